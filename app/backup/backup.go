@@ -0,0 +1,322 @@
+// Package backup dumps every row of a Farmily database, with its media
+// files, into a versioned archive and restores such an archive into an
+// empty instance with the same IDs.
+//
+// A backup is a JSON document listing each table's columns and rows. The
+// archive form is a gzipped tar holding that document as backup.json,
+// followed by the media files under media/<storage key>.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farmily/app/storage"
+	"io"
+	"log"
+	"path"
+	"time"
+)
+
+// Format identifies backup documents
+const Format = "farmily-backup"
+
+// Version is the version of the backup format written. Restores accept this
+// version and older ones.
+const Version = 1
+
+// DocumentName is the backup document's name inside an archive
+const DocumentName = "backup.json"
+
+// mediaDir holds the media files inside an archive
+const mediaDir = "media/"
+
+// Tables lists the backed up tables in the order they are restored, so rows
+// are inserted after the rows they refer to
+var Tables = []string{
+	"users",
+	"people",
+	"relationships",
+	"events",
+	"media",
+	"media_people",
+	"notes",
+	"note_revisions",
+	"external_ids",
+}
+
+// deferredColumns refer to tables restored later. They are restored empty
+// and filled in once every table is in.
+var deferredColumns = map[string][]string{
+	"people": {"profile_media_id"},
+}
+
+// Backup is a snapshot of every table
+type Backup struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// PasswordHashes reports whether users include their password hashes
+	PasswordHashes bool    `json:"password_hashes"`
+	Tables         []Table `json:"tables"`
+}
+
+// Table holds a table's rows, each a list of values in column order
+type Table struct {
+	Name    string          `json:"name"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Options controls what a backup includes
+type Options struct {
+	// PasswordHashes includes users' password hashes, so accounts can log in
+	// with their passwords after a restore
+	PasswordHashes bool
+}
+
+// Table returns the named table, or nil if the backup doesn't have it
+func (b *Backup) Table(name string) *Table {
+	for i := range b.Tables {
+		if b.Tables[i].Name == name {
+			return &b.Tables[i]
+		}
+	}
+	return nil
+}
+
+// MediaKeys returns the storage keys of the backed up media files
+func (b *Backup) MediaKeys() []string {
+	t := b.Table("media")
+	if t == nil {
+		return nil
+	}
+	col := t.column("file_path")
+	if col < 0 {
+		return nil
+	}
+
+	var keys []string
+	seen := map[string]bool{}
+	for _, row := range t.Rows {
+		if key, ok := row[col].(string); ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// drop removes a column and its values from every row
+func (t *Table) drop(name string) {
+	col := t.column(name)
+	if col < 0 {
+		return
+	}
+	t.Columns = append(t.Columns[:col:col], t.Columns[col+1:]...)
+	for i, row := range t.Rows {
+		if col < len(row) {
+			t.Rows[i] = append(row[:col:col], row[col+1:]...)
+		}
+	}
+}
+
+func (t *Table) column(name string) int {
+	for i, c := range t.Columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Dump reads every row of the backed up tables. Generated columns, such as
+// search vectors, are left out as the database computes them again.
+func Dump(db *sql.DB, opts Options) (*Backup, error) {
+	// One snapshot, so rows added while dumping can't refer to missing ones
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	b := &Backup{
+		Format:         Format,
+		Version:        Version,
+		CreatedAt:      time.Now().UTC(),
+		PasswordHashes: opts.PasswordHashes,
+	}
+
+	for _, name := range Tables {
+		columns, err := tableColumns(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "users" {
+			// Calendar tokens log anyone holding them into a feed of
+			// birthdays, so they never leave the instance
+			columns = without(columns, "calendar_token")
+			if !opts.PasswordHashes {
+				columns = without(columns, "password_hash")
+			}
+		}
+
+		t, err := dumpTable(tx, name, columns)
+		if err != nil {
+			return nil, err
+		}
+		b.Tables = append(b.Tables, *t)
+	}
+
+	return b, nil
+}
+
+func dumpTable(tx *sql.Tx, name string, columns []string) (*Table, error) {
+	t := &Table{Name: name, Columns: columns, Rows: [][]interface{}{}}
+
+	rows, err := tx.Query("SELECT " + columnList(columns) + " FROM " + name + " ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			// UUIDs and other types without a Go equivalent arrive as text
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		t.Rows = append(t.Rows, values)
+	}
+	return t, rows.Err()
+}
+
+// tableColumns lists a table's stored columns in order
+func tableColumns(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, table string) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND is_generated = 'NEVER'
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("table " + table + " does not exist")
+	}
+	return columns, nil
+}
+
+// WriteJSON writes the backup document on its own, without media files
+func WriteJSON(w io.Writer, b *Backup) error {
+	return json.NewEncoder(w).Encode(b)
+}
+
+// WriteArchive writes the backup document and the media files it refers to,
+// read from backend, as a gzipped tar. Files missing from storage are
+// logged and left out; restoring reports them.
+func WriteArchive(ctx context.Context, w io.Writer, b *Backup, backend storage.Backend) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	doc, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    DocumentName,
+		Mode:    0o644,
+		Size:    int64(len(doc)),
+		ModTime: b.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(doc); err != nil {
+		return err
+	}
+
+	for _, key := range b.MediaKeys() {
+		err := archiveFile(ctx, tw, backend, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("backup: media file %s missing from storage, skipped", key)
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func archiveFile(ctx context.Context, tw *tar.Writer, backend storage.Backend, key string) error {
+	r, info, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join(mediaDir, key),
+		Mode:    0o644,
+		Size:    info.Size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+func without(columns []string, name string) []string {
+	var out []string
+	for _, c := range columns {
+		if c != name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// columnList quotes column names for a query
+func columnList(columns []string) string {
+	list := ""
+	for i, c := range columns {
+		if i > 0 {
+			list += ", "
+		}
+		list += `"` + c + `"`
+	}
+	return list
+}
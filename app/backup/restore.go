@@ -0,0 +1,420 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farmily/app/storage"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+)
+
+// noPassword is stored for restored accounts whose backup has no password
+// hash. It never matches a bcrypt hash, so the account can't log in.
+const noPassword = "!"
+
+// ErrInvalidBackup is returned for files that aren't a backup this version
+// of Farmily can restore
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// ErrNotEmpty is returned when restoring into a database that already holds
+// family data
+var ErrNotEmpty = errors.New("database is not empty")
+
+// Report summarises a restore
+type Report struct {
+	// Rows counts the rows restored per table
+	Rows       map[string]int `json:"rows"`
+	MediaFiles int            `json:"media_files"`
+	Warnings   []string       `json:"warnings"`
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Restore loads a backup, either a backup document or an archive holding one
+// and the media files, into a database with no family data and stores the
+// media files in backend. Rows keep their IDs.
+//
+// User accounts may already exist, such as the administrator restoring. An
+// account with the same ID or email as a backed up one is replaced by it,
+// keeping its password hash when the backup has none. Restored accounts
+// without a password hash can't log in.
+//
+// The rows are restored in one transaction, so a failed restore leaves the
+// database as it was.
+func Restore(ctx context.Context, db *sql.DB, r io.Reader, backend storage.Backend) (*Report, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		h, err := tr.Next()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if h.Name != DocumentName {
+			return nil, fmt.Errorf("%w: archive doesn't start with %s", ErrInvalidBackup, DocumentName)
+		}
+		b, err := decode(tr)
+		if err != nil {
+			return nil, err
+		}
+		return restore(ctx, db, b, tr, backend)
+	}
+
+	b, err := decode(br)
+	if err != nil {
+		return nil, err
+	}
+	return restore(ctx, db, b, nil, backend)
+}
+
+func decode(r io.Reader) (*Backup, error) {
+	dec := json.NewDecoder(r)
+	// Keep numbers as written, so large integers don't lose precision
+	dec.UseNumber()
+
+	var b Backup
+	if err := dec.Decode(&b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if b.Format != Format {
+		return nil, fmt.Errorf("%w: not a Farmily backup", ErrInvalidBackup)
+	}
+	if b.Version < 1 || b.Version > Version {
+		return nil, fmt.Errorf("%w: backup version %d is not supported", ErrInvalidBackup, b.Version)
+	}
+	for _, t := range b.Tables {
+		for _, row := range t.Rows {
+			if len(row) != len(t.Columns) {
+				return nil, fmt.Errorf("%w: %s row has %d values for %d columns", ErrInvalidBackup, t.Name, len(row), len(t.Columns))
+			}
+		}
+	}
+	if err := checkMediaKeys(&b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// checkMediaKeys rejects media rows whose file path isn't a valid storage
+// key, so a restored entry can't point outside the media storage
+func checkMediaKeys(b *Backup) error {
+	t := b.Table("media")
+	if t == nil {
+		return nil
+	}
+	col := t.column("file_path")
+	if col < 0 {
+		return nil
+	}
+	for _, row := range t.Rows {
+		key, ok := row[col].(string)
+		if !ok {
+			return fmt.Errorf("%w: media file path %v is not a string", ErrInvalidBackup, row[col])
+		}
+		if err := storage.CheckKey(key); err != nil {
+			return fmt.Errorf("%w: media file path %q is not a valid storage key", ErrInvalidBackup, key)
+		}
+	}
+	return nil
+}
+
+// restore inserts the backup's rows and, from an archive, stores its media
+// files. Files are stored before committing; if anything fails they are
+// deleted again.
+func restore(ctx context.Context, db *sql.DB, b *Backup, tr *tar.Reader, backend storage.Backend) (*Report, error) {
+	report := &Report{Rows: map[string]int{}, Warnings: []string{}}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkEmpty(tx); err != nil {
+		return nil, err
+	}
+
+	// Older backups carried calendar tokens; restored accounts get new ones
+	// when they ask for a feed
+	if users := b.Table("users"); users != nil {
+		users.drop("calendar_token")
+	}
+
+	deferred := map[string][][2]interface{}{}
+	for _, name := range Tables {
+		t := b.Table(name)
+		if t == nil {
+			continue
+		}
+		n, err := restoreTable(tx, t, deferred, report)
+		if err != nil {
+			return nil, err
+		}
+		report.Rows[name] = n
+	}
+	for table, updates := range deferred {
+		for _, column := range deferredColumns[table] {
+			stmt, err := tx.Prepare(`UPDATE ` + table + ` SET "` + column + `" = $2 WHERE id = $1`)
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range updates {
+				if _, err := stmt.Exec(u[0], u[1]); err != nil {
+					stmt.Close()
+					return nil, fmt.Errorf("restore %s.%s: %w", table, column, err)
+				}
+			}
+			stmt.Close()
+		}
+	}
+
+	keys := b.MediaKeys()
+	types := mediaTypes(b)
+	wanted := map[string]bool{}
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	var stored []string
+	cleanup := func() {
+		for _, key := range stored {
+			backend.Delete(context.Background(), key)
+		}
+	}
+
+	for tr != nil {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		key := strings.TrimPrefix(h.Name, mediaDir)
+		if key == h.Name || !wanted[key] {
+			report.warn("Skipped %s: not a media file of this backup", h.Name)
+			continue
+		}
+
+		if err := backend.Put(ctx, key, tr, h.Size, types[key]); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("store %s: %w", key, err)
+		}
+		stored = append(stored, key)
+		delete(wanted, key)
+		report.MediaFiles++
+	}
+
+	// Files not in the archive may already be in storage, such as when a
+	// backup document is restored onto the instance it came from
+	for _, key := range keys {
+		if !wanted[key] {
+			continue
+		}
+		if ok, err := backend.Exists(ctx, key); err != nil || !ok {
+			report.warn("Media file %s is missing; its media entry was restored without it", key)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkEmpty makes sure no family data would be mixed with the backup's
+func checkEmpty(tx *sql.Tx) error {
+	for _, name := range Tables {
+		if name == "users" {
+			continue
+		}
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM " + name + ")").Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: %s has rows", ErrNotEmpty, name)
+		}
+	}
+	return nil
+}
+
+// restoreTable inserts a table's rows. Deferred columns are inserted empty
+// and their values collected as (id, value) pairs to set later.
+func restoreTable(tx *sql.Tx, t *Table, deferred map[string][][2]interface{}, report *Report) (int, error) {
+	existing, err := tableColumns(tx, t.Name)
+	if err != nil {
+		return 0, err
+	}
+	known := map[string]bool{}
+	for _, c := range existing {
+		known[c] = true
+	}
+	for _, c := range t.Columns {
+		if !known[c] {
+			return 0, fmt.Errorf("%w: column %s.%s doesn't exist in this database", ErrInvalidBackup, t.Name, c)
+		}
+	}
+
+	columns := t.Columns
+	users := t.Name == "users"
+	hashCol := t.column("password_hash")
+	if users && hashCol < 0 {
+		columns = append(append([]string{}, columns...), "password_hash")
+	}
+
+	deferCol := -1
+	if cols := deferredColumns[t.Name]; len(cols) > 0 {
+		deferCol = t.column(cols[0])
+	}
+	idCol := t.column("id")
+	if deferCol >= 0 && idCol < 0 {
+		return 0, fmt.Errorf("%w: %s has no id column", ErrInvalidBackup, t.Name)
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	stmt, err := tx.Prepare("INSERT INTO " + t.Name + " (" + columnList(columns) + ") VALUES (" + strings.Join(placeholders, ", ") + ")")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	// Backups from before external IDs had a source mapped document-local
+	// IDs globally; like the migration, they are dropped
+	unscopedCol := -1
+	if t.Name == "external_ids" && t.column("source") < 0 {
+		unscopedCol = t.column("external_id")
+	}
+
+	withoutPassword := 0
+	restored := 0
+	for _, row := range t.Rows {
+		if unscopedCol >= 0 {
+			if id, ok := row[unscopedCol].(string); ok && strings.HasPrefix(id, "#") {
+				continue
+			}
+		}
+
+		args := make([]interface{}, len(row), len(columns))
+		for i, v := range row {
+			arg, err := param(v)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s.%s: %v", ErrInvalidBackup, t.Name, t.Columns[i], err)
+			}
+			args[i] = arg
+		}
+
+		if users {
+			hash, err := replaceUser(tx, t, args, hashCol < 0)
+			if err != nil {
+				return 0, err
+			}
+			if hashCol < 0 {
+				args = append(args, hash)
+			}
+			if hash == noPassword {
+				withoutPassword++
+			}
+		}
+
+		if deferCol >= 0 && args[deferCol] != nil {
+			deferred[t.Name] = append(deferred[t.Name], [2]interface{}{args[idCol], args[deferCol]})
+			args[deferCol] = nil
+		}
+
+		if _, err := stmt.Exec(args...); err != nil {
+			return 0, fmt.Errorf("restore %s: %w", t.Name, err)
+		}
+		restored++
+	}
+
+	if withoutPassword > 0 {
+		report.warn("%d restored accounts have no password and can't log in", withoutPassword)
+	}
+	return restored, nil
+}
+
+// replaceUser removes the account a backed up user replaces and returns the
+// password hash to restore. Without a hash in the backup, the replaced
+// account's hash is kept.
+func replaceUser(tx *sql.Tx, t *Table, args []interface{}, keepHash bool) (string, error) {
+	idCol, emailCol := t.column("id"), t.column("email")
+	if idCol < 0 || emailCol < 0 {
+		return "", fmt.Errorf("%w: users need id and email columns", ErrInvalidBackup)
+	}
+
+	hash := noPassword
+	if col := t.column("password_hash"); col >= 0 {
+		hash, _ = args[col].(string)
+	}
+	if keepHash {
+		err := tx.QueryRow("SELECT password_hash FROM users WHERE email = $1", args[emailCol]).Scan(&hash)
+		if err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	_, err := tx.Exec("DELETE FROM users WHERE id = $1 OR email = $2", args[idCol], args[emailCol])
+	return hash, err
+}
+
+// param converts a decoded JSON value into a query argument
+func param(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// mediaTypes maps media files' storage keys to their content types
+func mediaTypes(b *Backup) map[string]string {
+	types := map[string]string{}
+	t := b.Table("media")
+	if t == nil {
+		return types
+	}
+	keyCol, typeCol := t.column("file_path"), t.column("content_type")
+	if keyCol < 0 {
+		return types
+	}
+	for _, row := range t.Rows {
+		key, _ := row[keyCol].(string)
+		contentType := ""
+		if typeCol >= 0 {
+			contentType, _ = row[typeCol].(string)
+		}
+		if contentType == "" {
+			contentType = mime.TypeByExtension(path.Ext(key))
+		}
+		types[key] = contentType
+	}
+	return types
+}
@@ -6,11 +6,14 @@ import (
 	"log"
 	"os"
 
+	"farmily/app/storage"
+
 	_ "github.com/lib/pq"
 )
 
 type Config struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Storage storage.Backend
 }
 
 var AppConfig *Config
@@ -63,12 +66,36 @@ func InitDB() {
 		log.Fatal("Cannot establish database connection")
 	}
 
-	AppConfig = &Config{
-		DB: db,
+	if AppConfig == nil {
+		AppConfig = &Config{}
 	}
+	AppConfig.DB = db
 	log.Println("Database connected successfully")
 }
 
 func GetDB() *sql.DB {
 	return AppConfig.DB
 }
+
+// InitStorage sets up the media storage backend selected by STORAGE_BACKEND
+func InitStorage() {
+	kind := os.Getenv("STORAGE_BACKEND")
+	backend, err := storage.New(kind)
+	if err != nil {
+		log.Fatal("Failed to initialize media storage:", err)
+	}
+
+	if AppConfig == nil {
+		AppConfig = &Config{}
+	}
+	AppConfig.Storage = backend
+
+	if kind == "" {
+		kind = storage.KindLocal
+	}
+	log.Printf("Media storage backend: %s", kind)
+}
+
+func GetStorage() storage.Backend {
+	return AppConfig.Storage
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"database/sql"
+	"farmily/app/storage"
 	"log"
+	"path/filepath"
 )
 
 func RunMigrations(db *sql.DB) error {
@@ -124,6 +126,191 @@ func RunMigrations(db *sql.DB) error {
 	}
 	log.Println("✓ Notes table created/verified")
 
+	// Add role to users; editors and admins may change other people's notes
+	_, err = db.Exec(`
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member'
+			CHECK (role IN ('member', 'editor', 'admin'))
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Instances created before roles existed have only members; make the
+	// oldest account the admin so someone can hand out roles
+	_, err = db.Exec(`
+		UPDATE users SET role = 'admin'
+		WHERE id = (SELECT id FROM users ORDER BY created_at, id LIMIT 1)
+			AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin')
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ User roles created/verified")
+
+	// Add upload metadata columns to media
+	_, err = db.Exec(`
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS original_name VARCHAR(255);
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS content_type VARCHAR(100);
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS file_size BIGINT;
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL;
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS taken_at TIMESTAMP;
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Media upload columns created/verified")
+
+	// Media uploaded before storage backends existed recorded the file path on
+	// disk; strip the media directory so file_path holds a storage key
+	legacyPrefix := filepath.ToSlash(filepath.Clean(storage.MediaDir())) + "/"
+	_, err = db.Exec(`
+		UPDATE media SET file_path = substr(file_path, length($1) + 1)
+		WHERE left(file_path, length($1)) = $1
+	`, legacyPrefix)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Media storage keys verified")
+
+	// Create media_people table for tagging several people in one photo
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS media_people (
+			media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+			person_id UUID NOT NULL REFERENCES people(id) ON DELETE CASCADE,
+			region_x REAL,
+			region_y REAL,
+			region_width REAL,
+			region_height REAL,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (media_id, person_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Media people table created/verified")
+
+	// Create note_revisions table holding earlier versions of edited notes.
+	// edited_by and created_at are who wrote that version and when.
+	_, err = db.Exec(`
+		ALTER TABLE notes ADD COLUMN IF NOT EXISTS updated_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+		CREATE TABLE IF NOT EXISTS note_revisions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+			content TEXT NOT NULL,
+			edited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Note revisions table created/verified")
+
+	// Deleted notes are only marked deleted, so their revision history is kept
+	_, err = db.Exec(`
+		ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+		ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Note deletion columns created/verified")
+
+	// Add managed profile photos: a media image plus an optional crop
+	_, err = db.Exec(`
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS profile_media_id UUID REFERENCES media(id) ON DELETE SET NULL;
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS profile_crop_x REAL;
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS profile_crop_y REAL;
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS profile_crop_width REAL;
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS profile_crop_height REAL;
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Profile photo columns created/verified")
+
+	// Add full-text search vectors. Names weigh most, then occupation and
+	// places, then free text.
+	_, err = db.Exec(`
+		ALTER TABLE people ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(first_name, '') || ' ' || coalesce(middle_name, '') || ' ' ||
+				coalesce(last_name, '') || ' ' || coalesce(maiden_name, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(occupation, '') || ' ' || coalesce(birth_place, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(biography, '')), 'C')
+		) STORED;
+		ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			to_tsvector('english', coalesce(content, ''))
+		) STORED;
+		ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			to_tsvector('english', coalesce(description, ''))
+		) STORED;
+		ALTER TABLE media ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(title, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'C')
+		) STORED;
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Search vectors created/verified")
+
+	// Create external identifiers table. Imports map the IDs other programs
+	// use for people and events to rows here, so re-importing a file updates
+	// the same rows. Entries for deleted rows are ignored when resolving.
+	// IDs local to a document, such as "#P1", are scoped to the source they
+	// were imported from; persistent identifiers have an empty source.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS external_ids (
+			entity_type VARCHAR(20) NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			external_id TEXT NOT NULL,
+			entity_id UUID NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (entity_type, source, external_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Tables created before sources existed mapped local IDs globally, so
+	// unrelated documents matched each other's people. Those mappings can't
+	// be attributed to a source and are dropped.
+	_, err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = 'external_ids' AND column_name = 'source'
+			) THEN
+				ALTER TABLE external_ids ADD COLUMN source TEXT NOT NULL DEFAULT '';
+				DELETE FROM external_ids WHERE external_id LIKE '#%';
+				ALTER TABLE external_ids DROP CONSTRAINT external_ids_pkey;
+				ALTER TABLE external_ids ADD PRIMARY KEY (entity_type, source, external_id);
+			END IF;
+		END
+		$$
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ External identifiers table created/verified")
+
+	// Add calendar feed tokens to users. The token in a feed URL stands in
+	// for logging in, since calendar apps can't.
+	_, err = db.Exec(`
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token VARCHAR(64) UNIQUE
+	`)
+	if err != nil {
+		return err
+	}
+	log.Println("✓ Calendar tokens created/verified")
+
 	// Create indexes for better query performance
 	_, err = db.Exec(`
 		CREATE INDEX IF NOT EXISTS idx_people_name ON people(last_name, first_name);
@@ -133,7 +320,16 @@ func RunMigrations(db *sql.DB) error {
 		CREATE INDEX IF NOT EXISTS idx_events_person ON events(person_id);
 		CREATE INDEX IF NOT EXISTS idx_events_date ON events(event_date);
 		CREATE INDEX IF NOT EXISTS idx_media_person ON media(person_id);
+		CREATE INDEX IF NOT EXISTS idx_media_event ON media(event_id);
+		CREATE INDEX IF NOT EXISTS idx_media_taken_at ON media(taken_at);
+		CREATE INDEX IF NOT EXISTS idx_media_people_person ON media_people(person_id);
 		CREATE INDEX IF NOT EXISTS idx_notes_person ON notes(person_id);
+		CREATE INDEX IF NOT EXISTS idx_note_revisions_note ON note_revisions(note_id);
+		CREATE INDEX IF NOT EXISTS idx_people_search ON people USING GIN(search_vector);
+		CREATE INDEX IF NOT EXISTS idx_notes_search ON notes USING GIN(search_vector);
+		CREATE INDEX IF NOT EXISTS idx_events_search ON events USING GIN(search_vector);
+		CREATE INDEX IF NOT EXISTS idx_media_search ON media USING GIN(search_vector);
+		CREATE INDEX IF NOT EXISTS idx_external_ids_entity ON external_ids(entity_id);
 	`)
 	if err != nil {
 		return err
@@ -0,0 +1,348 @@
+package exporter
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Book is a register-style descendant report: the root ancestor and their
+// descendants generation by generation, numbered the NGSQ way. Every
+// descendant gets a number when listed as a child; those with more to tell
+// (a family, events, a biography or notes) get their own entry in the next
+// generation, marked + in their parents' list of children.
+type Book struct {
+	Title       string
+	Root        *models.Person
+	Generations []BookGeneration
+	CreatedAt   time.Time
+}
+
+// BookGeneration holds the entries of one generation, 1 being the root
+type BookGeneration struct {
+	Number  int
+	Entries []*BookEntry
+}
+
+// BookEntry is a descendant's section of the book
+type BookEntry struct {
+	Number     int
+	Generation int
+	Person     *models.Person
+	// Lineage names the ancestors back to the root, e.g. "John², William¹"
+	Lineage string
+	// Summary describes the person's birth, death and occupation in full
+	// sentences, without their name
+	Summary   string
+	Spouses   []BookSpouse
+	Events    []BookEvent
+	Biography string
+	Notes     []string
+	Families  []BookFamily
+}
+
+// BookSpouse is a descendant's spouse
+type BookSpouse struct {
+	Person *models.Person
+	// Married says when the couple married and when the marriage ended, to
+	// follow "married <spouse>"
+	Married string
+	Summary string
+}
+
+// BookEvent is an event of the descendant's life other than birth and death
+type BookEvent struct {
+	Type        string
+	Date        string
+	Place       string
+	Description string
+}
+
+// BookFamily lists a descendant's children with one partner, or with no
+// known partner
+type BookFamily struct {
+	Partner  *models.Person
+	Children []BookChild
+}
+
+// BookChild is a child in a family list
+type BookChild struct {
+	Numeral string
+	Number  int
+	Person  *models.Person
+	Summary string
+	// Continued marks children with an entry of their own
+	Continued bool
+	// SeeAlso is set when the child was numbered earlier, through another
+	// line of descent
+	SeeAlso bool
+}
+
+// NewBook builds the descendant report of root from a dataset holding root's
+// descendants. maxGenerations limits how many generations get entries; 0
+// means all of them.
+func NewBook(d *Dataset, root uuid.UUID, maxGenerations int) *Book {
+	people := map[uuid.UUID]*models.Person{}
+	for i := range d.People {
+		people[d.People[i].ID] = &d.People[i]
+	}
+	events := map[uuid.UUID][]models.Event{}
+	for _, e := range d.Events {
+		events[e.PersonID] = append(events[e.PersonID], e)
+	}
+	notes := map[uuid.UUID][]string{}
+	for _, n := range d.Notes {
+		if content := strings.TrimSpace(n.Content); content != "" {
+			notes[n.PersonID] = append(notes[n.PersonID], content)
+		}
+	}
+	families := map[uuid.UUID][]*Family{}
+	for _, f := range d.Families() {
+		for _, p := range f.Partners {
+			families[p] = append(families[p], f)
+		}
+	}
+
+	book := &Book{Root: people[root], CreatedAt: time.Now()}
+	if book.Root == nil {
+		return book
+	}
+	book.Title = "Descendants of " + book.Root.GetDisplayName()
+
+	// More to tell than a line in a list of children
+	hasEntry := func(id uuid.UUID) bool {
+		p := people[id]
+		return len(families[id]) > 0 || len(events[id]) > 0 || len(notes[id]) > 0 ||
+			(p.Biography.Valid && strings.TrimSpace(p.Biography.String) != "")
+	}
+
+	numbers := map[uuid.UUID]int{root: 1}
+	lineage := map[uuid.UUID]string{root: ""}
+	next := 2
+
+	current := []uuid.UUID{root}
+	for g := 1; len(current) > 0 && (maxGenerations == 0 || g <= maxGenerations); g++ {
+		generation := BookGeneration{Number: g}
+		var upcoming []uuid.UUID
+
+		for _, id := range current {
+			p := people[id]
+			entry := &BookEntry{
+				Number:     numbers[id],
+				Generation: g,
+				Person:     p,
+				Lineage:    lineage[id],
+				Summary:    bookSummary(p),
+				Events:     bookEvents(events[id]),
+				Notes:      notes[id],
+			}
+			if p.Biography.Valid {
+				entry.Biography = strings.TrimSpace(p.Biography.String)
+			}
+
+			for _, f := range families[id] {
+				var partner *models.Person
+				for _, other := range f.Partners {
+					if other != id {
+						partner = people[other]
+					}
+				}
+				if partner != nil {
+					entry.Spouses = append(entry.Spouses, BookSpouse{
+						Person:  partner,
+						Married: bookMarriage(f.Marriage),
+						Summary: bookSummary(partner),
+					})
+				}
+				if len(f.Children) == 0 {
+					continue
+				}
+
+				family := BookFamily{Partner: partner}
+				for i, child := range sortByBirth(f.Children, people) {
+					c := BookChild{Numeral: romanNumeral(i + 1), Person: people[child], Summary: bookSummary(people[child])}
+					if n, ok := numbers[child]; ok {
+						c.Number, c.SeeAlso = n, true
+					} else {
+						numbers[child] = next
+						c.Number = next
+						next++
+						lineage[child] = bookLineage(p, g, lineage[id])
+						if hasEntry(child) {
+							c.Continued = true
+							upcoming = append(upcoming, child)
+						}
+					}
+					family.Children = append(family.Children, c)
+				}
+				entry.Families = append(entry.Families, family)
+			}
+
+			generation.Entries = append(generation.Entries, entry)
+		}
+
+		book.Generations = append(book.Generations, generation)
+		current = upcoming
+	}
+
+	// Children whose entries fall past the last generation aren't continued
+	if maxGenerations > 0 && len(book.Generations) == maxGenerations {
+		last := book.Generations[len(book.Generations)-1]
+		for _, e := range last.Entries {
+			for i := range e.Families {
+				for j := range e.Families[i].Children {
+					e.Families[i].Children[j].Continued = false
+				}
+			}
+		}
+	}
+
+	return book
+}
+
+// bookLineage adds a parent to their child's lineage: "John², William¹"
+func bookLineage(parent *models.Person, generation int, parentLineage string) string {
+	line := parent.FirstName + superscript(generation)
+	if parentLineage != "" {
+		line += ", " + parentLineage
+	}
+	return line
+}
+
+// bookSummary describes a person's birth, death and occupation
+func bookSummary(p *models.Person) string {
+	var parts []string
+	if s := bookFact(p.BirthDate, p.BirthPlace.String); s != "" {
+		verb := "was born"
+		if p.Gender != models.GenderMale && p.Gender != models.GenderFemale {
+			verb = "were born"
+		}
+		parts = append(parts, verb+s)
+	}
+	if s := bookFact(p.DeathDate, p.DeathPlace.String); s != "" {
+		parts = append(parts, "died"+s)
+	}
+
+	var sentences []string
+	if len(parts) > 0 {
+		sentences = append(sentences, pronoun(p)+" "+strings.Join(parts, " and ")+".")
+	}
+	if p.Occupation.Valid && p.Occupation.String != "" {
+		sentences = append(sentences, pronoun(p)+" worked as "+p.Occupation.String+".")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// bookFact describes when and where something happened: " on 2 January 1900
+// in Boston"
+func bookFact(date sql.NullTime, place string) string {
+	s := ""
+	if date.Valid {
+		s = " on " + bookDate(date.Time)
+	}
+	if place != "" {
+		s += " in " + place
+	}
+	return s
+}
+
+// bookMarriage describes when a couple married and when their marriage
+// ended, to follow "married <spouse>"
+func bookMarriage(r *models.Relationship) string {
+	if r == nil {
+		return ""
+	}
+	s := bookFact(r.StartDate, "")
+	if r.EndDate.Valid {
+		s += "; the marriage ended on " + bookDate(r.EndDate.Time)
+	}
+	return s
+}
+
+func bookEvents(events []models.Event) []BookEvent {
+	var out []BookEvent
+	for _, e := range events {
+		if e.EventType == models.EventBirth || e.EventType == models.EventDeath {
+			continue
+		}
+		be := BookEvent{
+			Type:        capitalize(e.EventType),
+			Place:       e.EventPlace.String,
+			Description: e.Description.String,
+		}
+		if e.EventDate.Valid {
+			be.Date = bookDate(e.EventDate.Time)
+		}
+		out = append(out, be)
+	}
+	return out
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func bookDate(t time.Time) string {
+	return t.Format("2 January 2006")
+}
+
+func pronoun(p *models.Person) string {
+	switch p.Gender {
+	case models.GenderMale:
+		return "He"
+	case models.GenderFemale:
+		return "She"
+	}
+	return "They"
+}
+
+// sortByBirth orders children oldest first, those without a birth date last
+func sortByBirth(ids []uuid.UUID, people map[uuid.UUID]*models.Person) []uuid.UUID {
+	out := append([]uuid.UUID(nil), ids...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := people[out[i]].BirthDate, people[out[j]].BirthDate
+		if a.Valid != b.Valid {
+			return a.Valid
+		}
+		return a.Valid && a.Time.Before(b.Time)
+	})
+	return out
+}
+
+// romanNumeral returns n in lower case roman numerals, as children are
+// listed
+func romanNumeral(n int) string {
+	numerals := []struct {
+		value int
+		digit string
+	}{
+		{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"}, {100, "c"}, {90, "xc"},
+		{50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+	}
+	var b strings.Builder
+	for _, r := range numerals {
+		for n >= r.value {
+			b.WriteString(r.digit)
+			n -= r.value
+		}
+	}
+	return b.String()
+}
+
+// superscript writes a generation number in superscript digits
+func superscript(n int) string {
+	digits := []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+	var out []rune
+	for _, c := range strconv.Itoa(n) {
+		out = append(out, digits[c-'0'])
+	}
+	return string(out)
+}
@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"html/template"
+	"io"
+)
+
+// bookTemplate lays a book out for printing: a title page, then each
+// generation starting on a new page
+var bookTemplate = template.Must(template.New("book").Funcs(template.FuncMap{
+	"pronoun": pronoun,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<style>
+@page { size: A4; margin: 20mm 18mm; }
+body { font-family: Georgia, "Times New Roman", serif; font-size: 11pt; line-height: 1.45; color: #111; max-width: 170mm; margin: 0 auto; }
+.title-page { text-align: center; padding-top: 35vh; page-break-after: always; }
+.title-page h1 { font-size: 26pt; margin-bottom: 0.3em; }
+.title-page p { color: #555; }
+h2 { font-size: 16pt; text-align: center; margin-top: 0; padding-top: 1em; border-bottom: 1px solid #999; page-break-before: always; }
+.entry { margin: 1.2em 0; page-break-inside: avoid; }
+.entry .name { font-weight: bold; }
+.lineage { font-style: italic; }
+.entry h4 { font-size: 11pt; margin: 0.8em 0 0.2em; }
+.events, .notes { margin: 0.2em 0 0.2em 1.5em; padding: 0; }
+.children { list-style: none; margin: 0.2em 0 0.6em 0; padding: 0; }
+.children li { display: grid; grid-template-columns: 1.2em 2.5em 2.5em 1fr; }
+.children .continued { text-align: center; font-weight: bold; }
+.children .number { text-align: right; padding-right: 0.4em; }
+.children .numeral { text-align: right; padding-right: 0.6em; }
+.biography, .note { white-space: pre-line; }
+@media print { a { color: inherit; text-decoration: none; } }
+</style>
+</head>
+<body>
+<section class="title-page">
+<h1>{{.Title}}</h1>
+<p>{{len .Generations}} generations &middot; generated {{.CreatedAt.Format "2 January 2006"}}</p>
+</section>
+{{range .Generations}}
+<h2>Generation {{.Number}}</h2>
+{{range .Entries}}{{$entry := .}}
+<div class="entry" id="p{{.Number}}">
+<p><span class="name">{{.Number}}. {{.Person.GetDisplayName}}<sup>{{.Generation}}</sup></span>{{if .Lineage}} <span class="lineage">({{.Lineage}})</span>{{end}}{{if .Summary}} {{.Summary}}{{end}}</p>
+{{range .Spouses}}<p>{{pronoun $entry.Person}} married <strong>{{.Person.GetDisplayName}}</strong>{{.Married}}.{{if .Summary}} {{.Summary}}{{end}}</p>
+{{end}}
+{{if .Biography}}<p class="biography">{{.Biography}}</p>{{end}}
+{{if .Events}}<h4>Events</h4>
+<ul class="events">{{range .Events}}<li>{{.Type}}{{if .Date}}, {{.Date}}{{end}}{{if .Place}}, {{.Place}}{{end}}{{if .Description}}: {{.Description}}{{end}}</li>{{end}}</ul>{{end}}
+{{if .Notes}}<h4>Notes</h4>
+<ul class="notes">{{range .Notes}}<li class="note">{{.}}</li>{{end}}</ul>{{end}}
+{{range .Families}}
+<h4>Children of {{$entry.Person.GetDisplayName}}{{if .Partner}} and {{.Partner.GetDisplayName}}{{end}}:</h4>
+<ol class="children">{{range .Children}}
+<li><span class="continued">{{if .Continued}}+{{end}}</span><span class="number">{{if .Continued}}<a href="#p{{.Number}}">{{.Number}}</a>{{else}}{{.Number}}{{end}}</span><span class="numeral">{{.Numeral}}.</span><span>{{.Person.GetDisplayName}}.{{if .Summary}} {{.Summary}}{{end}}{{if .SeeAlso}} See {{.Number}} above.{{end}}</span></li>{{end}}
+</ol>
+{{end}}
+</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// BookHTML writes the book as an HTML page ready to print
+func BookHTML(w io.Writer, b *Book) error {
+	return bookTemplate.Execute(w, b)
+}
@@ -0,0 +1,169 @@
+package exporter
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Book type sizes, in points
+const (
+	bookTextSize    = 10.5
+	bookHeadingSize = 16
+	bookTitleSize   = 24
+)
+
+// BookPDF writes the book as an A4 PDF, laid out like the HTML: a title
+// page, then each generation starting on a new page
+func BookPDF(w io.Writer, b *Book) error {
+	doc := newPDFDoc(b.Title)
+
+	doc.newPage()
+	doc.y = pdfPageHeight * 0.62
+	doc.centered(b.Title, pdfBold, bookTitleSize, 0)
+	doc.centered(strconv.Itoa(len(b.Generations))+" generations - generated "+b.CreatedAt.Format("2 January 2006"),
+		pdfRegular, bookTextSize, 8)
+
+	left := pdfMargin
+	for _, g := range b.Generations {
+		doc.newPage()
+		doc.centered("Generation "+strconv.Itoa(g.Number), pdfBold, bookHeadingSize, 0)
+		doc.rule(4)
+
+		for _, e := range g.Entries {
+			name := e.Person.GetDisplayName()
+
+			runs := []pdfRun{
+				{text: strconv.Itoa(e.Number) + ". " + name, font: pdfBold},
+				{text: strconv.Itoa(e.Generation), font: pdfBold, sup: true},
+			}
+			if e.Lineage != "" {
+				runs = append(runs, pdfRun{text: " (", font: pdfItalic})
+				runs = append(runs, superscriptRuns(e.Lineage, pdfItalic)...)
+				runs = append(runs, pdfRun{text: ")", font: pdfItalic})
+			}
+			if e.Summary != "" {
+				runs = append(runs, pdfRun{text: " " + e.Summary})
+			}
+			doc.paragraph(runs, bookTextSize, left, 14)
+
+			for _, s := range e.Spouses {
+				runs := []pdfRun{
+					{text: pronoun(e.Person) + " married "},
+					{text: s.Person.GetDisplayName(), font: pdfBold},
+					{text: s.Married + "."},
+				}
+				if s.Summary != "" {
+					runs = append(runs, pdfRun{text: " " + s.Summary})
+				}
+				doc.paragraph(runs, bookTextSize, left, 4)
+			}
+
+			for _, para := range strings.Split(e.Biography, "\n") {
+				if para = strings.TrimSpace(para); para != "" {
+					doc.paragraph([]pdfRun{{text: para}}, bookTextSize, left, 4)
+				}
+			}
+
+			if len(e.Events) > 0 {
+				doc.paragraph([]pdfRun{{text: "Events", font: pdfBold}}, bookTextSize, left, 6)
+				for _, ev := range e.Events {
+					text := ev.Type
+					if ev.Date != "" {
+						text += ", " + ev.Date
+					}
+					if ev.Place != "" {
+						text += ", " + ev.Place
+					}
+					if ev.Description != "" {
+						text += ": " + ev.Description
+					}
+					doc.paragraph([]pdfRun{{text: text}}, bookTextSize, left+14, 1,
+						pdfLabel{text: "•", x: left + 4})
+				}
+			}
+
+			if len(e.Notes) > 0 {
+				doc.paragraph([]pdfRun{{text: "Notes", font: pdfBold}}, bookTextSize, left, 6)
+				for _, note := range e.Notes {
+					for i, para := range strings.Split(note, "\n") {
+						if para = strings.TrimSpace(para); para == "" {
+							continue
+						}
+						var labels []pdfLabel
+						if i == 0 {
+							labels = append(labels, pdfLabel{text: "•", x: left + 4})
+						}
+						doc.paragraph([]pdfRun{{text: para}}, bookTextSize, left+14, 1, labels...)
+					}
+				}
+			}
+
+			for _, f := range e.Families {
+				heading := "Children of " + name
+				if f.Partner != nil {
+					heading += " and " + f.Partner.GetDisplayName()
+				}
+				doc.paragraph([]pdfRun{{text: heading + ":", font: pdfBold}}, bookTextSize, left, 6)
+
+				for _, c := range f.Children {
+					text := c.Person.GetDisplayName() + "."
+					if c.Summary != "" {
+						text += " " + c.Summary
+					}
+					if c.SeeAlso {
+						text += " See " + strconv.Itoa(c.Number) + " above."
+					}
+					labels := []pdfLabel{
+						{text: strconv.Itoa(c.Number), x: left + 44, right: true},
+						{text: c.Numeral + ".", x: left + 74, right: true},
+					}
+					if c.Continued {
+						labels = append(labels, pdfLabel{text: "+", font: pdfBold, x: left + 6})
+					}
+					doc.paragraph([]pdfRun{{text: text}}, bookTextSize, left+80, 1, labels...)
+				}
+			}
+		}
+	}
+
+	return doc.write(w)
+}
+
+// superscriptRuns splits text around superscript digits, which the PDF
+// fonts don't have, into runs drawn raised
+func superscriptRuns(s string, font pdfFont) []pdfRun {
+	digits := []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+	digit := func(r rune) int {
+		for i, d := range digits {
+			if d == r {
+				return i
+			}
+		}
+		return -1
+	}
+
+	var runs []pdfRun
+	var cur strings.Builder
+	sup := false
+	flush := func() {
+		if cur.Len() > 0 {
+			runs = append(runs, pdfRun{text: cur.String(), font: font, sup: sup})
+			cur.Reset()
+		}
+	}
+	for _, r := range s {
+		i := digit(r)
+		if (i >= 0) != sup {
+			flush()
+			sup = i >= 0
+		}
+		if sup {
+			cur.WriteByte(byte('0' + i))
+		} else {
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return runs
+}
@@ -0,0 +1,341 @@
+package exporter
+
+import (
+	"farmily/app/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Generations ranks the dataset's people by generation, 0 being the oldest.
+// Children are ranked below their parents and spouses on the same row;
+// people who married in, or whose parents aren't known, are ranked just
+// above their children.
+func (d *Dataset) Generations() map[uuid.UUID]int {
+	gen := map[uuid.UUID]int{}
+	for _, p := range d.People {
+		gen[p.ID] = 0
+	}
+
+	// Each pass only raises ranks, and a loop in bad data (someone their own
+	// ancestor) would raise them forever, so passes are bounded
+	push := func() bool {
+		changed := false
+		for i := 0; i <= len(d.People); i++ {
+			changed = false
+			for _, p := range d.People {
+				for _, parent := range d.parents[p.ID] {
+					if gen[p.ID] < gen[parent]+1 {
+						gen[p.ID] = gen[parent] + 1
+						changed = true
+					}
+				}
+				for _, spouse := range d.spouses[p.ID] {
+					if gen[p.ID] < gen[spouse] {
+						gen[p.ID] = gen[spouse]
+						changed = true
+					}
+				}
+			}
+			if !changed {
+				return true
+			}
+		}
+		return false
+	}
+	// Pull down people without parents to sit above their children
+	pull := func() bool {
+		changed := false
+		for _, p := range d.People {
+			if len(d.parents[p.ID]) > 0 || len(d.children[p.ID]) == 0 {
+				continue
+			}
+			lowest := -1
+			for _, child := range d.children[p.ID] {
+				if lowest < 0 || gen[child] < lowest {
+					lowest = gen[child]
+				}
+			}
+			if lowest-1 > gen[p.ID] {
+				gen[p.ID] = lowest - 1
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	for i := 0; i < 8; i++ {
+		if !push() || !pull() {
+			break
+		}
+	}
+
+	top := -1
+	for _, g := range gen {
+		if top < 0 || g < top {
+			top = g
+		}
+	}
+	for id := range gen {
+		gen[id] -= top
+	}
+	return gen
+}
+
+// Chart sizes, in SVG user units
+const (
+	chartBoxWidth  = 170
+	chartBoxHeight = 54
+	chartSpouseGap = 30
+	chartUnitGap   = 24
+	chartRowGap    = 70
+	chartMargin    = 20
+)
+
+// chartLayout places each person's box on a family chart
+type chartLayout struct {
+	// boxes holds the top left corner of each person's box
+	boxes         map[uuid.UUID][2]float64
+	width, height float64
+}
+
+// chartUnit is a person and their spouses in the same generation, drawn
+// side by side
+type chartUnit struct {
+	members []uuid.UUID
+	left    float64
+}
+
+func (u *chartUnit) width() float64 {
+	return float64(len(u.members))*chartBoxWidth + float64(len(u.members)-1)*chartSpouseGap
+}
+
+// layoutChart arranges people in rows by generation, spouses side by side
+// and children below their parents, keeping lines from crossing where it
+// can
+func layoutChart(d *Dataset) *chartLayout {
+	gen := d.Generations()
+	rowCount := 0
+	for _, g := range gen {
+		if g+1 > rowCount {
+			rowCount = g + 1
+		}
+	}
+
+	males := map[uuid.UUID]bool{}
+	for _, p := range d.People {
+		males[p.ID] = p.Gender == models.GenderMale
+	}
+
+	// Group spouses on the same row into units, walking from the end of a
+	// chain of marriages so each spouse is next to their partner
+	rows := make([][]*chartUnit, rowCount)
+	unitOf := map[uuid.UUID]*chartUnit{}
+	sameRowSpouses := func(id uuid.UUID) []uuid.UUID {
+		var out []uuid.UUID
+		for _, s := range d.spouses[id] {
+			if gen[s] == gen[id] {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	for _, p := range d.People {
+		if unitOf[p.ID] != nil {
+			continue
+		}
+		// Find everyone linked by marriage on this row
+		group := []uuid.UUID{p.ID}
+		inGroup := map[uuid.UUID]bool{p.ID: true}
+		for i := 0; i < len(group); i++ {
+			for _, s := range sameRowSpouses(group[i]) {
+				if !inGroup[s] {
+					inGroup[s] = true
+					group = append(group, s)
+				}
+			}
+		}
+		start := group[0]
+		for _, id := range group {
+			n, best := len(sameRowSpouses(id)), len(sameRowSpouses(start))
+			if n < best || n == best && males[id] && !males[start] {
+				start = id
+			}
+		}
+
+		u := &chartUnit{}
+		placed := map[uuid.UUID]bool{}
+		var visit func(id uuid.UUID)
+		visit = func(id uuid.UUID) {
+			placed[id] = true
+			u.members = append(u.members, id)
+			unitOf[id] = u
+			for _, s := range sameRowSpouses(id) {
+				if !placed[s] {
+					visit(s)
+				}
+			}
+		}
+		visit(start)
+		rows[gen[p.ID]] = append(rows[gen[p.ID]], u)
+	}
+
+	// Order each row by where its members' parents, then children, sit,
+	// sweeping down and up a few times
+	index := map[*chartUnit]float64{}
+	renumber := func(row []*chartUnit) {
+		for i, u := range row {
+			index[u] = float64(i)
+		}
+	}
+	for _, row := range rows {
+		renumber(row)
+	}
+	orderBy := func(row []*chartUnit, related func(uuid.UUID) []uuid.UUID) {
+		keys := map[*chartUnit]float64{}
+		for _, u := range row {
+			sum, n := 0.0, 0
+			for _, m := range u.members {
+				for _, r := range related(m) {
+					if ru := unitOf[r]; ru != nil {
+						sum += index[ru]
+						n++
+					}
+				}
+			}
+			keys[u] = index[u]
+			if n > 0 {
+				keys[u] = sum / float64(n)
+			}
+		}
+		sort.SliceStable(row, func(i, j int) bool { return keys[row[i]] < keys[row[j]] })
+		renumber(row)
+	}
+	parentsOf := func(id uuid.UUID) []uuid.UUID { return d.parents[id] }
+	childrenOf := func(id uuid.UUID) []uuid.UUID { return d.children[id] }
+	for sweep := 0; sweep < 3; sweep++ {
+		for g := 1; g < rowCount; g++ {
+			orderBy(rows[g], parentsOf)
+		}
+		for g := rowCount - 2; g >= 0; g-- {
+			orderBy(rows[g], childrenOf)
+		}
+	}
+	for g := 1; g < rowCount; g++ {
+		orderBy(rows[g], parentsOf)
+	}
+
+	// Place rows top down, each unit under its parents where there's room,
+	// then bottom up, centering parents over their children
+	centerOf := func(ids []uuid.UUID, boxes map[uuid.UUID]float64) (float64, bool) {
+		sum, n := 0.0, 0
+		for _, id := range ids {
+			if x, ok := boxes[id]; ok {
+				sum += x
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, false
+		}
+		return sum / float64(n), true
+	}
+	centers := map[uuid.UUID]float64{}
+	setCenters := func(u *chartUnit) {
+		for i, m := range u.members {
+			centers[m] = u.left + float64(i)*(chartBoxWidth+chartSpouseGap) + chartBoxWidth/2
+		}
+	}
+
+	for g, row := range rows {
+		cursor := 0.0
+		for _, u := range row {
+			var parents []uuid.UUID
+			for _, m := range u.members {
+				if g > 0 {
+					parents = append(parents, d.parents[m]...)
+				}
+			}
+			u.left = cursor
+			if c, ok := centerOf(parents, centers); ok && c-u.width()/2 > cursor {
+				u.left = c - u.width()/2
+			}
+			cursor = u.left + u.width() + chartUnitGap
+			setCenters(u)
+		}
+	}
+	for g := rowCount - 2; g >= 0; g-- {
+		row := rows[g]
+		for i := len(row) - 1; i >= 0; i-- {
+			u := row[i]
+			var children []uuid.UUID
+			for _, m := range u.members {
+				children = append(children, d.children[m]...)
+			}
+			c, ok := centerOf(children, centers)
+			if !ok {
+				continue
+			}
+			left := c - u.width()/2
+			if i+1 < len(row) {
+				if limit := row[i+1].left - chartUnitGap - u.width(); left > limit {
+					left = limit
+				}
+			}
+			if i > 0 {
+				if limit := row[i-1].left + row[i-1].width() + chartUnitGap; left < limit {
+					left = limit
+				}
+			}
+			if left > u.left {
+				u.left = left
+				setCenters(u)
+			}
+		}
+	}
+
+	// Shift everything into the margin
+	minLeft, maxRight := 0.0, 0.0
+	first := true
+	for _, row := range rows {
+		for _, u := range row {
+			if first || u.left < minLeft {
+				minLeft = u.left
+			}
+			if first || u.left+u.width() > maxRight {
+				maxRight = u.left + u.width()
+			}
+			first = false
+		}
+	}
+
+	layout := &chartLayout{
+		boxes:  map[uuid.UUID][2]float64{},
+		width:  maxRight - minLeft + 2*chartMargin,
+		height: float64(rowCount)*(chartBoxHeight+chartRowGap) - chartRowGap + 2*chartMargin,
+	}
+	if rowCount == 0 {
+		layout.width, layout.height = 2*chartMargin, 2*chartMargin
+	}
+	for g, row := range rows {
+		y := chartMargin + float64(g)*(chartBoxHeight+chartRowGap)
+		for _, u := range row {
+			for i, m := range u.members {
+				x := u.left - minLeft + chartMargin + float64(i)*(chartBoxWidth+chartSpouseGap)
+				layout.boxes[m] = [2]float64{x, y}
+			}
+		}
+	}
+	return layout
+}
+
+// chartDates returns the years shown under a person's name on a chart
+func chartDates(p *models.Person) string {
+	if p.BirthDate.Valid {
+		return p.GetLifespan()
+	}
+	if p.DeathDate.Valid {
+		return "d. " + p.DeathDate.Time.Format("2006")
+	}
+	return ""
+}
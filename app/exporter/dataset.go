@@ -0,0 +1,472 @@
+// Package exporter writes the family tree, or a branch of it, in formats
+// other genealogy programs can read.
+package exporter
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Selection scopes
+const (
+	ScopeAncestors   = "ancestors"
+	ScopeDescendants = "descendants"
+	ScopeBoth        = "both"
+	ScopeRelatives   = "relatives"
+)
+
+// DefaultDistance is how far the relatives scope reaches unless told
+// otherwise: parents, children, spouses, siblings, grandparents,
+// grandchildren and in-laws
+const DefaultDistance = 2
+
+// Selection picks the people to export. With no roots everyone is exported.
+// With roots, Scope chooses their ancestors, their descendants (with the
+// descendants' spouses, so every child's other parent is present), both, or
+// their close relatives: everyone within Distance parent, child or spouse
+// links of a root.
+type Selection struct {
+	Roots         []uuid.UUID
+	Scope         string
+	Distance      int
+	ExcludeLiving bool
+}
+
+// Validate checks the scope and fills in the defaults
+func (s *Selection) Validate() error {
+	if s.Scope == "" {
+		s.Scope = ScopeBoth
+	}
+	if s.Distance < 0 {
+		return fmt.Errorf("distance must be positive")
+	}
+	switch s.Scope {
+	case ScopeRelatives:
+		if s.Distance == 0 {
+			s.Distance = DefaultDistance
+		}
+		return nil
+	case ScopeAncestors, ScopeDescendants, ScopeBoth:
+		return nil
+	}
+	return fmt.Errorf("scope must be %s, %s, %s or %s", ScopeAncestors, ScopeDescendants, ScopeBoth, ScopeRelatives)
+}
+
+// Dataset is the part of the family tree being exported. Relationships,
+// events and notes only involve people in the dataset.
+type Dataset struct {
+	People        []models.Person
+	Relationships []models.Relationship
+	Events        []models.Event
+	Notes         []models.Note
+	Media         []models.Media
+	// MediaTags links media to the people who appear in them
+	MediaTags []models.MediaTag
+	// ExternalIDs lists the identifiers other programs use for people and
+	// events, by person or event ID
+	ExternalIDs map[uuid.UUID][]string
+
+	// localIDUses counts the rows each document-local ID ("#<id>") is
+	// mapped to. Imports from different sources can reuse the same ID.
+	localIDUses map[string]int
+
+	parents  map[uuid.UUID][]uuid.UUID
+	children map[uuid.UUID][]uuid.UUID
+	spouses  map[uuid.UUID][]uuid.UUID
+}
+
+// ErrRootNotFound is returned when a selection root is not a person
+type ErrRootNotFound struct {
+	ID uuid.UUID
+}
+
+func (e *ErrRootNotFound) Error() string {
+	return "person " + e.ID.String() + " not found"
+}
+
+// Load reads the selected people and everything attached to them
+func Load(db *sql.DB, sel Selection) (*Dataset, error) {
+	all, err := loadAll(db)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[uuid.UUID]models.Person{}
+	for _, p := range all.People {
+		byID[p.ID] = p
+	}
+
+	keep := map[uuid.UUID]bool{}
+	if len(sel.Roots) == 0 {
+		for id := range byID {
+			keep[id] = true
+		}
+	} else {
+		for _, root := range sel.Roots {
+			if _, ok := byID[root]; !ok {
+				return nil, &ErrRootNotFound{root}
+			}
+			keep[root] = true
+			if sel.Scope == ScopeRelatives {
+				for id := range all.near(root, sel.Distance) {
+					keep[id] = true
+				}
+				continue
+			}
+			if sel.Scope == ScopeAncestors || sel.Scope == ScopeBoth {
+				ancestors := map[uuid.UUID]bool{root: true}
+				all.walk(root, all.parents, ancestors)
+				for id := range ancestors {
+					keep[id] = true
+				}
+			}
+			if sel.Scope == ScopeDescendants || sel.Scope == ScopeBoth {
+				descendants := map[uuid.UUID]bool{root: true}
+				all.walk(root, all.children, descendants)
+				for id := range descendants {
+					keep[id] = true
+					for _, spouse := range all.spouses[id] {
+						keep[spouse] = true
+					}
+				}
+			}
+		}
+	}
+
+	// Living people are left out along with any photo or document showing
+	// them, even when it also shows someone who is exported
+	hidden := map[uuid.UUID]bool{}
+	if sel.ExcludeLiving {
+		for id, p := range byID {
+			if p.IsLiving {
+				hidden[id] = true
+				delete(keep, id)
+			}
+		}
+	}
+
+	return all.filter(keep, hidden), nil
+}
+
+// walk adds everyone reachable from id through next to seen
+func (d *Dataset) walk(id uuid.UUID, next map[uuid.UUID][]uuid.UUID, seen map[uuid.UUID]bool) {
+	queue := []uuid.UUID{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, other := range next[current] {
+			if !seen[other] {
+				seen[other] = true
+				queue = append(queue, other)
+			}
+		}
+	}
+}
+
+// near returns everyone within distance parent, child or spouse links of id
+func (d *Dataset) near(id uuid.UUID, distance int) map[uuid.UUID]bool {
+	seen := map[uuid.UUID]bool{id: true}
+	level := []uuid.UUID{id}
+	for step := 0; step < distance && len(level) > 0; step++ {
+		var next []uuid.UUID
+		for _, current := range level {
+			for _, links := range [][]uuid.UUID{d.parents[current], d.children[current], d.spouses[current]} {
+				for _, other := range links {
+					if !seen[other] {
+						seen[other] = true
+						next = append(next, other)
+					}
+				}
+			}
+		}
+		level = next
+	}
+	return seen
+}
+
+// filter returns the part of the dataset involving only the kept people.
+// Media filed under or showing a hidden person is dropped.
+func (d *Dataset) filter(keep, hidden map[uuid.UUID]bool) *Dataset {
+	out := &Dataset{ExternalIDs: d.ExternalIDs, localIDUses: d.localIDUses}
+	for _, p := range d.People {
+		if keep[p.ID] {
+			out.People = append(out.People, p)
+		}
+	}
+	for _, r := range d.Relationships {
+		if keep[r.Person1ID] && keep[r.Person2ID] {
+			out.Relationships = append(out.Relationships, r)
+		}
+	}
+	for _, e := range d.Events {
+		if keep[e.PersonID] {
+			out.Events = append(out.Events, e)
+		}
+	}
+	for _, n := range d.Notes {
+		if keep[n.PersonID] {
+			out.Notes = append(out.Notes, n)
+		}
+	}
+
+	// Media filed under, or showing, a kept person or one of their events
+	keptEvents := map[string]bool{}
+	for _, e := range out.Events {
+		keptEvents[e.ID.String()] = true
+	}
+	hiddenEvents := map[string]bool{}
+	for _, e := range d.Events {
+		if hidden[e.PersonID] {
+			hiddenEvents[e.ID.String()] = true
+		}
+	}
+	tagged := map[uuid.UUID]bool{}
+	showsHidden := map[uuid.UUID]bool{}
+	for _, t := range d.MediaTags {
+		if keep[t.PersonID] {
+			tagged[t.MediaID] = true
+		}
+		if hidden[t.PersonID] {
+			showsHidden[t.MediaID] = true
+		}
+	}
+	keptMedia := map[uuid.UUID]bool{}
+	for _, m := range d.Media {
+		owner, err := uuid.Parse(m.PersonID.String)
+		if (err == nil && hidden[owner]) || hiddenEvents[m.EventID.String] || showsHidden[m.ID] {
+			continue
+		}
+		if (err == nil && keep[owner]) || keptEvents[m.EventID.String] || tagged[m.ID] {
+			keptMedia[m.ID] = true
+			out.Media = append(out.Media, m)
+		}
+	}
+	for _, t := range d.MediaTags {
+		if keep[t.PersonID] && keptMedia[t.MediaID] {
+			out.MediaTags = append(out.MediaTags, t)
+		}
+	}
+
+	out.index()
+	return out
+}
+
+// index builds the parent, child and spouse lookups. Parent links are stored
+// either as ('child', child -> parent) or ('parent', parent -> child).
+func (d *Dataset) index() {
+	d.parents = map[uuid.UUID][]uuid.UUID{}
+	d.children = map[uuid.UUID][]uuid.UUID{}
+	d.spouses = map[uuid.UUID][]uuid.UUID{}
+
+	seen := map[string]bool{}
+	addParent := func(child, parent uuid.UUID) {
+		key := child.String() + parent.String()
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		d.parents[child] = append(d.parents[child], parent)
+		d.children[parent] = append(d.children[parent], child)
+	}
+
+	for _, r := range d.Relationships {
+		switch r.RelationshipType {
+		case models.RelationshipChild:
+			addParent(r.Person1ID, r.Person2ID)
+		case models.RelationshipParent:
+			addParent(r.Person2ID, r.Person1ID)
+		case models.RelationshipSpouse:
+			d.spouses[r.Person1ID] = append(d.spouses[r.Person1ID], r.Person2ID)
+			d.spouses[r.Person2ID] = append(d.spouses[r.Person2ID], r.Person1ID)
+		}
+	}
+}
+
+// Parents returns the parents of a person in the dataset
+func (d *Dataset) Parents(id uuid.UUID) []uuid.UUID {
+	return d.parents[id]
+}
+
+// Children returns the children of a person in the dataset
+func (d *Dataset) Children(id uuid.UUID) []uuid.UUID {
+	return d.children[id]
+}
+
+// loadAll reads the whole tree
+func loadAll(db *sql.DB) (*Dataset, error) {
+	d := &Dataset{}
+
+	rows, err := db.Query(`
+		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
+		FROM people
+		ORDER BY last_name, first_name, birth_date
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p models.Person
+		err := rows.Scan(
+			&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.People = append(d.People, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, person1_id, person2_id, relationship_type, start_date, end_date, notes, created_at, updated_at
+		FROM relationships
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var r models.Relationship
+		err := rows.Scan(&r.ID, &r.Person1ID, &r.Person2ID, &r.RelationshipType,
+			&r.StartDate, &r.EndDate, &r.Notes, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Relationships = append(d.Relationships, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, person_id, event_type, event_date, event_place, description, created_at, updated_at
+		FROM events
+		ORDER BY event_date NULLS LAST, created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var e models.Event
+		err := rows.Scan(&e.ID, &e.PersonID, &e.EventType, &e.EventDate, &e.EventPlace,
+			&e.Description, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Events = append(d.Events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, person_id, content, created_by, updated_by, created_at, updated_at
+		FROM notes
+		WHERE deleted_at IS NULL
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var n models.Note
+		err := rows.Scan(&n.ID, &n.PersonID, &n.Content, &n.CreatedBy, &n.UpdatedBy, &n.CreatedAt, &n.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Notes = append(d.Notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, person_id, event_id, file_path, file_type, title, description,
+			original_name, content_type, file_size, uploaded_by, taken_at, latitude, longitude, upload_date
+		FROM media
+		ORDER BY COALESCE(taken_at, upload_date)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m models.Media
+		err := rows.Scan(&m.ID, &m.PersonID, &m.EventID, &m.FilePath, &m.FileType, &m.Title, &m.Description,
+			&m.OriginalName, &m.ContentType, &m.FileSize, &m.UploadedBy, &m.TakenAt, &m.Latitude, &m.Longitude, &m.UploadDate)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Media = append(d.Media, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT media_id, person_id, created_at FROM media_people ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t models.MediaTag
+		if err := rows.Scan(&t.MediaID, &t.PersonID, &t.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.MediaTags = append(d.MediaTags, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	d.ExternalIDs = map[uuid.UUID][]string{}
+	d.localIDUses = map[string]int{}
+	rows, err = db.Query(`
+		SELECT entity_id, external_id, MIN(created_at) AS created_at
+		FROM external_ids
+		GROUP BY entity_id, external_id
+		ORDER BY created_at, external_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var externalID string
+		var createdAt sql.NullTime
+		if err := rows.Scan(&id, &externalID, &createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.ExternalIDs[id] = append(d.ExternalIDs[id], externalID)
+		if strings.HasPrefix(externalID, "#") {
+			d.localIDUses[externalID]++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	d.index()
+	return d, nil
+}
@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"bufio"
+	"farmily/app/models"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// chartColors fill people's boxes by gender
+var chartColors = map[string]string{
+	models.GenderMale:   "#dbeafe",
+	models.GenderFemale: "#fce7f3",
+	models.GenderOther:  "#ede9fe",
+}
+
+// DOT writes the dataset as a Graphviz graph. Couples are joined through a
+// family point their children hang from, and each generation is ranked on
+// its own row, so `dot -Tsvg` or `dot -Tpdf` draws a family chart.
+func DOT(w io.Writer, d *Dataset) error {
+	bw := bufio.NewWriter(w)
+	gen := d.Generations()
+
+	bw.WriteString("digraph family {\n")
+	bw.WriteString("\tgraph [rankdir=TB, splines=ortho, nodesep=0.3, ranksep=0.6];\n")
+	bw.WriteString("\tnode [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\", fontsize=10];\n")
+	bw.WriteString("\tedge [dir=none, color=\"#555555\"];\n\n")
+
+	rows := map[int][]string{}
+	rowCount := 0
+	for i := range d.People {
+		p := &d.People[i]
+		label := p.GetDisplayName()
+		if dates := chartDates(p); dates != "" {
+			label += "\n" + dates
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s, fillcolor=%s];\n", dotID(p.ID), dotString(label), dotString(chartColors[p.Gender]))
+
+		g := gen[p.ID]
+		rows[g] = append(rows[g], dotID(p.ID))
+		if g+1 > rowCount {
+			rowCount = g + 1
+		}
+	}
+	bw.WriteString("\n")
+
+	for i, f := range d.Families() {
+		from := dotID(f.Partners[0])
+		if len(f.Partners) == 2 {
+			// The family point sits between the partners on their row
+			from = fmt.Sprintf("F%d", i+1)
+			fmt.Fprintf(bw, "\t%s [shape=point, width=0.05, label=\"\"];\n", from)
+			fmt.Fprintf(bw, "\t%s -> %s -> %s [weight=10];\n", dotID(f.Partners[0]), from, dotID(f.Partners[1]))
+			if g := gen[f.Partners[0]]; g == gen[f.Partners[1]] {
+				rows[g] = append(rows[g], from)
+			}
+		}
+		for _, child := range f.Children {
+			fmt.Fprintf(bw, "\t%s -> %s;\n", from, dotID(child))
+		}
+	}
+	bw.WriteString("\n")
+
+	for g := 0; g < rowCount; g++ {
+		if len(rows[g]) > 0 {
+			fmt.Fprintf(bw, "\t{ rank=same; %s; }\n", strings.Join(rows[g], "; "))
+		}
+	}
+
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func dotID(id uuid.UUID) string {
+	return `"` + id.String() + `"`
+}
+
+// dotString quotes a DOT string, keeping line breaks as \n
+func dotString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"farmily/app/models"
+
+	"github.com/google/uuid"
+)
+
+// Family is a couple, or a single parent, and their children: the unit
+// GEDCOM links people through
+type Family struct {
+	// Partners holds one or two people, husband first where genders allow
+	Partners []uuid.UUID
+	Children []uuid.UUID
+	// Marriage is the spouse relationship between the partners, if any
+	Marriage *models.Relationship
+}
+
+// Families groups the dataset's parent and spouse links into families. A
+// child whose two parents are known belongs to that couple's family; a child
+// with one known parent to that parent's single-parent family.
+func (d *Dataset) Families() []*Family {
+	gender := map[uuid.UUID]string{}
+	for _, p := range d.People {
+		gender[p.ID] = p.Gender
+	}
+
+	var families []*Family
+	byKey := map[string]*Family{}
+	get := func(partners ...uuid.UUID) *Family {
+		if len(partners) == 2 && partners[1].String() < partners[0].String() {
+			partners[0], partners[1] = partners[1], partners[0]
+		}
+		key := ""
+		for _, p := range partners {
+			key += p.String()
+		}
+		if f, ok := byKey[key]; ok {
+			return f
+		}
+
+		f := &Family{Partners: append([]uuid.UUID(nil), partners...)}
+		if len(f.Partners) == 2 && (gender[f.Partners[1]] == models.GenderMale && gender[f.Partners[0]] != models.GenderMale ||
+			gender[f.Partners[0]] == models.GenderFemale && gender[f.Partners[1]] != models.GenderFemale) {
+			f.Partners[0], f.Partners[1] = f.Partners[1], f.Partners[0]
+		}
+		byKey[key] = f
+		families = append(families, f)
+		return f
+	}
+
+	for i := range d.Relationships {
+		r := &d.Relationships[i]
+		if r.RelationshipType != models.RelationshipSpouse {
+			continue
+		}
+		if f := get(r.Person1ID, r.Person2ID); f.Marriage == nil {
+			f.Marriage = r
+		}
+	}
+
+	isCouple := func(a, b uuid.UUID) bool {
+		for _, s := range d.spouses[a] {
+			if s == b {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, p := range d.People {
+		parents := d.parents[p.ID]
+		switch len(parents) {
+		case 0:
+		case 1:
+			f := get(parents[0])
+			f.Children = append(f.Children, p.ID)
+		case 2:
+			f := get(parents[0], parents[1])
+			f.Children = append(f.Children, p.ID)
+		default:
+			// More than two parents (e.g. birth and step-parents): pair the
+			// married ones, the rest are single parents
+			used := map[uuid.UUID]bool{}
+			for i, a := range parents {
+				for _, b := range parents[i+1:] {
+					if !used[a] && !used[b] && isCouple(a, b) {
+						f := get(a, b)
+						f.Children = append(f.Children, p.ID)
+						used[a], used[b] = true, true
+					}
+				}
+			}
+			for _, a := range parents {
+				if !used[a] {
+					f := get(a)
+					f.Children = append(f.Children, p.ID)
+				}
+			}
+		}
+	}
+
+	return families
+}
@@ -0,0 +1,511 @@
+package exporter
+
+import (
+	"database/sql"
+	"farmily/app/gedcom"
+	"farmily/app/models"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// individualTags maps event types to INDI event tags. Marriages and divorces
+// belong to families and are handled separately.
+var individualTags = map[string]string{
+	models.EventBirth:      "BIRT",
+	models.EventDeath:      "DEAT",
+	models.EventEmployment: "OCCU",
+	models.EventGraduation: "GRAD",
+	models.EventRetirement: "RETI",
+	models.EventOther:      "EVEN",
+}
+
+var eventTypeLabels = map[string]string{
+	models.EventMarriage: "Marriage",
+	models.EventDivorce:  "Divorce",
+	models.EventOther:    "Other",
+}
+
+// GEDCOMOptions controls the GEDCOM writer
+type GEDCOMOptions struct {
+	// Version is gedcom.Version551 (the default) or gedcom.Version70
+	Version string
+	// Submitter names the person making the export
+	Submitter string
+	// MediaPaths maps media IDs to the FILE path written for them. Media
+	// missing from the map are left out; only GEDCOM 7 output lists media.
+	MediaPaths map[uuid.UUID]string
+}
+
+// gedcomExport builds GEDCOM records from a dataset
+type gedcomExport struct {
+	d    *Dataset
+	opts GEDCOMOptions
+	v7   bool
+
+	people   map[uuid.UUID]*models.Person
+	xrefs    map[uuid.UUID]string
+	families []*Family
+	famXrefs map[*Family]string
+
+	// Person events merged into a family's MARR or DIV
+	merged map[uuid.UUID]bool
+	// Places taken from those events
+	places map[*Family]map[string]string
+
+	mediaXrefs map[uuid.UUID]string
+}
+
+// GEDCOM writes the dataset as a GEDCOM 5.5.1 or 7.0 file
+func GEDCOM(w io.Writer, d *Dataset, opts GEDCOMOptions) error {
+	if opts.Version == "" {
+		opts.Version = gedcom.Version551
+	}
+
+	g := &gedcomExport{
+		d:          d,
+		opts:       opts,
+		v7:         opts.Version == gedcom.Version70,
+		mediaXrefs: map[uuid.UUID]string{},
+		people:     map[uuid.UUID]*models.Person{},
+		xrefs:      map[uuid.UUID]string{},
+		famXrefs:   map[*Family]string{},
+		merged:     map[uuid.UUID]bool{},
+		places:     map[*Family]map[string]string{},
+	}
+
+	for i := range d.People {
+		p := &d.People[i]
+		g.people[p.ID] = p
+		g.xrefs[p.ID] = fmt.Sprintf("@I%d@", i+1)
+	}
+	g.families = d.Families()
+	for i, f := range g.families {
+		g.famXrefs[f] = fmt.Sprintf("@F%d@", i+1)
+	}
+	g.mergeFamilyEvents()
+
+	if g.v7 {
+		n := 0
+		for _, m := range d.Media {
+			if _, ok := opts.MediaPaths[m.ID]; ok {
+				n++
+				g.mediaXrefs[m.ID] = fmt.Sprintf("@O%d@", n)
+			}
+		}
+	}
+
+	header := gedcom.NewRecord("HEAD", "")
+	if g.v7 {
+		header.Add("GEDC", "").Add("VERS", gedcom.Version70)
+	}
+	sour := header.Add("SOUR", "FARMILY")
+	sour.Add("NAME", "Farmily Tree")
+	header.Add("DATE", gedcom.FormatDate(time.Now()))
+	header.Add("SUBM", "@U1@")
+	if !g.v7 {
+		gedc := header.Add("GEDC", "")
+		gedc.Add("VERS", gedcom.Version551)
+		gedc.Add("FORM", "LINEAGE-LINKED")
+		header.Add("CHAR", "UTF-8")
+	}
+
+	var records []*gedcom.Record
+	subm := &gedcom.Record{XRef: "@U1@", Tag: "SUBM"}
+	subm.Add("NAME", opts.Submitter)
+	records = append(records, subm)
+
+	for i := range d.People {
+		records = append(records, g.individual(&d.People[i]))
+	}
+	for _, f := range g.families {
+		records = append(records, g.family(f))
+	}
+	for i := range d.Media {
+		if _, ok := g.mediaXrefs[d.Media[i].ID]; ok {
+			records = append(records, g.object(&d.Media[i]))
+		}
+	}
+
+	return gedcom.EncodeVersion(w, opts.Version, header, records)
+}
+
+// mergeFamilyEvents matches marriage and divorce events recorded on a person
+// to their family by date, so they become the family's MARR and DIV instead
+// of being repeated on each spouse
+func (g *gedcomExport) mergeFamilyEvents() {
+	for _, e := range g.d.Events {
+		if e.EventType != models.EventMarriage && e.EventType != models.EventDivorce {
+			continue
+		}
+		for _, f := range g.families {
+			if f.Marriage == nil || !hasPartner(f, e.PersonID) {
+				continue
+			}
+			date := f.Marriage.StartDate
+			tag := "MARR"
+			if e.EventType == models.EventDivorce {
+				date, tag = f.Marriage.EndDate, "DIV"
+			}
+			if !sameDate(date, e.EventDate) {
+				continue
+			}
+
+			g.merged[e.ID] = true
+			if e.EventPlace.Valid {
+				if g.places[f] == nil {
+					g.places[f] = map[string]string{}
+				}
+				if g.places[f][tag] == "" {
+					g.places[f][tag] = e.EventPlace.String
+				}
+			}
+			break
+		}
+	}
+}
+
+func (g *gedcomExport) individual(p *models.Person) *gedcom.Record {
+	rec := &gedcom.Record{XRef: g.xrefs[p.ID], Tag: "INDI"}
+
+	given := strings.TrimSpace(p.FirstName + " " + p.MiddleName.String)
+
+	// The birth name comes first; a maiden name means the last name is a married one
+	birthSurname := p.LastName
+	if p.MaidenName.Valid && p.MaidenName.String != "" {
+		birthSurname = p.MaidenName.String
+	}
+	name := rec.Add("NAME", gedcom.FormatName(given, birthSurname))
+	name.AddIf("GIVN", given)
+	name.AddIf("SURN", birthSurname)
+	if birthSurname != p.LastName {
+		married := rec.Add("NAME", gedcom.FormatName(given, p.LastName))
+		if g.v7 {
+			married.Add("TYPE", "MARRIED")
+		} else {
+			married.Add("TYPE", "married")
+		}
+		married.AddIf("GIVN", given)
+		married.AddIf("SURN", p.LastName)
+	}
+
+	switch p.Gender {
+	case models.GenderMale:
+		rec.Add("SEX", "M")
+	case models.GenderFemale:
+		rec.Add("SEX", "F")
+	default:
+		if g.v7 {
+			rec.Add("SEX", "X")
+		} else {
+			rec.Add("SEX", "U")
+		}
+	}
+
+	events := g.eventsOf(p.ID)
+
+	// Birth and death from the people row, merged with a matching event
+	birth := gedcom.NewRecord("BIRT", "")
+	addDatePlace(birth, p.BirthDate, p.BirthPlace)
+	events = g.mergeVital(birth, events, models.EventBirth, p.BirthDate)
+	if len(birth.Children) > 0 {
+		rec.Children = append(rec.Children, birth)
+	}
+
+	if !p.IsLiving || p.DeathDate.Valid {
+		death := rec.Add("DEAT", "")
+		addDatePlace(death, p.DeathDate, p.DeathPlace)
+		events = g.mergeVital(death, events, models.EventDeath, p.DeathDate)
+		if len(death.Children) == 0 {
+			death.Value = "Y"
+		}
+	}
+
+	// Media of events written below go with the event, the rest with the person
+	attached := map[uuid.UUID]bool{}
+
+	occupationListed := false
+	for _, e := range events {
+		eventRec := g.event(e)
+		for _, m := range g.d.Media {
+			if m.EventID.String == e.ID.String() && g.mediaXrefs[m.ID] != "" {
+				eventRec.Add("OBJE", g.mediaXrefs[m.ID])
+				attached[m.ID] = true
+			}
+		}
+		rec.Children = append(rec.Children, eventRec)
+		if e.EventType == models.EventEmployment && e.Description.String == p.Occupation.String {
+			occupationListed = true
+		}
+	}
+	if p.Occupation.Valid && p.Occupation.String != "" && !occupationListed {
+		rec.Add("OCCU", clipText(p.Occupation.String, 90))
+	}
+
+	if p.Biography.Valid && strings.TrimSpace(p.Biography.String) != "" {
+		rec.Add("NOTE", p.Biography.String)
+	}
+	for _, n := range g.d.Notes {
+		if n.PersonID == p.ID {
+			rec.Add("NOTE", n.Content)
+		}
+	}
+
+	for _, f := range g.families {
+		for _, child := range f.Children {
+			if child == p.ID {
+				rec.Add("FAMC", g.famXrefs[f])
+			}
+		}
+	}
+	for _, f := range g.families {
+		if hasPartner(f, p.ID) {
+			rec.Add("FAMS", g.famXrefs[f])
+		}
+	}
+
+	for _, mediaID := range g.personMedia(p) {
+		if !attached[mediaID] {
+			rec.Add("OBJE", g.mediaXrefs[mediaID])
+		}
+	}
+
+	if g.v7 {
+		rec.Add("UID", p.ID.String())
+	} else {
+		refn := rec.Add("REFN", p.ID.String())
+		refn.Add("TYPE", "Farmily")
+	}
+
+	return rec
+}
+
+// personMedia returns the written media filed under or showing the person,
+// or attached to one of their events, with their profile photo first
+func (g *gedcomExport) personMedia(p *models.Person) []uuid.UUID {
+	tagged := map[uuid.UUID]bool{}
+	for _, t := range g.d.MediaTags {
+		if t.PersonID == p.ID {
+			tagged[t.MediaID] = true
+		}
+	}
+	events := map[string]bool{}
+	for _, e := range g.d.Events {
+		if e.PersonID == p.ID {
+			events[e.ID.String()] = true
+		}
+	}
+
+	var ids []uuid.UUID
+	if p.ProfileMediaID.Valid && g.mediaXrefs[p.ProfileMediaID.UUID] != "" {
+		ids = append(ids, p.ProfileMediaID.UUID)
+	}
+	for _, m := range g.d.Media {
+		if g.mediaXrefs[m.ID] == "" || (p.ProfileMediaID.Valid && m.ID == p.ProfileMediaID.UUID) {
+			continue
+		}
+		if m.PersonID.String == p.ID.String() || tagged[m.ID] || events[m.EventID.String] {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// mediaKinds maps media types to GEDCOM 7 MEDI values
+var mediaKinds = map[string]string{
+	models.MediaImage:    "PHOTO",
+	models.MediaVideo:    "VIDEO",
+	models.MediaDocument: "ELECTRONIC",
+}
+
+// object writes a GEDCOM 7 multimedia record for a media file
+func (g *gedcomExport) object(m *models.Media) *gedcom.Record {
+	rec := &gedcom.Record{XRef: g.mediaXrefs[m.ID], Tag: "OBJE"}
+
+	file := rec.Add("FILE", g.opts.MediaPaths[m.ID])
+	form := file.Add("FORM", mediaType(m))
+	form.AddIf("MEDI", mediaKinds[m.FileType])
+
+	title := m.Title.String
+	if title == "" {
+		title = m.OriginalName.String
+	}
+	file.AddIf("TITL", title)
+
+	if m.Description.Valid && strings.TrimSpace(m.Description.String) != "" {
+		rec.Add("NOTE", m.Description.String)
+	}
+	return rec
+}
+
+// mediaType returns the file's media type, guessing from its extension for
+// files uploaded before content types were recorded
+func mediaType(m *models.Media) string {
+	if m.ContentType.Valid && m.ContentType.String != "" {
+		return m.ContentType.String
+	}
+	if t := mime.TypeByExtension(path.Ext(m.FilePath)); t != "" {
+		t, _, _ = strings.Cut(t, ";")
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// eventsOf returns the person's events that aren't merged into a family
+func (g *gedcomExport) eventsOf(personID uuid.UUID) []*models.Event {
+	var out []*models.Event
+	for i := range g.d.Events {
+		e := &g.d.Events[i]
+		if e.PersonID == personID && !g.merged[e.ID] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// mergeVital folds the first birth or death event on the same date as the
+// people row, or any date if the row has none, into rec and returns the
+// remaining events
+func (g *gedcomExport) mergeVital(rec *gedcom.Record, events []*models.Event, eventType string, date sql.NullTime) []*models.Event {
+	for i, e := range events {
+		if e.EventType != eventType || (date.Valid && !sameDate(e.EventDate, date)) {
+			continue
+		}
+		if rec.First("DATE") == nil && e.EventDate.Valid {
+			rec.Add("DATE", gedcom.FormatDate(e.EventDate.Time))
+		}
+		if rec.First("PLAC") == nil && e.EventPlace.Valid {
+			rec.Add("PLAC", e.EventPlace.String)
+		}
+		if e.Description.Valid && e.Description.String != "" {
+			rec.Add("NOTE", e.Description.String)
+		}
+		return append(events[:i:i], events[i+1:]...)
+	}
+	return events
+}
+
+func (g *gedcomExport) event(e *models.Event) *gedcom.Record {
+	tag, ok := individualTags[e.EventType]
+	if !ok {
+		tag = "EVEN"
+	}
+
+	rec := gedcom.NewRecord(tag, "")
+	description := strings.TrimSpace(e.Description.String)
+
+	switch tag {
+	case "OCCU":
+		// The occupation is the value; long descriptions go in a note
+		rec.Value = "Employment"
+		if description != "" {
+			rec.Value = clipText(description, 90)
+			if rec.Value == description {
+				description = ""
+			}
+		}
+	case "EVEN":
+		label := eventTypeLabels[e.EventType]
+		if description != "" && !strings.Contains(description, "\n") && utf8.RuneCountInString(description) <= 90 {
+			label, description = description, ""
+		}
+		rec.Add("TYPE", label)
+	}
+
+	addDatePlace(rec, e.EventDate, e.EventPlace)
+	if description != "" {
+		rec.Add("NOTE", description)
+	}
+	if len(rec.Children) == 0 && rec.Value == "" && tag != "EVEN" {
+		rec.Value = "Y"
+	}
+
+	return rec
+}
+
+func (g *gedcomExport) family(f *Family) *gedcom.Record {
+	rec := &gedcom.Record{XRef: g.famXrefs[f], Tag: "FAM"}
+
+	for i, partner := range f.Partners {
+		tag := "HUSB"
+		if i == 1 || (len(f.Partners) == 1 && g.people[partner].Gender == models.GenderFemale) {
+			tag = "WIFE"
+		}
+		rec.Add(tag, g.xrefs[partner])
+	}
+	for _, child := range f.Children {
+		rec.Add("CHIL", g.xrefs[child])
+	}
+
+	if m := f.Marriage; m != nil {
+		marr := rec.Add("MARR", "")
+		addDatePlace(marr, m.StartDate, placeOf(g.places[f]["MARR"]))
+		if len(marr.Children) == 0 {
+			marr.Value = "Y"
+		}
+
+		if m.EndDate.Valid && !g.widowed(f, m.EndDate.Time) {
+			div := rec.Add("DIV", "")
+			addDatePlace(div, m.EndDate, placeOf(g.places[f]["DIV"]))
+		}
+
+		if m.Notes.Valid && strings.TrimSpace(m.Notes.String) != "" {
+			rec.Add("NOTE", m.Notes.String)
+		}
+	}
+
+	return rec
+}
+
+// widowed reports whether a marriage ending on date ended with a partner's death
+func (g *gedcomExport) widowed(f *Family, date time.Time) bool {
+	for _, partner := range f.Partners {
+		if p := g.people[partner]; p.DeathDate.Valid && p.DeathDate.Time.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPartner(f *Family, id uuid.UUID) bool {
+	for _, p := range f.Partners {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameDate(a, b sql.NullTime) bool {
+	if a.Valid != b.Valid {
+		return false
+	}
+	return !a.Valid || a.Time.Equal(b.Time)
+}
+
+func addDatePlace(rec *gedcom.Record, date sql.NullTime, place sql.NullString) {
+	if date.Valid {
+		rec.Add("DATE", gedcom.FormatDate(date.Time))
+	}
+	if place.Valid && place.String != "" {
+		rec.Add("PLAC", place.String)
+	}
+}
+
+func placeOf(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// clipText shortens s to at most n characters
+func clipText(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,216 @@
+package exporter
+
+import (
+	"farmily/app/gedcomx"
+	"farmily/app/models"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// factTypes maps event types to GEDCOM X fact types
+var factTypes = map[string]string{
+	models.EventBirth:      gedcomx.FactBirth,
+	models.EventDeath:      gedcomx.FactDeath,
+	models.EventMarriage:   gedcomx.FactMarriage,
+	models.EventDivorce:    gedcomx.FactDivorce,
+	models.EventGraduation: gedcomx.FactEducation,
+	models.EventEmployment: gedcomx.FactOccupation,
+	models.EventRetirement: gedcomx.FactRetirement,
+	models.EventOther:      gedcomx.FactOther,
+}
+
+// GEDCOMX writes the dataset as a GEDCOM X JSON document. People and events
+// keep the ID an earlier import gave them, or else use their UUID, and list
+// "urn:uuid:<id>" and any mapped identifiers, so importing the document again
+// updates the same rows.
+//
+// A person's birth, death and occupation columns are written as facts
+// without IDs, unless an event with the same details stands in for them.
+func GEDCOMX(w io.Writer, d *Dataset) error {
+	doc := &gedcomx.Document{
+		Attribution: &gedcomx.Attribution{Modified: time.Now().UnixMilli()},
+	}
+
+	docIDs := map[uuid.UUID]string{}
+	for _, p := range d.People {
+		docIDs[p.ID] = d.docID(p.ID)
+	}
+
+	events := map[uuid.UUID][]*models.Event{}
+	for i := range d.Events {
+		e := &d.Events[i]
+		events[e.PersonID] = append(events[e.PersonID], e)
+	}
+
+	for i := range d.People {
+		doc.Persons = append(doc.Persons, d.gedcomxPerson(&d.People[i], docIDs[d.People[i].ID], events[d.People[i].ID]))
+	}
+
+	for _, r := range d.Relationships {
+		rel := gedcomx.Relationship{
+			ID:          r.ID.String(),
+			Identifiers: gedcomx.Identifiers{gedcomx.IdentifierPersistent: {"urn:uuid:" + r.ID.String()}},
+		}
+		switch r.RelationshipType {
+		case models.RelationshipSpouse:
+			rel.Type = gedcomx.RelationshipCouple
+			rel.Person1, rel.Person2 = gedcomx.Ref(docIDs[r.Person1ID]), gedcomx.Ref(docIDs[r.Person2ID])
+			if r.StartDate.Valid {
+				rel.Facts = append(rel.Facts, gedcomx.Fact{Type: gedcomx.FactMarriage, Date: gedcomx.NewDate(r.StartDate.Time)})
+			}
+			if r.EndDate.Valid {
+				rel.Facts = append(rel.Facts, gedcomx.Fact{Type: gedcomx.FactDivorce, Date: gedcomx.NewDate(r.EndDate.Time)})
+			}
+		case models.RelationshipChild:
+			rel.Type = gedcomx.RelationshipParentChild
+			rel.Person1, rel.Person2 = gedcomx.Ref(docIDs[r.Person2ID]), gedcomx.Ref(docIDs[r.Person1ID])
+		case models.RelationshipParent:
+			rel.Type = gedcomx.RelationshipParentChild
+			rel.Person1, rel.Person2 = gedcomx.Ref(docIDs[r.Person1ID]), gedcomx.Ref(docIDs[r.Person2ID])
+		default:
+			// Siblings follow from shared parents
+			continue
+		}
+		doc.Relationships = append(doc.Relationships, rel)
+	}
+
+	return gedcomx.Encode(w, doc)
+}
+
+// docID returns the ID a person or event is written under: the document ID
+// it was imported with, if no other row was imported with the same one, or
+// else its UUID
+func (d *Dataset) docID(id uuid.UUID) string {
+	for _, externalID := range d.ExternalIDs[id] {
+		if local, ok := strings.CutPrefix(externalID, "#"); ok && d.localIDUses[externalID] == 1 {
+			return local
+		}
+	}
+	return id.String()
+}
+
+// identifiers lists a row's UUID and the identifiers other programs use for it
+func (d *Dataset) identifiers(id uuid.UUID) gedcomx.Identifiers {
+	values := []string{"urn:uuid:" + id.String()}
+	for _, externalID := range d.ExternalIDs[id] {
+		if !strings.HasPrefix(externalID, "#") {
+			values = append(values, externalID)
+		}
+	}
+	return gedcomx.Identifiers{gedcomx.IdentifierPersistent: values}
+}
+
+func (d *Dataset) gedcomxPerson(p *models.Person, docID string, events []*models.Event) gedcomx.Person {
+	living := p.IsLiving
+	person := gedcomx.Person{
+		ID:          docID,
+		Identifiers: d.identifiers(p.ID),
+		Living:      &living,
+	}
+
+	switch p.Gender {
+	case models.GenderMale:
+		person.Gender = &gedcomx.Gender{Type: gedcomx.GenderMale}
+	case models.GenderFemale:
+		person.Gender = &gedcomx.Gender{Type: gedcomx.GenderFemale}
+	default:
+		person.Gender = &gedcomx.Gender{Type: gedcomx.GenderUnknown}
+	}
+
+	given := strings.TrimSpace(p.FirstName + " " + p.MiddleName.String)
+	surname := p.LastName
+	if p.MaidenName.Valid && p.MaidenName.String != "" {
+		surname = p.MaidenName.String
+	}
+	person.Names = append(person.Names, gedcomxName(gedcomx.NameBirth, given, surname))
+	if surname != p.LastName {
+		person.Names = append(person.Names, gedcomxName(gedcomx.NameMarried, given, p.LastName))
+	}
+
+	// Vital facts come first, as the importer reads the first of each type
+	// into the person's columns
+	vital := func(factType, eventType string, matches func(*models.Event) bool, fact gedcomx.Fact) {
+		for i, e := range events {
+			if e.EventType == eventType && matches(e) {
+				person.Facts = append(person.Facts, d.gedcomxFact(e))
+				events = append(events[:i:i], events[i+1:]...)
+				return
+			}
+		}
+		if fact.Date != nil || fact.Place != nil || fact.Value != "" {
+			fact.Type = factType
+			person.Facts = append(person.Facts, fact)
+		}
+	}
+
+	vital(gedcomx.FactBirth, models.EventBirth, func(e *models.Event) bool {
+		return sameDate(e.EventDate, p.BirthDate) && e.EventPlace.String == p.BirthPlace.String
+	}, gedcomx.Fact{Date: gedcomxDate(p.BirthDate.Valid, p.BirthDate.Time), Place: gedcomxPlace(p.BirthPlace.String)})
+	vital(gedcomx.FactDeath, models.EventDeath, func(e *models.Event) bool {
+		return sameDate(e.EventDate, p.DeathDate) && e.EventPlace.String == p.DeathPlace.String
+	}, gedcomx.Fact{Date: gedcomxDate(p.DeathDate.Valid, p.DeathDate.Time), Place: gedcomxPlace(p.DeathPlace.String)})
+	if p.Occupation.Valid && strings.TrimSpace(p.Occupation.String) != "" {
+		vital(gedcomx.FactOccupation, models.EventEmployment, func(e *models.Event) bool {
+			return strings.TrimSpace(e.Description.String) == strings.TrimSpace(p.Occupation.String)
+		}, gedcomx.Fact{Value: strings.TrimSpace(p.Occupation.String)})
+	}
+
+	for _, e := range events {
+		person.Facts = append(person.Facts, d.gedcomxFact(e))
+	}
+
+	if p.Biography.Valid && strings.TrimSpace(p.Biography.String) != "" {
+		person.Notes = append(person.Notes, gedcomx.Note{Subject: "Biography", Text: p.Biography.String})
+	}
+
+	return person
+}
+
+func (d *Dataset) gedcomxFact(e *models.Event) gedcomx.Fact {
+	factType, ok := factTypes[e.EventType]
+	if !ok {
+		factType = gedcomx.FactOther
+	}
+	return gedcomx.Fact{
+		ID:          d.docID(e.ID),
+		Identifiers: d.identifiers(e.ID),
+		Type:        factType,
+		Date:        gedcomxDate(e.EventDate.Valid, e.EventDate.Time),
+		Place:       gedcomxPlace(e.EventPlace.String),
+		Value:       strings.TrimSpace(e.Description.String),
+	}
+}
+
+func gedcomxName(nameType, given, surname string) gedcomx.Name {
+	var parts []gedcomx.NamePart
+	if given != "" {
+		parts = append(parts, gedcomx.NamePart{Type: gedcomx.PartGiven, Value: given})
+	}
+	if surname != "" {
+		parts = append(parts, gedcomx.NamePart{Type: gedcomx.PartSurname, Value: surname})
+	}
+	return gedcomx.Name{
+		Type: nameType,
+		NameForms: []gedcomx.NameForm{{
+			FullText: strings.TrimSpace(given + " " + surname),
+			Parts:    parts,
+		}},
+	}
+}
+
+func gedcomxDate(valid bool, t time.Time) *gedcomx.Date {
+	if !valid {
+		return nil
+	}
+	return gedcomx.NewDate(t)
+}
+
+func gedcomxPlace(place string) *gedcomx.PlaceReference {
+	if place == "" {
+		return nil
+	}
+	return &gedcomx.PlaceReference{Original: place}
+}
@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"farmily/app/gedcom"
+	"farmily/app/storage"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GEDZIP writes the dataset as a GEDZIP bundle: a GEDCOM 7.0 file named
+// gedcom.ged plus the dataset's media files under media/, read from backend.
+// Files missing from storage are left out along with their OBJE records.
+func GEDZIP(ctx context.Context, w io.Writer, d *Dataset, submitter string, backend storage.Backend) error {
+	zw := zip.NewWriter(w)
+
+	paths := map[uuid.UUID]string{}
+	written := map[string]bool{}
+	for _, m := range d.Media {
+		name := path.Join("media", m.FilePath)
+		if written[name] {
+			paths[m.ID] = name
+			continue
+		}
+
+		err := copyMedia(ctx, zw, backend, m.FilePath, name, m.UploadDate)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("gedzip: media %s missing from storage (%s), skipped", m.ID, m.FilePath)
+			continue
+		} else if err != nil {
+			return err
+		}
+		paths[m.ID] = name
+		written[name] = true
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "gedcom.ged",
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	err = GEDCOM(f, d, GEDCOMOptions{
+		Version:    gedcom.Version70,
+		Submitter:  submitter,
+		MediaPaths: paths,
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// copyMedia copies one stored file into the archive. Images and videos are
+// already compressed, so files are stored rather than deflated.
+func copyMedia(ctx context.Context, zw *zip.Writer, backend storage.Backend, key, name string, modified time.Time) error {
+	r, _, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
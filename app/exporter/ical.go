@@ -0,0 +1,183 @@
+package exporter
+
+import (
+	"bufio"
+	"farmily/app/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Calendar event kinds
+const (
+	CalendarBirthdays     = "birthdays"
+	CalendarAnniversaries = "anniversaries"
+	CalendarMemorials     = "memorials"
+)
+
+// CalendarKinds lists every calendar event kind
+var CalendarKinds = []string{CalendarBirthdays, CalendarAnniversaries, CalendarMemorials}
+
+// CalendarOptions controls a calendar feed
+type CalendarOptions struct {
+	// Name is the calendar's title in calendar apps
+	Name string
+	// Kinds picks the kinds of events listed; empty lists them all
+	Kinds []string
+}
+
+// calendarEvent is one yearly recurring event
+type calendarEvent struct {
+	uid         string
+	date        time.Time
+	summary     string
+	description string
+}
+
+// Calendar writes the dataset's yearly dates as an iCalendar (RFC 5545)
+// feed: the birthdays of living people, the wedding anniversaries of
+// marriages that haven't ended, and the anniversaries of deaths.
+func Calendar(w io.Writer, d *Dataset, opts CalendarOptions) error {
+	kinds := map[string]bool{}
+	for _, k := range opts.Kinds {
+		kinds[k] = true
+	}
+	want := func(kind string) bool {
+		return len(kinds) == 0 || kinds[kind]
+	}
+
+	people := map[uuid.UUID]*models.Person{}
+	for i := range d.People {
+		people[d.People[i].ID] = &d.People[i]
+	}
+
+	var events []calendarEvent
+	for i := range d.People {
+		p := &d.People[i]
+		name := p.GetDisplayName()
+
+		if want(CalendarBirthdays) && p.BirthDate.Valid && p.IsLiving && !p.DeathDate.Valid {
+			events = append(events, calendarEvent{
+				uid:         "birthday-" + p.ID.String(),
+				date:        p.BirthDate.Time,
+				summary:     name + "'s birthday",
+				description: fmt.Sprintf("%s was born on %s", name, p.BirthDate.Time.Format("January 2, 2006")),
+			})
+		}
+		if want(CalendarMemorials) && p.DeathDate.Valid {
+			events = append(events, calendarEvent{
+				uid:         "memorial-" + p.ID.String(),
+				date:        p.DeathDate.Time,
+				summary:     "In memory of " + name,
+				description: fmt.Sprintf("%s died on %s", name, p.DeathDate.Time.Format("January 2, 2006")),
+			})
+		}
+	}
+
+	if want(CalendarAnniversaries) {
+		seen := map[string]bool{}
+		for _, r := range d.Relationships {
+			if r.RelationshipType != models.RelationshipSpouse || !r.StartDate.Valid || r.EndDate.Valid {
+				continue
+			}
+			a, b := people[r.Person1ID], people[r.Person2ID]
+			if a == nil || b == nil {
+				continue
+			}
+			// A couple may be linked in both directions
+			key := r.Person1ID.String() + r.Person2ID.String()
+			if r.Person2ID.String() < r.Person1ID.String() {
+				key = r.Person2ID.String() + r.Person1ID.String()
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			couple := a.GetDisplayName() + " & " + b.GetDisplayName()
+			events = append(events, calendarEvent{
+				uid:         "anniversary-" + r.ID.String(),
+				date:        r.StartDate.Time,
+				summary:     couple + "'s wedding anniversary",
+				description: fmt.Sprintf("%s married on %s", couple, r.StartDate.Time.Format("January 2, 2006")),
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		mi, di := events[i].date.Month(), events[i].date.Day()
+		mj, dj := events[j].date.Month(), events[j].date.Day()
+		if mi != mj {
+			return mi < mj
+		}
+		return di < dj
+	})
+
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeICalLine(bw, name+":"+value)
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Farmily//Family Calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if opts.Name != "" {
+		line("X-WR-CALNAME", icalText(opts.Name))
+	}
+	// Ask subscribers to refresh daily
+	line("REFRESH-INTERVAL;VALUE=DURATION", "P1D")
+	line("X-PUBLISHED-TTL", "P1D")
+
+	for _, e := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.uid+"@farmily")
+		line("DTSTAMP", stamp)
+		line("DTSTART;VALUE=DATE", e.date.Format("20060102"))
+		line("DTEND;VALUE=DATE", e.date.AddDate(0, 0, 1).Format("20060102"))
+		if e.date.Month() == time.February && e.date.Day() == 29 {
+			// Yearly repeats of Feb 29 only fall in leap years, so use the
+			// last day of February instead
+			line("RRULE", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1")
+		} else {
+			line("RRULE", "FREQ=YEARLY")
+		}
+		line("SUMMARY", icalText(e.summary))
+		line("DESCRIPTION", icalText(e.description))
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// icalText escapes a TEXT value
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICalLine writes a content line, folded so no line is longer than 75
+// octets, without splitting UTF-8 characters
+func writeICalLine(bw *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		bw.WriteString(s[:cut])
+		bw.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the folding space
+		limit = 74
+	}
+	bw.WriteString(s)
+	bw.WriteString("\r\n")
+}
@@ -0,0 +1,345 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size and margins, in points
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 56.0
+	pdfFooter     = 30.0
+)
+
+// pdfFont is one of the standard Type 1 fonts every PDF reader has, so
+// nothing needs embedding
+type pdfFont int
+
+const (
+	pdfRegular pdfFont = iota
+	pdfBold
+	pdfItalic
+)
+
+var pdfFontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// Glyph widths of ASCII 32-126 per 1000 units of font size, from the fonts'
+// metrics. Oblique shares the regular widths.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding has
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfEncode converts text to WinAnsiEncoding, replacing characters it
+// doesn't have with '?'
+func pdfEncode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case winAnsi[r] != 0:
+			out = append(out, winAnsi[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfTextWidth measures text in points
+func pdfTextWidth(s string, font pdfFont, size float64) float64 {
+	widths := &helveticaWidths
+	if font == pdfBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range pdfEncode(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfRun is a piece of text in one style
+type pdfRun struct {
+	text string
+	font pdfFont
+	sup  bool
+}
+
+// pdfLabel is text drawn at a fixed position on a paragraph's first line,
+// such as the numbers of a list
+type pdfLabel struct {
+	text string
+	font pdfFont
+	// x is the label's right edge when right is set, else its left edge
+	x     float64
+	right bool
+}
+
+// pdfDoc lays out flowing text on A4 pages
+type pdfDoc struct {
+	title string
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	// y is the top of the space left on the page
+	y float64
+}
+
+func newPDFDoc(title string) *pdfDoc {
+	return &pdfDoc{title: title}
+}
+
+// newPage starts a page
+func (p *pdfDoc) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// space moves down, starting a page if there's no room for a line of the
+// given height after it
+func (p *pdfDoc) space(before, lineHeight float64) {
+	if p.page == nil {
+		p.newPage()
+	}
+	if p.y-before-lineHeight < pdfMargin+pdfFooter {
+		p.newPage()
+		return
+	}
+	if p.y < pdfPageHeight-pdfMargin {
+		p.y -= before
+	}
+}
+
+func (p *pdfDoc) text(x, y float64, s string, font pdfFont, size, rise float64) {
+	var escaped bytes.Buffer
+	for _, c := range pdfEncode(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	fmt.Fprintf(p.page, "BT /F%d %.2f Tf %.2f Ts 1 0 0 1 %.2f %.2f Tm (", int(font)+1, size, rise, x, y)
+	p.page.Write(escaped.Bytes())
+	p.page.WriteString(") Tj ET\n")
+}
+
+// centered writes one line of text centered on the page
+func (p *pdfDoc) centered(s string, font pdfFont, size, before float64) {
+	lineHeight := size * 1.3
+	p.space(before, lineHeight)
+	p.y -= lineHeight
+	w := pdfTextWidth(s, font, size)
+	p.text((pdfPageWidth-w)/2, p.y+size*0.3, s, font, size, 0)
+}
+
+// rule draws a horizontal line across the text area
+func (p *pdfDoc) rule(before float64) {
+	p.space(before, 1)
+	p.y -= before
+	fmt.Fprintf(p.page, "0.6 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pdfMargin, p.y, pdfPageWidth-pdfMargin, p.y)
+}
+
+// paragraph wraps runs of text between left and the right margin, drawing
+// labels on the first line
+func (p *pdfDoc) paragraph(runs []pdfRun, size, left, before float64, labels ...pdfLabel) {
+	lineHeight := size * 1.4
+	maxWidth := pdfPageWidth - pdfMargin - left
+
+	// Split into words; a word may span runs, such as a name and its
+	// superscript
+	type piece struct {
+		run   pdfRun
+		width float64
+	}
+	var words [][]piece
+	joined := false
+	for _, r := range runs {
+		size := size
+		if r.sup {
+			size *= 0.7
+		}
+		for i, part := range strings.Split(r.text, " ") {
+			if i > 0 {
+				joined = false
+			}
+			if part == "" {
+				continue
+			}
+			pc := piece{run: pdfRun{text: part, font: r.font, sup: r.sup}, width: pdfTextWidth(part, r.font, size)}
+			if joined && len(words) > 0 {
+				words[len(words)-1] = append(words[len(words)-1], pc)
+			} else {
+				words = append(words, []piece{pc})
+			}
+			joined = true
+		}
+		if strings.HasSuffix(r.text, " ") {
+			joined = false
+		}
+	}
+
+	var lines [][][]piece
+	var line [][]piece
+	width := 0.0
+	spaceWidth := pdfTextWidth(" ", pdfRegular, size)
+	for _, word := range words {
+		w := 0.0
+		for _, pc := range word {
+			w += pc.width
+		}
+		if len(line) > 0 && width+spaceWidth+w > maxWidth {
+			lines = append(lines, line)
+			line, width = nil, 0
+		}
+		if len(line) > 0 {
+			width += spaceWidth
+		}
+		line = append(line, word)
+		width += w
+	}
+	if len(line) > 0 || len(labels) > 0 {
+		lines = append(lines, line)
+	}
+
+	for i, line := range lines {
+		if i == 0 {
+			p.space(before, lineHeight)
+		} else {
+			p.space(0, lineHeight)
+		}
+		p.y -= lineHeight
+		baseline := p.y + size*0.35
+
+		if i == 0 {
+			for _, l := range labels {
+				x := l.x
+				if l.right {
+					x -= pdfTextWidth(l.text, l.font, size)
+				}
+				p.text(x, baseline, l.text, l.font, size, 0)
+			}
+		}
+
+		x := left
+		for j, word := range line {
+			if j > 0 {
+				x += spaceWidth
+			}
+			for _, pc := range word {
+				if pc.run.sup {
+					p.text(x, baseline, pc.run.text, pc.run.font, size*0.7, size*0.35)
+				} else {
+					p.text(x, baseline, pc.run.text, pc.run.font, size, 0)
+				}
+				x += pc.width
+			}
+		}
+	}
+}
+
+// write numbers the pages and writes the document
+func (p *pdfDoc) write(w io.Writer) error {
+	if len(p.pages) == 0 {
+		p.newPage()
+	}
+	for i, page := range p.pages {
+		p.page = page
+		label := fmt.Sprintf("Page %d of %d", i+1, len(p.pages))
+		p.text((pdfPageWidth-pdfTextWidth(label, pdfRegular, 8))/2, pdfMargin-10, label, pdfRegular, 8, 0)
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catalog, 2 page tree, 3-5 fonts, 6 info, then a page and its content
+	// per page
+	const firstPage = 7
+	var kids []string
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	for _, name := range pdfFontNames {
+		object("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+	}
+	object("<< /Title " + pdfString(p.title) + " /Producer (Farmily) >>")
+
+	for i, page := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+2*i+1))
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(page.Bytes())
+		zw.Close()
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfString writes a PDF literal string
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range pdfEncode(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
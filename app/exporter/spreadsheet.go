@@ -0,0 +1,205 @@
+package exporter
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/csv"
+	"farmily/app/models"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sheet is one table of a spreadsheet export
+type Sheet struct {
+	// Name is the sheet's title, and in lower case its CSV file name
+	Name    string
+	Columns []Column
+	Rows    [][]string
+}
+
+// Column is a sheet column. Numeric columns hold whole numbers or are empty.
+type Column struct {
+	Name    string
+	Numeric bool
+}
+
+// Sheet names
+const (
+	SheetPeople        = "People"
+	SheetRelationships = "Relationships"
+	SheetEvents        = "Events"
+)
+
+// FileName returns the sheet's CSV file name, e.g. "people.csv"
+func (s *Sheet) FileName() string {
+	return strings.ToLower(s.Name) + ".csv"
+}
+
+// Sheets lays the dataset out as people, relationships and events tables.
+// People carry the display name, age and lifespan the API computes, and
+// relationships and events name the people involved.
+func Sheets(d *Dataset) []Sheet {
+	people := map[uuid.UUID]*models.Person{}
+	for i := range d.People {
+		people[d.People[i].ID] = &d.People[i]
+	}
+	// Names as the relationships API gives them
+	name := func(id uuid.UUID) string {
+		if p, ok := people[id]; ok {
+			return p.FirstName + " " + p.LastName
+		}
+		return ""
+	}
+	names := func(ids []uuid.UUID) string {
+		var out []string
+		for _, id := range ids {
+			out = append(out, name(id))
+		}
+		return strings.Join(out, "; ")
+	}
+
+	peopleSheet := Sheet{
+		Name: SheetPeople,
+		Columns: []Column{
+			{Name: "ID"}, {Name: "Display Name"}, {Name: "First Name"}, {Name: "Middle Name"},
+			{Name: "Last Name"}, {Name: "Maiden Name"}, {Name: "Gender"},
+			{Name: "Birth Date"}, {Name: "Birth Place"}, {Name: "Death Date"}, {Name: "Death Place"},
+			{Name: "Living"}, {Name: "Age", Numeric: true}, {Name: "Lifespan"},
+			{Name: "Occupation"}, {Name: "Parents"}, {Name: "Spouses"}, {Name: "Biography"},
+		},
+	}
+	for i := range d.People {
+		p := &d.People[i]
+		age := ""
+		if a := p.GetAge(); a != nil {
+			age = strconv.Itoa(*a)
+		}
+		peopleSheet.Rows = append(peopleSheet.Rows, []string{
+			p.ID.String(), p.GetDisplayName(), p.FirstName, p.MiddleName.String,
+			p.LastName, p.MaidenName.String, p.Gender,
+			cellDate(p.BirthDate), p.BirthPlace.String, cellDate(p.DeathDate), p.DeathPlace.String,
+			cellBool(p.IsLiving), age, p.GetLifespan(),
+			p.Occupation.String, names(d.Parents(p.ID)), names(d.spouses[p.ID]), p.Biography.String,
+		})
+	}
+
+	relationshipsSheet := Sheet{
+		Name: SheetRelationships,
+		Columns: []Column{
+			{Name: "ID"}, {Name: "Person 1"}, {Name: "Relationship"}, {Name: "Person 2"},
+			{Name: "Description"}, {Name: "Start Date"}, {Name: "End Date"}, {Name: "Notes"},
+			{Name: "Person 1 ID"}, {Name: "Person 2 ID"},
+		},
+	}
+	for _, r := range d.Relationships {
+		// A row reads as "person 1 is the <type> of person 2"
+		relationshipsSheet.Rows = append(relationshipsSheet.Rows, []string{
+			r.ID.String(), name(r.Person1ID), r.RelationshipType, name(r.Person2ID),
+			name(r.Person1ID) + " is the " + r.RelationshipType + " of " + name(r.Person2ID),
+			cellDate(r.StartDate), cellDate(r.EndDate), r.Notes.String,
+			r.Person1ID.String(), r.Person2ID.String(),
+		})
+	}
+
+	eventsSheet := Sheet{
+		Name: SheetEvents,
+		Columns: []Column{
+			{Name: "ID"}, {Name: "Person"}, {Name: "Type"}, {Name: "Date"}, {Name: "Place"},
+			{Name: "Age", Numeric: true}, {Name: "Description"}, {Name: "Person ID"},
+		},
+	}
+	for _, e := range d.Events {
+		age := ""
+		if p, ok := people[e.PersonID]; ok && e.EventDate.Valid {
+			if a := p.GetAgeAt(e.EventDate.Time); a != nil && *a >= 0 {
+				age = strconv.Itoa(*a)
+			}
+		}
+		eventsSheet.Rows = append(eventsSheet.Rows, []string{
+			e.ID.String(), name(e.PersonID), e.EventType, cellDate(e.EventDate), e.EventPlace.String,
+			age, e.Description.String, e.PersonID.String(),
+		})
+	}
+
+	return []Sheet{peopleSheet, relationshipsSheet, eventsSheet}
+}
+
+// WriteCSV writes one sheet as CSV. A byte order mark lets spreadsheet
+// programs recognise the file as UTF-8. Text cells that a spreadsheet would
+// run as a formula are neutralised.
+func WriteCSV(w io.Writer, sheet Sheet) error {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(sheet.Columns))
+	for i, col := range sheet.Columns {
+		header[i] = col.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range sheet.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if i < len(sheet.Columns) && !sheet.Columns[i].Numeric {
+				value = csvText(value)
+			}
+			record[i] = value
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText prefixes text starting with a formula character with a quote, so
+// spreadsheet programs show it instead of running it (CSV injection)
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// WriteCSVZip writes every sheet as a CSV file in a zip archive
+func WriteCSVZip(w io.Writer, sheets []Sheet) error {
+	zw := zip.NewWriter(w)
+	for _, sheet := range sheets {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     sheet.FileName(),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := WriteCSV(f, sheet); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// cellDate writes dates as text, as spreadsheet date serials can't go back
+// before 1900
+func cellDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02")
+}
+
+func cellBool(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
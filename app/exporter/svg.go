@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// chartNameLen is the most characters of a name that fit in a chart box
+const chartNameLen = 24
+
+// chartStyle is embedded in the SVG so it renders the same anywhere
+const chartStyle = `
+.person rect { stroke: #64748b; stroke-width: 1; }
+.person text { font-family: Helvetica, Arial, sans-serif; text-anchor: middle; fill: #0f172a; }
+.person .name { font-size: 12px; font-weight: bold; }
+.person .dates { font-size: 11px; fill: #475569; }
+.link { stroke: #555555; stroke-width: 1.2; fill: none; }
+`
+
+// SVG draws the dataset as a family chart: one row per generation, spouses
+// side by side and joined by a line, and children hanging from their
+// parents. The SVG is self-contained, so it can be printed or embedded as is.
+func SVG(w io.Writer, d *Dataset, title string) error {
+	layout := layoutChart(d)
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">
+`, layout.width, layout.height, layout.width, layout.height)
+	if title != "" {
+		bw.WriteString("<title>" + xmlEscape(title) + "</title>\n")
+	}
+	bw.WriteString("<style>" + chartStyle + "</style>\n")
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+
+	// Lines first, so boxes cover their ends
+	bw.WriteString(`<g class="links">` + "\n")
+	for _, f := range d.Families() {
+		writeFamilyLinks(bw, layout, f)
+	}
+	bw.WriteString("</g>\n")
+
+	for i := range d.People {
+		p := &d.People[i]
+		box, ok := layout.boxes[p.ID]
+		if !ok {
+			continue
+		}
+		name := p.GetDisplayName()
+		short := name
+		if utf8.RuneCountInString(short) > chartNameLen {
+			short = string([]rune(short)[:chartNameLen-1]) + "…"
+		}
+		fill := chartColors[p.Gender]
+		if fill == "" {
+			fill = "#f1f5f9"
+		}
+		cx := box[0] + chartBoxWidth/2
+
+		fmt.Fprintf(bw, `<g class="person" id="p-%s">`, p.ID)
+		bw.WriteString("<title>" + xmlEscape(name) + "</title>")
+		fmt.Fprintf(bw, `<rect x="%.1f" y="%.1f" width="%d" height="%d" rx="6" fill="%s"/>`,
+			box[0], box[1], chartBoxWidth, chartBoxHeight, fill)
+		dates := chartDates(p)
+		nameY := box[1] + chartBoxHeight/2 + 4
+		if dates != "" {
+			nameY = box[1] + 22
+		}
+		fmt.Fprintf(bw, `<text class="name" x="%.1f" y="%.1f">%s</text>`, cx, nameY, xmlEscape(short))
+		if dates != "" {
+			fmt.Fprintf(bw, `<text class="dates" x="%.1f" y="%.1f">%s</text>`, cx, box[1]+40, xmlEscape(dates))
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// writeFamilyLinks draws the line joining a couple and the lines from them
+// down to their children: a drop to a bar above the children, and a drop
+// from the bar to each child
+func writeFamilyLinks(bw *bufio.Writer, layout *chartLayout, f *Family) {
+	var partners [][2]float64
+	for _, id := range f.Partners {
+		if box, ok := layout.boxes[id]; ok {
+			partners = append(partners, box)
+		}
+	}
+	if len(partners) == 0 {
+		return
+	}
+
+	// Where the children's line starts: between a couple, or below a single
+	// parent
+	var fromX, fromY float64
+	if len(partners) == 2 {
+		a, b := partners[0], partners[1]
+		if b[0] < a[0] {
+			a, b = b, a
+		}
+		ay, by := a[1]+chartBoxHeight/2, b[1]+chartBoxHeight/2
+		fmt.Fprintf(bw, `<path class="link" d="M%.1f %.1f L%.1f %.1f"/>`+"\n", a[0]+chartBoxWidth, ay, b[0], by)
+		fromX, fromY = (a[0]+chartBoxWidth+b[0])/2, (ay+by)/2
+	} else {
+		fromX, fromY = partners[0][0]+chartBoxWidth/2, partners[0][1]+chartBoxHeight
+	}
+
+	var children [][2]float64
+	for _, id := range f.Children {
+		if box, ok := layout.boxes[id]; ok {
+			children = append(children, box)
+		}
+	}
+	if len(children) == 0 {
+		return
+	}
+
+	childTop := math.Inf(1)
+	left, right := fromX, fromX
+	for _, c := range children {
+		childTop = math.Min(childTop, c[1])
+		cx := c[0] + chartBoxWidth/2
+		left, right = math.Min(left, cx), math.Max(right, cx)
+	}
+	barY := childTop - chartRowGap/2
+
+	fmt.Fprintf(bw, `<path class="link" d="M%.1f %.1f V%.1f M%.1f %.1f H%.1f`, fromX, fromY, barY, left, barY, right)
+	for _, c := range children {
+		fmt.Fprintf(bw, ` M%.1f %.1f V%.1f`, c[0]+chartBoxWidth/2, barY, c[1])
+	}
+	bw.WriteString(`"/>` + "\n")
+}
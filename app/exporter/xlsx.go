@@ -0,0 +1,176 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCellLen is the most characters a spreadsheet cell holds
+const maxCellLen = 32767
+
+// xlsxFiles are the package parts that don't depend on the sheets
+var xlsxFiles = map[string]string{
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`,
+	// Style 1 is the bold header row
+	"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`,
+}
+
+// WriteXLSX writes the sheets as an Excel workbook. Header rows are bold,
+// frozen and filterable; numeric columns are stored as numbers and
+// everything else as text.
+func WriteXLSX(w io.Writer, sheets []Sheet) error {
+	zw := zip.NewWriter(w)
+
+	create := func(name string) (*bufio.Writer, error) {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewWriter(f), nil
+	}
+	write := func(name, content string) error {
+		bw, err := create(name)
+		if err != nil {
+			return err
+		}
+		bw.WriteString(content)
+		return bw.Flush()
+	}
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	// The content types go first, as some readers expect
+	for i := range sheets {
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	types.WriteString(`</Types>`)
+	if err := write("[Content_Types].xml", types.String()); err != nil {
+		return err
+	}
+
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.Name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+
+		bw, err := create(fmt.Sprintf("xl/worksheets/sheet%d.xml", n))
+		if err != nil {
+			return err
+		}
+		writeWorksheet(bw, sheet)
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	workbook.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	rels.WriteString(`</Relationships>`)
+
+	if err := write("xl/workbook.xml", workbook.String()); err != nil {
+		return err
+	}
+	if err := write("xl/_rels/workbook.xml.rels", rels.String()); err != nil {
+		return err
+	}
+	for _, name := range []string{"_rels/.rels", "xl/styles.xml"} {
+		if err := write(name, xlsxFiles[name]); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeWorksheet(bw *bufio.Writer, sheet Sheet) {
+	lastCol := columnName(len(sheet.Columns) - 1)
+
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	bw.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	bw.WriteString(`<sheetData>`)
+
+	bw.WriteString(`<row r="1">`)
+	for i, col := range sheet.Columns {
+		writeTextCell(bw, columnName(i)+"1", col.Name, 1)
+	}
+	bw.WriteString(`</row>`)
+
+	for r, row := range sheet.Rows {
+		rowNum := strconv.Itoa(r + 2)
+		bw.WriteString(`<row r="` + rowNum + `">`)
+		for i, value := range row {
+			if value == "" {
+				continue
+			}
+			ref := columnName(i) + rowNum
+			if i < len(sheet.Columns) && sheet.Columns[i].Numeric {
+				bw.WriteString(`<c r="` + ref + `"><v>` + xmlEscape(value) + `</v></c>`)
+			} else {
+				writeTextCell(bw, ref, value, 0)
+			}
+		}
+		bw.WriteString(`</row>`)
+	}
+
+	bw.WriteString(`</sheetData>`)
+	fmt.Fprintf(bw, `<autoFilter ref="A1:%s%d"/>`, lastCol, len(sheet.Rows)+1)
+	bw.WriteString(`</worksheet>`)
+}
+
+func writeTextCell(bw *bufio.Writer, ref, value string, style int) {
+	if utf8.RuneCountInString(value) > maxCellLen {
+		value = string([]rune(value)[:maxCellLen])
+	}
+	bw.WriteString(`<c r="` + ref + `" t="inlineStr"`)
+	if style != 0 {
+		bw.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	bw.WriteString(`><is><t xml:space="preserve">` + xmlEscape(value) + `</t></is></c>`)
+}
+
+// columnName returns the letters of a zero-based column index: A, B, ...
+// Z, AA, AB...
+func columnName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML, replacing characters XML can't hold
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
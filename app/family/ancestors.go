@@ -0,0 +1,178 @@
+package family
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Generation limits for ancestor and descendant lists
+const (
+	DefaultGenerations = 5
+	MaxGenerations     = 25
+)
+
+// parentLinksSQL lists every child and parent pair, from links stored either
+// as ('child', child -> parent) or ('parent', parent -> child)
+const parentLinksSQL = `
+	SELECT person1_id AS child_id, person2_id AS parent_id FROM relationships WHERE relationship_type = 'child'
+	UNION
+	SELECT person2_id, person1_id FROM relationships WHERE relationship_type = 'parent'`
+
+// personColumns are the people columns scanPerson reads
+const personColumns = `p.id, p.first_name, p.middle_name, p.last_name, p.maiden_name, p.gender,
+	p.birth_date, p.birth_place, p.death_date, p.death_place, p.is_living,
+	p.profile_photo_url, p.profile_media_id, p.updated_at`
+
+// Ancestor is someone in a person's pedigree
+type Ancestor struct {
+	Person models.Person
+	// Generation counts back from the person: 1 for parents, 2 for
+	// grandparents
+	Generation int
+	// Number is the Ahnentafel (Sosa-Stradonitz) number: the person is 1, a
+	// father is twice his child's number and a mother twice plus one
+	Number int64
+	// Numbers lists every number of an ancestor who appears on more than one
+	// line, lowest first
+	Numbers []int64
+	// ChildID is the child on the line of the lowest number
+	ChildID uuid.UUID
+	// Relation names the ancestor, e.g. "great-grandmother"
+	Relation string
+}
+
+// Ancestors lists a person's ancestors up to the given number of
+// generations back, ordered by Ahnentafel number
+func Ancestors(db *sql.DB, id uuid.UUID, generations int) ([]Ancestor, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE parent_links AS (`+parentLinksSQL+`
+		), ancestors AS (
+			SELECT child_id, parent_id, 1 AS generation
+			FROM parent_links WHERE child_id = $1
+			UNION
+			SELECT l.child_id, l.parent_id, a.generation + 1
+			FROM parent_links l
+			JOIN ancestors a ON l.child_id = a.parent_id
+			WHERE a.generation < $2
+		)
+		SELECT DISTINCT a.child_id, `+personColumns+`
+		FROM ancestors a
+		JOIN people p ON p.id = a.parent_id
+	`, id, generations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := map[uuid.UUID]*models.Person{}
+	parents := map[uuid.UUID][]uuid.UUID{}
+	for rows.Next() {
+		var childID uuid.UUID
+		p, err := scanPerson(rows, &childID)
+		if err != nil {
+			return nil, err
+		}
+		if people[p.ID] == nil {
+			people[p.ID] = p
+		}
+		parents[childID] = append(parents[childID], p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return numberAncestors(id, generations, people, parents), nil
+}
+
+// scanPerson reads any leading columns into dest, then a person's columns
+func scanPerson(rows *sql.Rows, dest ...any) (*models.Person, error) {
+	var p models.Person
+	err := rows.Scan(append(dest, &p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+		&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+		&p.ProfilePhotoURL, &p.ProfileMediaID, &p.UpdatedAt)...)
+	return &p, err
+}
+
+// numberAncestors walks up from the person giving each ancestor their
+// Ahnentafel numbers. Fathers take the even slot and mothers the odd one;
+// parents of other or unknown gender fill whichever is free, and a third
+// recorded parent gets no number.
+func numberAncestors(root uuid.UUID, generations int, people map[uuid.UUID]*models.Person, parents map[uuid.UUID][]uuid.UUID) []Ancestor {
+	byID := map[uuid.UUID]*Ancestor{}
+	var order []uuid.UUID
+
+	type slot struct {
+		id         uuid.UUID
+		number     int64
+		generation int
+	}
+	queue := []slot{{id: root, number: 1}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.generation >= generations {
+			continue
+		}
+
+		var slots [2]uuid.UUID
+		var rest []uuid.UUID
+		for _, p := range parents[cur.id] {
+			switch people[p].Gender {
+			case models.GenderMale:
+				if slots[0] == uuid.Nil {
+					slots[0] = p
+					continue
+				}
+			case models.GenderFemale:
+				if slots[1] == uuid.Nil {
+					slots[1] = p
+					continue
+				}
+			}
+			rest = append(rest, p)
+		}
+		for _, p := range rest {
+			if slots[0] == uuid.Nil {
+				slots[0] = p
+			} else if slots[1] == uuid.Nil {
+				slots[1] = p
+			}
+		}
+
+		for i, p := range slots {
+			if p == uuid.Nil {
+				continue
+			}
+			number := cur.number*2 + int64(i)
+			a := byID[p]
+			if a == nil {
+				a = &Ancestor{
+					Person:     *people[p],
+					Generation: cur.generation + 1,
+					Number:     number,
+					ChildID:    cur.id,
+					Relation:   bloodName(cur.generation+1, 0, people[p].Gender),
+				}
+				byID[p] = a
+				order = append(order, p)
+			}
+			a.Numbers = append(a.Numbers, number)
+			queue = append(queue, slot{id: p, number: number, generation: cur.generation + 1})
+		}
+	}
+
+	ancestors := make([]Ancestor, 0, len(order))
+	for _, id := range order {
+		a := byID[id]
+		sort.Slice(a.Numbers, func(i, j int) bool { return a.Numbers[i] < a.Numbers[j] })
+		if len(a.Numbers) == 1 {
+			a.Numbers = nil
+		}
+		ancestors = append(ancestors, *a)
+	}
+	sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].Number < ancestors[j].Number })
+	return ancestors
+}
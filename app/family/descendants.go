@@ -0,0 +1,196 @@
+package family
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Descendant is someone descending from a person
+type Descendant struct {
+	Person models.Person
+	// Generation counts down from the person: 1 for children, 2 for
+	// grandchildren
+	Generation int
+	// Number is the d'Aboville number: the person is 1, their children 1.1,
+	// 1.2 by birth, a second child's first child 1.2.1. Someone reached on
+	// more than one line takes the number of the shortest.
+	Number string
+	// Numbers lists every number of a descendant reached on more than one
+	// line, in register order
+	Numbers []string
+	// ParentID is the parent on the line of Number
+	ParentID uuid.UUID
+	// OtherParent is the parent's spouse or partner the child descends
+	// through, if recorded
+	OtherParent *models.Person
+	// Relation names the descendant, e.g. "granddaughter"
+	Relation string
+}
+
+// Descendants lists a person's descendants down to the given number of
+// generations, ordered by d'Aboville number
+func Descendants(db *sql.DB, id uuid.UUID, generations int) ([]Descendant, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE parent_links AS (`+parentLinksSQL+`
+		), descendants AS (
+			SELECT parent_id, child_id, 1 AS generation
+			FROM parent_links WHERE parent_id = $1
+			UNION
+			SELECT l.parent_id, l.child_id, d.generation + 1
+			FROM parent_links l
+			JOIN descendants d ON l.parent_id = d.child_id
+			WHERE d.generation < $2
+		)
+		SELECT DISTINCT d.parent_id, `+personColumns+`
+		FROM descendants d
+		JOIN people p ON p.id = d.child_id
+	`, id, generations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	people := map[uuid.UUID]*models.Person{}
+	children := map[uuid.UUID][]uuid.UUID{}
+	var ids []string
+	for rows.Next() {
+		var parentID uuid.UUID
+		p, err := scanPerson(rows, &parentID)
+		if err != nil {
+			return nil, err
+		}
+		if people[p.ID] == nil {
+			people[p.ID] = p
+			ids = append(ids, p.ID.String())
+		}
+		children[parentID] = append(children[parentID], p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []Descendant{}, nil
+	}
+
+	// Every parent of each descendant, to find the one they descend through
+	// besides the line being followed
+	rows, err = db.Query(`
+		WITH parent_links AS (`+parentLinksSQL+`
+		)
+		SELECT DISTINCT l.child_id, `+personColumns+`
+		FROM parent_links l
+		JOIN people p ON p.id = l.parent_id
+		WHERE l.child_id = ANY($1::uuid[])
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := map[uuid.UUID][]*models.Person{}
+	for rows.Next() {
+		var childID uuid.UUID
+		p, err := scanPerson(rows, &childID)
+		if err != nil {
+			return nil, err
+		}
+		parents[childID] = append(parents[childID], p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return numberDescendants(id, generations, people, children, parents), nil
+}
+
+// numberDescendants walks down from the person giving each descendant
+// their d'Aboville numbers. Children are numbered in order of birth, those
+// without a birth date last.
+func numberDescendants(root uuid.UUID, generations int, people map[uuid.UUID]*models.Person, children map[uuid.UUID][]uuid.UUID, parents map[uuid.UUID][]*models.Person) []Descendant {
+	byID := map[uuid.UUID]*Descendant{}
+	var order []uuid.UUID
+
+	type slot struct {
+		id         uuid.UUID
+		number     string
+		generation int
+	}
+	queue := []slot{{id: root, number: "1"}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.generation >= generations {
+			continue
+		}
+
+		kids := append([]uuid.UUID{}, children[cur.id]...)
+		sort.SliceStable(kids, func(i, j int) bool {
+			a, b := people[kids[i]], people[kids[j]]
+			if a.BirthDate.Valid != b.BirthDate.Valid {
+				return a.BirthDate.Valid
+			}
+			if a.BirthDate.Valid && !a.BirthDate.Time.Equal(b.BirthDate.Time) {
+				return a.BirthDate.Time.Before(b.BirthDate.Time)
+			}
+			return a.GetDisplayName() < b.GetDisplayName()
+		})
+
+		for i, kid := range kids {
+			number := cur.number + "." + strconv.Itoa(i+1)
+			d := byID[kid]
+			if d == nil {
+				d = &Descendant{
+					Person:     *people[kid],
+					Generation: cur.generation + 1,
+					Number:     number,
+					ParentID:   cur.id,
+					Relation:   bloodName(0, cur.generation+1, people[kid].Gender),
+				}
+				for _, p := range parents[kid] {
+					if p.ID != cur.id {
+						d.OtherParent = p
+						break
+					}
+				}
+				byID[kid] = d
+				order = append(order, kid)
+			}
+			d.Numbers = append(d.Numbers, number)
+			queue = append(queue, slot{id: kid, number: number, generation: cur.generation + 1})
+		}
+	}
+
+	descendants := make([]Descendant, 0, len(order))
+	for _, id := range order {
+		d := byID[id]
+		sort.Slice(d.Numbers, func(i, j int) bool { return lessDAboville(d.Numbers[i], d.Numbers[j]) })
+		if len(d.Numbers) == 1 {
+			d.Numbers = nil
+		}
+		descendants = append(descendants, *d)
+	}
+	sort.SliceStable(descendants, func(i, j int) bool {
+		return lessDAboville(descendants[i].Number, descendants[j].Number)
+	})
+	return descendants
+}
+
+// lessDAboville orders d'Aboville numbers as a register lists them: each
+// person followed by their line, 1.2 before 1.2.1 before 1.10
+func lessDAboville(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
@@ -0,0 +1,252 @@
+// Package family walks the family graph: who is whose parent, child, spouse
+// and sibling. It names how two people are related and lists ancestors and
+// descendants.
+package family
+
+import (
+	"database/sql"
+	"farmily/app/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Graph holds everyone and their direct links
+type Graph struct {
+	People map[uuid.UUID]*models.Person
+	// Relationships are the rows the links were built from, repeats and all
+	Relationships []models.Relationship
+
+	parents  map[uuid.UUID][]uuid.UUID
+	children map[uuid.UUID][]uuid.UUID
+	spouses  map[uuid.UUID][]uuid.UUID
+	siblings map[uuid.UUID][]uuid.UUID
+}
+
+// querier is a database or a transaction
+type querier interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}
+
+// LoadGraph reads every person and relationship, from the database or
+// within a transaction
+func LoadGraph(db querier) (*Graph, error) {
+	return loadGraph(db, "", "")
+}
+
+// LoadLines reads the given people, their ancestors and descendants, everyone
+// linked to them directly and the other parents of their children, with the
+// relationships between them. It holds every issue Validate can find
+// involving the given people.
+func LoadLines(db querier, ids ...uuid.UUID) (*Graph, error) {
+	start := make([]string, len(ids))
+	for i, id := range ids {
+		start[i] = id.String()
+	}
+
+	rows, err := db.Query(`
+		WITH RECURSIVE parent_links AS (`+parentLinksSQL+`
+		), up AS (
+			SELECT unnest($1::uuid[]) AS id
+			UNION
+			SELECT l.parent_id FROM parent_links l JOIN up u ON l.child_id = u.id
+		), down AS (
+			SELECT unnest($1::uuid[]) AS id
+			UNION
+			SELECT l.child_id FROM parent_links l JOIN down d ON l.parent_id = d.id
+		)
+		SELECT id FROM up
+		UNION SELECT id FROM down
+		UNION SELECT person2_id FROM relationships WHERE person1_id = ANY($1::uuid[])
+		UNION SELECT person1_id FROM relationships WHERE person2_id = ANY($1::uuid[])
+		UNION
+		SELECT l.parent_id
+		FROM parent_links l
+		JOIN parent_links c ON c.child_id = l.child_id
+		WHERE c.parent_id = ANY($1::uuid[])
+	`, pq.Array(start))
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return loadGraph(db,
+		"WHERE id = ANY($1::uuid[])",
+		"WHERE person1_id = ANY($1::uuid[]) AND person2_id = ANY($1::uuid[])",
+		pq.Array(lines))
+}
+
+// loadGraph reads the people and relationships the filters select
+func loadGraph(db querier, peopleWhere, relationshipsWhere string, args ...interface{}) (*Graph, error) {
+	g := &Graph{People: map[uuid.UUID]*models.Person{}}
+
+	rows, err := db.Query(`
+		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			profile_photo_url, profile_media_id, updated_at
+		FROM people
+		`+peopleWhere, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var p models.Person
+		err := rows.Scan(&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+			&p.ProfilePhotoURL, &p.ProfileMediaID, &p.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.People[p.ID] = &p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT id, person1_id, person2_id, relationship_type FROM relationships
+		`+relationshipsWhere+`
+		ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var links []models.Relationship
+	for rows.Next() {
+		var r models.Relationship
+		if err := rows.Scan(&r.ID, &r.Person1ID, &r.Person2ID, &r.RelationshipType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		links = append(links, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	g.index(links)
+	return g, nil
+}
+
+// NewGraph builds a graph from people and their relationships
+func NewGraph(people []models.Person, relationships []models.Relationship) *Graph {
+	g := &Graph{People: map[uuid.UUID]*models.Person{}}
+	for i := range people {
+		g.People[people[i].ID] = &people[i]
+	}
+	g.index(relationships)
+	return g
+}
+
+// index builds the link lookups. Parent links are stored either as
+// ('child', child -> parent) or ('parent', parent -> child); links to
+// missing people and repeats are dropped.
+func (g *Graph) index(relationships []models.Relationship) {
+	g.Relationships = relationships
+	g.parents = map[uuid.UUID][]uuid.UUID{}
+	g.children = map[uuid.UUID][]uuid.UUID{}
+	g.spouses = map[uuid.UUID][]uuid.UUID{}
+	g.siblings = map[uuid.UUID][]uuid.UUID{}
+
+	seen := map[string]bool{}
+	link := func(kind string, from, to uuid.UUID, lookup map[uuid.UUID][]uuid.UUID) bool {
+		key := kind + from.String() + to.String()
+		if seen[key] || from == to {
+			return false
+		}
+		seen[key] = true
+		lookup[from] = append(lookup[from], to)
+		return true
+	}
+
+	for _, r := range relationships {
+		if g.People[r.Person1ID] == nil || g.People[r.Person2ID] == nil {
+			continue
+		}
+		a, b := r.Person1ID, r.Person2ID
+		switch r.RelationshipType {
+		case models.RelationshipParent:
+			a, b = b, a
+			fallthrough
+		case models.RelationshipChild:
+			if link("p", a, b, g.parents) {
+				link("c", b, a, g.children)
+			}
+		case models.RelationshipSpouse:
+			link("s", a, b, g.spouses)
+			link("s", b, a, g.spouses)
+		case models.RelationshipSibling:
+			link("b", a, b, g.siblings)
+			link("b", b, a, g.siblings)
+		}
+	}
+}
+
+// Person returns a person, or nil
+func (g *Graph) Person(id uuid.UUID) *models.Person {
+	return g.People[id]
+}
+
+// Parents returns a person's parents
+func (g *Graph) Parents(id uuid.UUID) []uuid.UUID {
+	return g.parents[id]
+}
+
+// Children returns a person's children
+func (g *Graph) Children(id uuid.UUID) []uuid.UUID {
+	return g.children[id]
+}
+
+// Spouses returns a person's spouses
+func (g *Graph) Spouses(id uuid.UUID) []uuid.UUID {
+	return g.spouses[id]
+}
+
+// Siblings returns the people recorded as a person's siblings, and those who
+// share a parent with them
+func (g *Graph) Siblings(id uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{id: true}
+	var out []uuid.UUID
+	for _, s := range g.siblings[id] {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	for _, p := range g.parents[id] {
+		for _, s := range g.children[p] {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// sharedParents counts the parents two people have in common
+func (g *Graph) sharedParents(a, b uuid.UUID) int {
+	n := 0
+	for _, p := range g.parents[a] {
+		for _, q := range g.parents[b] {
+			if p == q {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,495 @@
+package family
+
+import (
+	"farmily/app/models"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Kinship kinds
+const (
+	KinshipSelf     = "self"
+	KinshipBlood    = "blood"
+	KinshipMarriage = "marriage"
+	// KinshipDistant connections go through more than one marriage, or a
+	// child's other parent, and are named by the path
+	KinshipDistant = "distant"
+)
+
+// maxKinshipCost bounds the search, in parent, child or spouse links
+const maxKinshipCost = 40
+
+// move is one link on a path through the graph
+type move byte
+
+const (
+	moveParent move = iota
+	moveChild
+	moveSibling
+	moveSpouse
+)
+
+// inverse is the link walked the other way
+func (m move) inverse() move {
+	switch m {
+	case moveParent:
+		return moveChild
+	case moveChild:
+		return moveParent
+	}
+	return m
+}
+
+// Kinship says how one person is related to another
+type Kinship struct {
+	Related bool
+	Kind    string
+	// Label names what the first person is to the second, e.g. "great-aunt"
+	Label string
+	// Path lists the people from the first person to the second
+	Path []Step
+	// CommonAncestors are the closest ancestors blood relatives share
+	CommonAncestors []uuid.UUID
+}
+
+// Step is a person on a kinship path
+type Step struct {
+	PersonID uuid.UUID
+	// Relation is what this person is to the one before them on the path
+	Relation string
+}
+
+// path is a walk from one person to another: nodes[i+1] is reached from
+// nodes[i] through moves[i]
+type path struct {
+	nodes []uuid.UUID
+	moves []move
+}
+
+func (p path) cost() int {
+	n := 0
+	for _, m := range p.moves {
+		if m == moveSibling {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// Relate finds the closest connection between a and b and names what a is
+// to b. Blood relationships are preferred over equally close ones through a
+// marriage.
+func (g *Graph) Relate(a, b uuid.UUID) Kinship {
+	if a == b {
+		return Kinship{Related: true, Kind: KinshipSelf, Label: "self", Path: []Step{{PersonID: a}}}
+	}
+
+	type candidate struct {
+		kind  string
+		label string
+		path  path
+		cost  int
+		blood *path
+	}
+	var best *candidate
+	consider := func(c candidate) {
+		if best == nil || c.cost < best.cost {
+			best = &c
+		}
+	}
+
+	// Walks run from b to a, so each link says what the next person is to
+	// the one before
+	if p, ok := g.bloodPath(b, a); ok {
+		consider(candidate{kind: KinshipBlood, label: g.bloodLabel(p, a), path: p, cost: p.cost(), blood: &p})
+	}
+	for _, s := range g.spouses[b] {
+		// a is a blood relative of b's spouse
+		var p path
+		if s == a {
+			p = path{nodes: []uuid.UUID{s}}
+		} else if found, ok := g.bloodPath(s, a); ok {
+			p = found
+		} else {
+			continue
+		}
+		full := path{nodes: append([]uuid.UUID{b}, p.nodes...), moves: append([]move{moveSpouse}, p.moves...)}
+		consider(candidate{kind: KinshipMarriage, label: g.spouseRelativeLabel(p, a), path: full, cost: full.cost()})
+	}
+	for _, s := range g.spouses[a] {
+		// a is married to a blood relative of b
+		p, ok := g.bloodPath(b, s)
+		if !ok {
+			continue
+		}
+		full := path{nodes: append(append([]uuid.UUID{}, p.nodes...), a), moves: append(append([]move{}, p.moves...), moveSpouse)}
+		consider(candidate{kind: KinshipMarriage, label: g.relativeSpouseLabel(p, a), path: full, cost: full.cost()})
+	}
+
+	if best == nil {
+		p, ok := g.anyPath(b, a)
+		if !ok {
+			return Kinship{}
+		}
+		words := make([]string, len(p.moves))
+		for i, m := range p.moves {
+			words[i] = g.word(m, p.nodes[i+1])
+		}
+		best = &candidate{kind: KinshipDistant, label: strings.Join(words, "'s "), path: p}
+	}
+
+	k := Kinship{Related: true, Kind: best.kind, Label: best.label, Path: g.steps(best.path)}
+	if best.blood != nil {
+		k.CommonAncestors = g.commonAncestors(*best.blood)
+	}
+	return k
+}
+
+// steps turns a walk from b to a into the path from a to b
+func (g *Graph) steps(p path) []Step {
+	n := len(p.nodes)
+	steps := []Step{{PersonID: p.nodes[n-1]}}
+	for i := n - 2; i >= 0; i-- {
+		steps = append(steps, Step{
+			PersonID: p.nodes[i],
+			Relation: g.word(p.moves[i].inverse(), p.nodes[i]),
+		})
+	}
+	return steps
+}
+
+// bloodPath finds the shortest line of descent from one person to another:
+// up through parents, then down through children, turning at a common
+// ancestor or through a recorded sibling
+func (g *Graph) bloodPath(from, to uuid.UUID) (path, bool) {
+	type state struct {
+		id   uuid.UUID
+		down bool
+	}
+	type edge struct {
+		prev state
+		m    move
+	}
+
+	start := state{id: from}
+	dist := map[state]int{start: 0}
+	came := map[state]edge{}
+	buckets := [][]state{{start}}
+
+	for cost := 0; cost < len(buckets) && cost <= maxKinshipCost; cost++ {
+		for i := 0; i < len(buckets[cost]); i++ {
+			s := buckets[cost][i]
+			if dist[s] != cost {
+				continue
+			}
+			if s.id == to {
+				var p path
+				for cur := s; cur != start; cur = came[cur].prev {
+					p.nodes = append([]uuid.UUID{cur.id}, p.nodes...)
+					p.moves = append([]move{came[cur].m}, p.moves...)
+				}
+				p.nodes = append([]uuid.UUID{from}, p.nodes...)
+				return p, true
+			}
+
+			visit := func(next state, m move, step int) {
+				if d, ok := dist[next]; ok && d <= cost+step {
+					return
+				}
+				dist[next] = cost + step
+				came[next] = edge{prev: s, m: m}
+				for len(buckets) <= cost+step {
+					buckets = append(buckets, nil)
+				}
+				buckets[cost+step] = append(buckets[cost+step], next)
+			}
+			if !s.down {
+				for _, p := range g.parents[s.id] {
+					visit(state{id: p}, moveParent, 1)
+				}
+				for _, sib := range g.siblings[s.id] {
+					visit(state{id: sib, down: true}, moveSibling, 2)
+				}
+			}
+			for _, c := range g.children[s.id] {
+				visit(state{id: c, down: true}, moveChild, 1)
+			}
+		}
+	}
+	return path{}, false
+}
+
+// anyPath finds the shortest walk through any links
+func (g *Graph) anyPath(from, to uuid.UUID) (path, bool) {
+	type edge struct {
+		prev uuid.UUID
+		m    move
+	}
+	came := map[uuid.UUID]edge{}
+	seen := map[uuid.UUID]bool{from: true}
+	queue := []uuid.UUID{from}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			p := path{nodes: []uuid.UUID{to}}
+			for cur := to; cur != from; cur = came[cur].prev {
+				p.nodes = append([]uuid.UUID{came[cur].prev}, p.nodes...)
+				p.moves = append([]move{came[cur].m}, p.moves...)
+			}
+			return p, true
+		}
+		for m, links := range map[move][]uuid.UUID{
+			moveParent: g.parents[id], moveChild: g.children[id],
+			moveSpouse: g.spouses[id], moveSibling: g.siblings[id],
+		} {
+			for _, next := range links {
+				if !seen[next] {
+					seen[next] = true
+					came[next] = edge{prev: id, m: m}
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return path{}, false
+}
+
+// generations counts the generations a line of descent goes up and down
+func generations(p path) (up, down int) {
+	for _, m := range p.moves {
+		switch m {
+		case moveParent:
+			up++
+		case moveChild:
+			down++
+		case moveSibling:
+			up++
+			down++
+		}
+	}
+	return up, down
+}
+
+// half reports whether a line of descent turns through only one of two
+// parents: the two people below the turn each have two known parents, and
+// share one
+func (g *Graph) half(p path) bool {
+	up, down := generations(p)
+	if up == 0 || down == 0 || p.moves[up-1] == moveSibling {
+		return false
+	}
+	for _, m := range p.moves {
+		if m == moveSibling {
+			return false
+		}
+	}
+	left, right := p.nodes[up-1], p.nodes[up+1]
+	return len(g.parents[left]) == 2 && len(g.parents[right]) == 2 && g.sharedParents(left, right) == 1
+}
+
+// commonAncestors lists the ancestors at the turn of a line of descent
+func (g *Graph) commonAncestors(p path) []uuid.UUID {
+	up, down := generations(p)
+	if up == 0 || down == 0 {
+		return nil
+	}
+	for _, m := range p.moves {
+		if m == moveSibling {
+			return nil
+		}
+	}
+	left, right := p.nodes[up-1], p.nodes[up+1]
+	var out []uuid.UUID
+	for _, x := range g.parents[left] {
+		for _, y := range g.parents[right] {
+			if x == y {
+				out = append(out, x)
+			}
+		}
+	}
+	return out
+}
+
+// bloodLabel names what the person at the end of a line of descent is to
+// the one at its start
+func (g *Graph) bloodLabel(p path, who uuid.UUID) string {
+	up, down := generations(p)
+	gender := g.gender(who)
+	label := bloodName(up, down, gender)
+	if g.half(p) {
+		if up >= 2 && down >= 2 {
+			return "half " + label
+		}
+		return "half-" + label
+	}
+	return label
+}
+
+// bloodName names a blood relative up generations above and down below the
+// common ancestor
+func bloodName(up, down int, gender string) string {
+	switch {
+	case up == 0 && down == 0:
+		return "self"
+	case up == 0:
+		if down == 1 {
+			return gendered("child", gender)
+		}
+		return greats(down-2) + "grand" + gendered("child", gender)
+	case down == 0:
+		if up == 1 {
+			return gendered("parent", gender)
+		}
+		return greats(up-2) + "grand" + gendered("parent", gender)
+	case up == 1 && down == 1:
+		return gendered("sibling", gender)
+	case up == 1:
+		return greats(down-2) + gendered("niece", gender)
+	case down == 1:
+		return greats(up-2) + gendered("aunt", gender)
+	}
+
+	degree, removed := up-1, down-1
+	if removed < degree {
+		degree, removed = removed, degree
+	}
+	removed -= degree
+	label := ordinalWord(degree) + " cousin"
+	switch removed {
+	case 0:
+	case 1:
+		label += " once removed"
+	case 2:
+		label += " twice removed"
+	default:
+		label += " " + strconv.Itoa(removed) + " times removed"
+	}
+	return label
+}
+
+// spouseRelativeLabel names what a blood relative of someone's spouse is to
+// them, given the line of descent from the spouse
+func (g *Graph) spouseRelativeLabel(p path, who uuid.UUID) string {
+	up, down := generations(p)
+	gender := g.gender(who)
+	switch {
+	case up == 0 && down == 0:
+		return gendered("spouse", gender)
+	case up == 0:
+		// The spouse's children and their descendants
+		return "step" + map[bool]string{true: "", false: "-"}[down == 1] + bloodName(0, down, gender)
+	}
+	return g.bloodLabel(p, who) + "-in-law"
+}
+
+// relativeSpouseLabel names what the spouse of someone's blood relative is
+// to them, given the line of descent to the relative
+func (g *Graph) relativeSpouseLabel(p path, who uuid.UUID) string {
+	up, down := generations(p)
+	gender := g.gender(who)
+	relative := bloodName(up, down, gender)
+	switch {
+	case down == 0:
+		// The spouses of parents and ancestors
+		if up == 1 {
+			return "step" + relative
+		}
+		return "step-" + relative
+	case up <= 1:
+		// The spouses of descendants, siblings, nieces and nephews
+		return relative + "-in-law"
+	case down == 1:
+		return relative + " by marriage"
+	}
+	return bloodName(up, down, "") + "'s " + gendered("spouse", gender)
+}
+
+// word names the link to a person: what they are to the one before them
+func (g *Graph) word(m move, id uuid.UUID) string {
+	gender := g.gender(id)
+	switch m {
+	case moveParent:
+		return gendered("parent", gender)
+	case moveChild:
+		return gendered("child", gender)
+	case moveSibling:
+		return gendered("sibling", gender)
+	}
+	return gendered("spouse", gender)
+}
+
+func (g *Graph) gender(id uuid.UUID) string {
+	if p := g.People[id]; p != nil {
+		return p.Gender
+	}
+	return ""
+}
+
+// kinWords holds the male, female and neutral word for each kind of
+// relative
+var kinWords = map[string][3]string{
+	"parent":  {"father", "mother", "parent"},
+	"child":   {"son", "daughter", "child"},
+	"sibling": {"brother", "sister", "sibling"},
+	"spouse":  {"husband", "wife", "spouse"},
+	"aunt":    {"uncle", "aunt", "aunt/uncle"},
+	"niece":   {"nephew", "niece", "niece/nephew"},
+}
+
+func gendered(kind, gender string) string {
+	words := kinWords[kind]
+	switch gender {
+	case models.GenderMale:
+		return words[0]
+	case models.GenderFemale:
+		return words[1]
+	}
+	return words[2]
+}
+
+// greats returns the prefix for n generations beyond grand: "", "great-",
+// "great-great-", "3rd great-"...
+func greats(n int) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n == 1:
+		return "great-"
+	case n == 2:
+		return "great-great-"
+	}
+	return ordinal(n) + " great-"
+}
+
+var ordinalWords = []string{"", "first", "second", "third", "fourth", "fifth", "sixth", "seventh", "eighth", "ninth", "tenth"}
+
+// ordinalWord spells out small ordinals, as cousin degrees are written
+func ordinalWord(n int) string {
+	if n > 0 && n < len(ordinalWords) {
+		return ordinalWords[n]
+	}
+	return ordinal(n)
+}
+
+// ordinal writes 1st, 2nd, 3rd, 4th...
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,169 @@
+package family
+
+import (
+	"farmily/app/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBloodName(t *testing.T) {
+	tests := []struct {
+		up, down int
+		gender   string
+		want     string
+	}{
+		{0, 0, "", "self"},
+		{1, 0, models.GenderFemale, "mother"},
+		{2, 0, models.GenderMale, "grandfather"},
+		{4, 0, "", "great-great-grandparent"},
+		{0, 1, models.GenderMale, "son"},
+		{0, 3, models.GenderFemale, "great-granddaughter"},
+		{1, 1, "", "sibling"},
+		{1, 2, models.GenderMale, "nephew"},
+		{1, 3, models.GenderFemale, "great-niece"},
+		{2, 1, models.GenderFemale, "aunt"},
+		{3, 1, models.GenderFemale, "great-aunt"},
+		{4, 1, models.GenderMale, "great-great-uncle"},
+		{5, 1, "", "3rd great-aunt/uncle"},
+		{2, 2, models.GenderMale, "first cousin"},
+		{2, 3, "", "first cousin once removed"},
+		{3, 2, "", "first cousin once removed"},
+		{4, 2, "", "first cousin twice removed"},
+		{3, 3, "", "second cousin"},
+		{3, 6, "", "second cousin 3 times removed"},
+		{12, 12, "", "11th cousin"},
+	}
+	for _, tt := range tests {
+		if got := bloodName(tt.up, tt.down, tt.gender); got != tt.want {
+			t.Errorf("bloodName(%d, %d, %q) = %q, want %q", tt.up, tt.down, tt.gender, got, tt.want)
+		}
+	}
+}
+
+func TestGreats(t *testing.T) {
+	tests := map[int]string{
+		0:  "",
+		1:  "great-",
+		2:  "great-great-",
+		3:  "3rd great-",
+		11: "11th great-",
+		21: "21st great-",
+		22: "22nd great-",
+	}
+	for n, want := range tests {
+		if got := greats(n); got != want {
+			t.Errorf("greats(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// kinshipGraph builds a small family; people are named by what they are to
+// "me"
+func kinshipGraph() (*Graph, map[string]uuid.UUID) {
+	genders := map[string]string{
+		"me":             models.GenderMale,
+		"sister":         models.GenderFemale,
+		"father":         models.GenderMale,
+		"mother":         models.GenderFemale,
+		"stepmother":     models.GenderFemale,
+		"father's ex":    models.GenderFemale,
+		"half-brother":   models.GenderMale,
+		"half-nephew":    models.GenderMale,
+		"grandfather":    models.GenderMale,
+		"grandmother":    models.GenderFemale,
+		"great-grandma":  models.GenderFemale,
+		"great-grandpa":  models.GenderMale,
+		"great-aunt":     models.GenderFemale,
+		"aunt":           models.GenderFemale,
+		"aunt's husband": models.GenderMale,
+		"cousin":         models.GenderFemale,
+		"cousin's son":   models.GenderMale,
+		"wife":           models.GenderFemale,
+		"wife's father":  models.GenderMale,
+		"wife's son":     models.GenderMale,
+		"sister's man":   models.GenderMale,
+		"stranger":       "",
+	}
+	ids := map[string]uuid.UUID{}
+	var people []models.Person
+	for name, gender := range genders {
+		id := uuid.New()
+		ids[name] = id
+		people = append(people, models.Person{ID: id, FirstName: name, Gender: gender})
+	}
+
+	var links []models.Relationship
+	link := func(kind, a, b string) {
+		links = append(links, models.Relationship{ID: uuid.New(), Person1ID: ids[a], Person2ID: ids[b], RelationshipType: kind})
+	}
+	parent := func(child string, parents ...string) {
+		for _, p := range parents {
+			link(models.RelationshipParent, p, child)
+		}
+	}
+	parent("me", "father", "mother")
+	parent("sister", "father", "mother")
+	parent("half-brother", "father", "father's ex")
+	parent("half-nephew", "half-brother")
+	parent("father", "grandfather", "grandmother")
+	parent("aunt", "grandfather", "grandmother")
+	parent("grandmother", "great-grandpa", "great-grandma")
+	parent("great-aunt", "great-grandpa", "great-grandma")
+	parent("cousin", "aunt", "aunt's husband")
+	parent("cousin's son", "cousin")
+	parent("wife", "wife's father")
+	parent("wife's son", "wife")
+	link(models.RelationshipSpouse, "father", "mother")
+	link(models.RelationshipSpouse, "father", "stepmother")
+	link(models.RelationshipSpouse, "aunt", "aunt's husband")
+	link(models.RelationshipSpouse, "me", "wife")
+	link(models.RelationshipSpouse, "sister", "sister's man")
+
+	return NewGraph(people, links), ids
+}
+
+func TestRelate(t *testing.T) {
+	g, ids := kinshipGraph()
+
+	tests := []struct {
+		a, b string
+		kind string
+		want string
+	}{
+		{"me", "me", KinshipSelf, "self"},
+		{"father", "me", KinshipBlood, "father"},
+		{"me", "father", KinshipBlood, "son"},
+		{"sister", "me", KinshipBlood, "sister"},
+		{"great-grandma", "me", KinshipBlood, "great-grandmother"},
+		{"great-aunt", "me", KinshipBlood, "great-aunt"},
+		{"me", "great-aunt", KinshipBlood, "great-nephew"},
+		{"aunt", "me", KinshipBlood, "aunt"},
+		{"cousin", "me", KinshipBlood, "first cousin"},
+		{"cousin's son", "me", KinshipBlood, "first cousin once removed"},
+		{"me", "cousin's son", KinshipBlood, "first cousin once removed"},
+		{"half-brother", "me", KinshipBlood, "half-brother"},
+		{"half-nephew", "me", KinshipBlood, "half-nephew"},
+		{"stepmother", "me", KinshipMarriage, "stepmother"},
+		{"wife's son", "me", KinshipMarriage, "stepson"},
+		{"me", "wife's son", KinshipMarriage, "stepfather"},
+		{"wife", "me", KinshipMarriage, "wife"},
+		{"wife's father", "me", KinshipMarriage, "father-in-law"},
+		{"me", "wife's father", KinshipMarriage, "son-in-law"},
+		{"sister's man", "me", KinshipMarriage, "brother-in-law"},
+		{"aunt's husband", "me", KinshipMarriage, "uncle by marriage"},
+	}
+	for _, tt := range tests {
+		k := g.Relate(ids[tt.a], ids[tt.b])
+		if !k.Related || k.Kind != tt.kind || k.Label != tt.want {
+			t.Errorf("Relate(%s, %s) = %v %q %q, want %q %q", tt.a, tt.b, k.Related, k.Kind, k.Label, tt.kind, tt.want)
+		}
+	}
+
+	if k := g.Relate(ids["stranger"], ids["me"]); k.Related {
+		t.Errorf("Relate(stranger, me) = %q, want unrelated", k.Label)
+	}
+	if k := g.Relate(ids["cousin"], ids["me"]); len(k.CommonAncestors) != 2 {
+		t.Errorf("Relate(cousin, me) common ancestors = %v, want the grandparents", k.CommonAncestors)
+	}
+}
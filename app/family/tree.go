@@ -0,0 +1,229 @@
+package family
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Subtree directions
+const (
+	DirectionAncestors   = "ancestors"
+	DirectionDescendants = "descendants"
+	DirectionBoth        = "both"
+)
+
+// DefaultTreeDepth is how many generations a tree shows each way unless
+// asked for more
+const DefaultTreeDepth = 3
+
+// Subtree is the part of the tree around one person: their ancestors and/or
+// descendants to a depth, and the spouses of everyone on those lines
+type Subtree struct {
+	Root   uuid.UUID
+	People []models.Person
+	// Generations places each person relative to the root: negative for
+	// ancestors, positive for descendants. Spouses share their partner's.
+	Generations map[uuid.UUID]int
+	// Relationships are the links between people in the subtree
+	Relationships []models.Relationship
+
+	parentCounts  map[uuid.UUID]int
+	childCounts   map[uuid.UUID]int
+	shownParents  map[uuid.UUID]int
+	shownChildren map[uuid.UUID]int
+}
+
+// ParentCount is how many parents a person has recorded
+func (s *Subtree) ParentCount(id uuid.UUID) int {
+	return s.parentCounts[id]
+}
+
+// ChildCount is how many children a person has recorded
+func (s *Subtree) ChildCount(id uuid.UUID) int {
+	return s.childCounts[id]
+}
+
+// HasHiddenParents reports whether a person has parents outside the
+// subtree
+func (s *Subtree) HasHiddenParents(id uuid.UUID) bool {
+	return s.parentCounts[id] > s.shownParents[id]
+}
+
+// HasHiddenChildren reports whether a person has children outside the
+// subtree
+func (s *Subtree) HasHiddenChildren(id uuid.UUID) bool {
+	return s.childCounts[id] > s.shownChildren[id]
+}
+
+// LoadSubtree reads the people within depth generations of root in the
+// given direction, their spouses, and the links between them
+func LoadSubtree(db *sql.DB, root uuid.UUID, direction string, depth int) (*Subtree, error) {
+	up := direction == DirectionAncestors || direction == DirectionBoth
+	down := direction == DirectionDescendants || direction == DirectionBoth
+
+	rows, err := db.Query(`
+		WITH RECURSIVE parent_links AS (`+parentLinksSQL+`
+		), up AS (
+			SELECT $1::uuid AS id, 0 AS generation
+			UNION
+			SELECT l.parent_id, u.generation + 1
+			FROM parent_links l
+			JOIN up u ON l.child_id = u.id
+			WHERE $3::boolean AND u.generation < $2
+		), down AS (
+			SELECT $1::uuid AS id, 0 AS generation
+			UNION
+			SELECT l.child_id, d.generation + 1
+			FROM parent_links l
+			JOIN down d ON l.parent_id = d.id
+			WHERE $4::boolean AND d.generation < $2
+		), lineage AS (
+			SELECT id, -generation AS generation FROM up
+			UNION
+			SELECT id, generation FROM down
+		), spouse_links AS (
+			SELECT person1_id AS id, person2_id AS spouse_id FROM relationships WHERE relationship_type = 'spouse'
+			UNION
+			SELECT person2_id, person1_id FROM relationships WHERE relationship_type = 'spouse'
+		)
+		SELECT id, generation, false FROM lineage
+		UNION ALL
+		SELECT s.spouse_id, l.generation, true
+		FROM lineage l
+		JOIN spouse_links s ON s.id = l.id
+	`, root, depth, up, down)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Everyone on the lines keeps their closest generation; spouses only
+	// take their partner's when they aren't on the lines themselves
+	s := &Subtree{
+		Root:          root,
+		Generations:   map[uuid.UUID]int{},
+		parentCounts:  map[uuid.UUID]int{},
+		childCounts:   map[uuid.UUID]int{},
+		shownParents:  map[uuid.UUID]int{},
+		shownChildren: map[uuid.UUID]int{},
+	}
+	lineage := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var id uuid.UUID
+		var generation int
+		var spouse bool
+		if err := rows.Scan(&id, &generation, &spouse); err != nil {
+			return nil, err
+		}
+		g, seen := s.Generations[id]
+		switch {
+		case !seen:
+			s.Generations[id] = generation
+		case spouse && lineage[id]:
+		case !spouse && !lineage[id]:
+			s.Generations[id] = generation
+		case abs(generation) < abs(g):
+			s.Generations[id] = generation
+		}
+		if !spouse {
+			lineage[id] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(s.Generations))
+	for id := range s.Generations {
+		ids = append(ids, id.String())
+	}
+
+	rows, err = db.Query(`SELECT `+personColumns+` FROM people p WHERE p.id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPerson(rows)
+		if err != nil {
+			return nil, err
+		}
+		s.People = append(s.People, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(s.People, func(i, j int) bool {
+		a, b := s.People[i], s.People[j]
+		if s.Generations[a.ID] != s.Generations[b.ID] {
+			return s.Generations[a.ID] < s.Generations[b.ID]
+		}
+		if a.BirthDate.Valid != b.BirthDate.Valid {
+			return a.BirthDate.Valid
+		}
+		return a.BirthDate.Time.Before(b.BirthDate.Time)
+	})
+
+	// Every link touching the subtree: those inside it are returned, the
+	// rest tell which people have more family to expand
+	rows, err = db.Query(`
+		SELECT id, person1_id, person2_id, relationship_type, start_date, end_date
+		FROM relationships
+		WHERE person1_id = ANY($1::uuid[]) OR person2_id = ANY($1::uuid[])
+		ORDER BY created_at
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type pair struct{ child, parent uuid.UUID }
+	counted := map[pair]bool{}
+	for rows.Next() {
+		var r models.Relationship
+		if err := rows.Scan(&r.ID, &r.Person1ID, &r.Person2ID, &r.RelationshipType, &r.StartDate, &r.EndDate); err != nil {
+			return nil, err
+		}
+		_, in1 := s.Generations[r.Person1ID]
+		_, in2 := s.Generations[r.Person2ID]
+		if in1 && in2 {
+			s.Relationships = append(s.Relationships, r)
+		}
+
+		var link pair
+		switch r.RelationshipType {
+		case models.RelationshipChild:
+			link = pair{child: r.Person1ID, parent: r.Person2ID}
+		case models.RelationshipParent:
+			link = pair{child: r.Person2ID, parent: r.Person1ID}
+		default:
+			continue
+		}
+		if counted[link] {
+			continue
+		}
+		counted[link] = true
+		s.parentCounts[link.child]++
+		s.childCounts[link.parent]++
+		if in1 && in2 {
+			s.shownParents[link.child]++
+			s.shownChildren[link.parent]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,370 @@
+package family
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Issue severities: errors are rejected when a write introduces them,
+// warnings are reported
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue codes
+const (
+	IssueSelfRelationship = "self_relationship"
+	IssueDuplicate        = "duplicate_relationship"
+	IssueTooManyParents   = "too_many_parents"
+	IssueAncestorCycle    = "ancestor_cycle"
+	IssueParentYounger    = "parent_younger_than_child"
+	IssueParentTooYoung   = "parent_too_young"
+	IssueParentDied       = "parent_died_before_birth"
+	IssueDiedBeforeBorn   = "died_before_born"
+)
+
+// MinParentAge is the youngest a parent is expected to be at a child's
+// birth
+const MinParentAge = 12
+
+// Issue is something genealogically impossible or doubtful in the tree
+type Issue struct {
+	Severity        string      `json:"severity"`
+	Code            string      `json:"code"`
+	Message         string      `json:"message"`
+	People          []uuid.UUID `json:"people"`
+	RelationshipIDs []uuid.UUID `json:"relationship_ids,omitempty"`
+}
+
+// key identifies an issue independently of relationship rows, which are
+// replaced when a person's parents are saved again
+func (i Issue) key() string {
+	ids := make([]string, len(i.People))
+	for n, id := range i.People {
+		ids[n] = id.String()
+	}
+	sort.Strings(ids)
+	return i.Code + ":" + strings.Join(ids, ",")
+}
+
+// Validate checks the whole tree: links from people to themselves,
+// duplicated or mirrored relationship rows, more than two parents, people
+// who are their own ancestors, and birth and death dates that don't fit
+// between parents and children
+func (g *Graph) Validate() []Issue {
+	var issues []Issue
+	issues = append(issues, g.checkRows()...)
+	issues = append(issues, g.checkParents()...)
+	issues = append(issues, g.checkCycles()...)
+	issues = append(issues, g.checkDates()...)
+	return issues
+}
+
+// ValidatePeople returns the issues involving any of the given people
+func (g *Graph) ValidatePeople(ids ...uuid.UUID) []Issue {
+	want := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	var out []Issue
+	for _, issue := range g.Validate() {
+		for _, id := range issue.People {
+			if want[id] {
+				out = append(out, issue)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Check loads the given people's lines and returns the issues involving any
+// of them. Run it before and after a write in the same transaction, after
+// LockTree, and Introduced tells what the write broke.
+func Check(db querier, ids ...uuid.UUID) ([]Issue, error) {
+	g, err := LoadLines(db, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return g.ValidatePeople(ids...), nil
+}
+
+// treeLockKey names the advisory lock LockTree takes
+const treeLockKey = 0x6661726d696c79 // "farmily"
+
+// LockTree serializes checked writes to the tree for the rest of tx, so two
+// writes can't each pass Check against a tree the other is changing, e.g.
+// by adding the two halves of an ancestor loop. Reads are not blocked.
+func LockTree(tx *sql.Tx) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", treeLockKey)
+	return err
+}
+
+// Introduced returns the issues in after that weren't in before
+func Introduced(before, after []Issue) []Issue {
+	seen := map[string]bool{}
+	for _, issue := range before {
+		seen[issue.key()] = true
+	}
+	out := []Issue{}
+	for _, issue := range after {
+		if !seen[issue.key()] {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+// Errors returns the issues of error severity
+func Errors(issues []Issue) []Issue {
+	var out []Issue
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+// checkRows finds rows linking someone to themselves and rows repeating an
+// earlier one, including mirrored pairs such as A child of B and B parent
+// of A
+func (g *Graph) checkRows() []Issue {
+	var issues []Issue
+	first := map[string]models.Relationship{}
+	for _, r := range g.Relationships {
+		if g.People[r.Person1ID] == nil || g.People[r.Person2ID] == nil {
+			continue
+		}
+		if r.Person1ID == r.Person2ID {
+			issues = append(issues, Issue{
+				Severity:        SeverityError,
+				Code:            IssueSelfRelationship,
+				Message:         fmt.Sprintf("%s is recorded as their own %s", g.name(r.Person1ID), r.RelationshipType),
+				People:          []uuid.UUID{r.Person1ID},
+				RelationshipIDs: []uuid.UUID{r.ID},
+			})
+			continue
+		}
+
+		a, b := r.Person1ID, r.Person2ID
+		kind := r.RelationshipType
+		switch kind {
+		case models.RelationshipParent:
+			a, b = b, a
+			kind = models.RelationshipChild
+		case models.RelationshipSpouse, models.RelationshipSibling:
+			if b.String() < a.String() {
+				a, b = b, a
+			}
+		}
+		key := kind + a.String() + b.String()
+		prev, ok := first[key]
+		if !ok {
+			first[key] = r
+			continue
+		}
+
+		what := "spouses"
+		switch kind {
+		case models.RelationshipChild:
+			what = "child and parent"
+		case models.RelationshipSibling:
+			what = "siblings"
+		}
+		message := fmt.Sprintf("%s and %s are recorded as %s twice", g.name(a), g.name(b), what)
+		if prev.Person1ID != r.Person1ID || prev.RelationshipType != r.RelationshipType {
+			message += ", once from each side"
+		}
+		issues = append(issues, Issue{
+			Severity:        SeverityError,
+			Code:            IssueDuplicate,
+			Message:         message,
+			People:          []uuid.UUID{a, b},
+			RelationshipIDs: []uuid.UUID{prev.ID, r.ID},
+		})
+	}
+	return issues
+}
+
+// checkParents finds people with more than two parents
+func (g *Graph) checkParents() []Issue {
+	var issues []Issue
+	for _, id := range g.sortedIDs() {
+		parents := g.parents[id]
+		if len(parents) <= 2 {
+			continue
+		}
+		names := make([]string, len(parents))
+		for i, p := range parents {
+			names[i] = g.name(p)
+		}
+		issues = append(issues, Issue{
+			Severity: SeverityError,
+			Code:     IssueTooManyParents,
+			Message: fmt.Sprintf("%s has %d parents recorded: %s", g.name(id), len(parents),
+				strings.Join(names, ", ")),
+			People: append([]uuid.UUID{id}, parents...),
+		})
+	}
+	return issues
+}
+
+// checkCycles finds people who are their own ancestors, reporting each
+// loop once
+func (g *Graph) checkCycles() []Issue {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[uuid.UUID]int{}
+	var stack []uuid.UUID
+	var issues []Issue
+	reported := map[string]bool{}
+
+	var visit func(id uuid.UUID)
+	visit = func(id uuid.UUID) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, p := range g.parents[id] {
+			switch state[p] {
+			case unvisited:
+				visit(p)
+			case visiting:
+				// The stack from p back up to here is the loop, each an
+				// ancestor of the one before
+				var loop []uuid.UUID
+				for i := len(stack) - 1; i >= 0; i-- {
+					loop = append(loop, stack[i])
+					if stack[i] == p {
+						break
+					}
+				}
+				issue := Issue{Severity: SeverityError, Code: IssueAncestorCycle, People: loop}
+				if reported[issue.key()] {
+					continue
+				}
+				reported[issue.key()] = true
+				if len(loop) == 1 {
+					issue.Message = g.name(p) + " is recorded as their own parent"
+				} else {
+					names := make([]string, 0, len(loop)+1)
+					for i := len(loop) - 1; i >= 0; i-- {
+						names = append(names, g.name(loop[i]))
+					}
+					names = append(names, g.name(p))
+					issue.Message = g.name(p) + " is their own ancestor: " + strings.Join(names, ", child of ")
+				}
+				issues = append(issues, issue)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+
+	for _, id := range g.sortedIDs() {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return issues
+}
+
+// checkDates finds people who died before they were born, parents born
+// after or too soon before their children, and parents who died before a
+// child's birth: mothers at all, fathers more than ten months before
+func (g *Graph) checkDates() []Issue {
+	var issues []Issue
+	for _, id := range g.sortedIDs() {
+		p := g.People[id]
+		if p.BirthDate.Valid && p.DeathDate.Valid && p.DeathDate.Time.Before(p.BirthDate.Time) {
+			issues = append(issues, Issue{
+				Severity: SeverityError,
+				Code:     IssueDiedBeforeBorn,
+				Message: fmt.Sprintf("%s died on %s, before they were born on %s", g.name(id),
+					issueDate(p.DeathDate), issueDate(p.BirthDate)),
+				People: []uuid.UUID{id},
+			})
+		}
+
+		if !p.BirthDate.Valid {
+			continue
+		}
+		born := p.BirthDate.Time
+		for _, pid := range g.parents[id] {
+			parent := g.People[pid]
+			switch {
+			case !parent.BirthDate.Valid:
+			case !parent.BirthDate.Time.Before(born):
+				issues = append(issues, Issue{
+					Severity: SeverityError,
+					Code:     IssueParentYounger,
+					Message: fmt.Sprintf("%s was born on %s, not before their child %s on %s", g.name(pid),
+						issueDate(parent.BirthDate), g.name(id), issueDate(p.BirthDate)),
+					People: []uuid.UUID{pid, id},
+				})
+			default:
+				if age := parent.GetAgeAt(born); age != nil && *age < MinParentAge {
+					issues = append(issues, Issue{
+						Severity: SeverityWarning,
+						Code:     IssueParentTooYoung,
+						Message:  fmt.Sprintf("%s was %d when their child %s was born", g.name(pid), *age, g.name(id)),
+						People:   []uuid.UUID{pid, id},
+					})
+				}
+			}
+
+			if !parent.DeathDate.Valid {
+				continue
+			}
+			latest := parent.DeathDate.Time
+			if parent.Gender != models.GenderFemale {
+				latest = latest.AddDate(0, 10, 0)
+			}
+			if latest.Before(born) {
+				issues = append(issues, Issue{
+					Severity: SeverityError,
+					Code:     IssueParentDied,
+					Message: fmt.Sprintf("%s died on %s, before their child %s was born on %s", g.name(pid),
+						issueDate(parent.DeathDate), g.name(id), issueDate(p.BirthDate)),
+					People: []uuid.UUID{pid, id},
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// sortedIDs lists everyone by name, so reports come out in a stable order
+func (g *Graph) sortedIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(g.People))
+	for id := range g.People {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := g.name(ids[i]), g.name(ids[j])
+		if a != b {
+			return a < b
+		}
+		return ids[i].String() < ids[j].String()
+	})
+	return ids
+}
+
+func (g *Graph) name(id uuid.UUID) string {
+	if p := g.People[id]; p != nil {
+		return p.GetDisplayName()
+	}
+	return id.String()
+}
+
+func issueDate(d sql.NullTime) string {
+	return d.Time.Format("2 January 2006")
+}
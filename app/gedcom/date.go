@@ -0,0 +1,223 @@
+package gedcom
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date precisions
+const (
+	PrecisionDay   = "day"
+	PrecisionMonth = "month"
+	PrecisionYear  = "year"
+)
+
+var months = map[string]time.Month{
+	"JAN": time.January, "FEB": time.February, "MAR": time.March,
+	"APR": time.April, "MAY": time.May, "JUN": time.June,
+	"JUL": time.July, "AUG": time.August, "SEP": time.September,
+	"OCT": time.October, "NOV": time.November, "DEC": time.December,
+}
+
+// Date is a GEDCOM date reduced to a single calendar day. Approximate and
+// ranged dates keep their first date; Exact reports whether anything was lost.
+type Date struct {
+	Time      time.Time
+	Precision string
+	Qualifier string
+	Exact     bool
+}
+
+// French Republican months, numbered from 1. The five or six complementary
+// days at the end of the year count as a thirteenth month.
+var frenchMonths = map[string]int{
+	"VEND": 1, "BRUM": 2, "FRIM": 3, "NIVO": 4, "PLUV": 5, "VENT": 6,
+	"GERM": 7, "FLOR": 8, "PRAI": 9, "MESS": 10, "THER": 11, "FRUC": 12, "COMP": 13,
+}
+
+// frenchNewYears holds 1 Vendémiaire of years I to XV of the French
+// Republican calendar. It was only in use until the end of 1805, so later
+// years aren't converted.
+var frenchNewYears = []time.Time{
+	time.Date(1792, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1793, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1794, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1795, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1796, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1797, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1798, time.September, 22, 0, 0, 0, 0, time.UTC),
+	time.Date(1799, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1800, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1801, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1802, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1803, time.September, 24, 0, 0, 0, 0, time.UTC),
+	time.Date(1804, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1805, time.September, 23, 0, 0, 0, 0, time.UTC),
+	time.Date(1806, time.September, 23, 0, 0, 0, 0, time.UTC),
+}
+
+// Calendar escapes
+const (
+	calendarGregorian = "GREGORIAN"
+	calendarFrench    = "FRENCH R"
+)
+
+// ParseDate reads a GEDCOM date value such as "12 JAN 1900", "JAN 1900",
+// "ABT 1900" or "BET 1890 AND 1900". Dates with only a month or year fall on
+// the first day of that period. ok is false for values with no usable date,
+// such as "(unknown)".
+//
+// French Republican dates ("@#DFRENCH R@ 1 VEND 11") are converted to the
+// Gregorian calendar. Dates in other calendars keep their day, month and
+// year as written, with the calendar as their qualifier.
+func ParseDate(value string) (d Date, ok bool) {
+	calendar, rest := cutCalendar(strings.ToUpper(strings.TrimSpace(value)))
+	fields := strings.Fields(rest)
+
+	if len(fields) > 0 && isDateQualifier(fields[0]) {
+		d.Qualifier = fields[0]
+		// The calendar escape may follow the qualifier, e.g. "ABT @#DJULIAN@ 1700"
+		if c, rest := cutCalendar(strings.Join(fields[1:], " ")); c != "" {
+			calendar = c
+			fields = strings.Fields(rest)
+		} else {
+			fields = fields[1:]
+		}
+	}
+
+	// Keep the first date of a range or period
+	for i, f := range fields {
+		if f == "AND" || f == "TO" {
+			fields = fields[:i]
+			break
+		}
+	}
+
+	if n := len(fields); n > 0 {
+		// Years before the common era can't be stored
+		if fields[n-1] == "B.C." || fields[n-1] == "BCE" {
+			return Date{}, false
+		}
+		// 5.5.1 dual years like "1699/00" keep the first year
+		if year, _, found := strings.Cut(fields[n-1], "/"); found {
+			fields[n-1] = year
+		}
+	}
+
+	switch calendar {
+	case "", calendarGregorian:
+		d.Time, d.Precision, ok = gregorianDate(fields)
+	case calendarFrench:
+		d.Time, d.Precision, ok = frenchDate(fields)
+	default:
+		// Only the Gregorian calendar is stored as is
+		if d.Qualifier == "" {
+			d.Qualifier = calendar
+		}
+		d.Time, d.Precision, ok = gregorianDate(fields)
+	}
+	if !ok {
+		return Date{}, false
+	}
+
+	d.Exact = d.Qualifier == "" && d.Precision == PrecisionDay
+	return d, true
+}
+
+// cutCalendar splits a leading calendar escape such as "@#DJULIAN@" off s
+// and returns the calendar's name. Names can contain spaces ("FRENCH R"), so
+// the escape runs up to its closing "@". An unterminated escape is left in s.
+func cutCalendar(s string) (calendar, rest string) {
+	if !strings.HasPrefix(s, "@#D") {
+		return "", s
+	}
+	end := strings.IndexByte(s[3:], '@')
+	if end < 0 {
+		return "", s
+	}
+	return s[3 : 3+end], strings.TrimSpace(s[3+end+1:])
+}
+
+// gregorianDate reads "YEAR", "MON YEAR" or "DAY MON YEAR"
+func gregorianDate(fields []string) (time.Time, string, bool) {
+	switch len(fields) {
+	case 1:
+		year, err := strconv.Atoi(fields[0])
+		if err != nil || year < 1 {
+			return time.Time{}, "", false
+		}
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC), PrecisionYear, true
+	case 2:
+		month, okMonth := months[fields[0]]
+		year, err := strconv.Atoi(fields[1])
+		if !okMonth || err != nil || year < 1 {
+			return time.Time{}, "", false
+		}
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), PrecisionMonth, true
+	case 3:
+		day, errDay := strconv.Atoi(fields[0])
+		month, okMonth := months[fields[1]]
+		year, err := strconv.Atoi(fields[2])
+		if errDay != nil || !okMonth || err != nil || year < 1 || day < 1 || day > 31 {
+			return time.Time{}, "", false
+		}
+		t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		if t.Month() != month {
+			return time.Time{}, "", false
+		}
+		return t, PrecisionDay, true
+	}
+	return time.Time{}, "", false
+}
+
+// frenchDate reads a French Republican "YEAR", "MON YEAR" or "DAY MON YEAR"
+// and converts it to the Gregorian calendar. Every month has 30 days; the
+// complementary days run to the next 1 Vendémiaire.
+func frenchDate(fields []string) (time.Time, string, bool) {
+	if len(fields) < 1 || len(fields) > 3 {
+		return time.Time{}, "", false
+	}
+
+	year, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || year < 1 || year >= len(frenchNewYears) {
+		return time.Time{}, "", false
+	}
+	start, next := frenchNewYears[year-1], frenchNewYears[year]
+
+	month, day, precision := 1, 1, PrecisionYear
+	if len(fields) >= 2 {
+		var ok bool
+		month, ok = frenchMonths[fields[len(fields)-2]]
+		if !ok {
+			return time.Time{}, "", false
+		}
+		precision = PrecisionMonth
+	}
+	if len(fields) == 3 {
+		day, err = strconv.Atoi(fields[0])
+		if err != nil || day < 1 || day > 30 {
+			return time.Time{}, "", false
+		}
+		precision = PrecisionDay
+	}
+
+	t := start.AddDate(0, 0, (month-1)*30+day-1)
+	if !t.Before(next) {
+		return time.Time{}, "", false
+	}
+	return t, precision, true
+}
+
+func isDateQualifier(s string) bool {
+	switch s {
+	case "ABT", "CAL", "EST", "BEF", "AFT", "BET", "FROM", "TO", "INT":
+		return true
+	}
+	return false
+}
+
+// FormatDate writes t as an exact GEDCOM date, e.g. "2 JAN 1900"
+func FormatDate(t time.Time) string {
+	return strconv.Itoa(t.Day()) + " " + strings.ToUpper(t.Month().String()[:3]) + " " + strconv.Itoa(t.Year())
+}
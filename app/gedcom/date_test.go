@@ -0,0 +1,68 @@
+package gedcom
+
+import "testing"
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value     string
+		ok        bool
+		date      string
+		precision string
+		qualifier string
+		exact     bool
+	}{
+		{"12 JAN 1900", true, "1900-01-12", PrecisionDay, "", true},
+		{"12 jan 1900", true, "1900-01-12", PrecisionDay, "", true},
+		{"JAN 1900", true, "1900-01-01", PrecisionMonth, "", false},
+		{"1900", true, "1900-01-01", PrecisionYear, "", false},
+		{"ABT 1900", true, "1900-01-01", PrecisionYear, "ABT", false},
+		{"BET 1890 AND 1900", true, "1890-01-01", PrecisionYear, "BET", false},
+		{"FROM 3 MAR 1901 TO 1905", true, "1901-03-03", PrecisionDay, "FROM", false},
+		{"11 FEB 1699/00", true, "1699-02-11", PrecisionDay, "", true},
+		{"@#DGREGORIAN@ 2 JAN 1900", true, "1900-01-02", PrecisionDay, "", true},
+		{"@#DJULIAN@ 1 JAN 1700", true, "1700-01-01", PrecisionDay, "JULIAN", false},
+		{"ABT @#DJULIAN@ 1700", true, "1700-01-01", PrecisionYear, "ABT", false},
+
+		// French Republican dates, whose escape contains a space
+		{"@#DFRENCH R@ 1 VEND 11", true, "1802-09-23", PrecisionDay, "", true},
+		{"@#DFRENCH R@ 18 BRUM 8", true, "1799-11-09", PrecisionDay, "", true},
+		{"@#DFRENCH R@ 9 THER 2", true, "1794-07-27", PrecisionDay, "", true},
+		{"@#DFRENCH R@ FRIM 13", true, "1804-11-22", PrecisionMonth, "", false},
+		{"@#DFRENCH R@ 12", true, "1803-09-24", PrecisionYear, "", false},
+		{"ABT @#DFRENCH R@ 11 FRIM 13", true, "1804-12-02", PrecisionDay, "ABT", false},
+		{"@#DFRENCH R@ 6 COMP 3", true, "1795-09-22", PrecisionDay, "", true},
+		{"@#DFRENCH R@ 6 COMP 4", false, "", "", "", false},
+		{"@#DFRENCH R@ 31 VEND 11", false, "", "", "", false},
+		{"@#DFRENCH R@ 1 VEND 79", false, "", "", "", false},
+
+		{"@#DHEBREW@ 1 TSH 5600", false, "", "", "", false},
+		{"@#DFRENCH R 1 VEND 11", false, "", "", "", false},
+		{"31 FEB 1900", false, "", "", "", false},
+		{"100 B.C.", false, "", "", "", false},
+		{"(unknown)", false, "", "", "", false},
+		{"", false, "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		d, ok := ParseDate(tt.value)
+		if ok != tt.ok {
+			t.Errorf("ParseDate(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got := d.Time.Format("2006-01-02"); got != tt.date {
+			t.Errorf("ParseDate(%q) = %s, want %s", tt.value, got, tt.date)
+		}
+		if d.Precision != tt.precision {
+			t.Errorf("ParseDate(%q) precision = %q, want %q", tt.value, d.Precision, tt.precision)
+		}
+		if d.Qualifier != tt.qualifier {
+			t.Errorf("ParseDate(%q) qualifier = %q, want %q", tt.value, d.Qualifier, tt.qualifier)
+		}
+		if d.Exact != tt.exact {
+			t.Errorf("ParseDate(%q) exact = %v, want %v", tt.value, d.Exact, tt.exact)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package gedcom reads GEDCOM genealogy files into a tree of records.
+//
+// A GEDCOM file is a list of lines of the form
+//
+//	level [@XREF@] TAG [value]
+//
+// where each line belongs to the nearest preceding line with a lower level.
+// Level 0 lines start a record (HEAD, INDI, FAM, NOTE, TRLR, ...).
+package gedcom
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Record is a GEDCOM line and the lines nested under it
+type Record struct {
+	Level    int
+	XRef     string
+	Tag      string
+	Value    string
+	Line     int
+	Children []*Record
+}
+
+// File is a parsed GEDCOM file
+type File struct {
+	Header  *Record
+	Records []*Record
+
+	// Warnings are problems that did not stop parsing, such as a non UTF-8
+	// encoding
+	Warnings []string
+
+	xrefs map[string]*Record
+}
+
+// ParseError reports a line that isn't valid GEDCOM
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Parse reads a GEDCOM file. CONT and CONC continuation lines are folded into
+// the value of the line they continue. Files that aren't valid UTF-8 are read
+// as Latin-1, which covers the ANSI files most programs write.
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	f := &File{xrefs: map[string]*Record{}}
+	if !utf8.Valid(data) {
+		data = latin1ToUTF8(data)
+		f.Warnings = append(f.Warnings, "File is not UTF-8; read it as Latin-1 (ANSI)")
+	}
+
+	// stack[i] is the open record at level i
+	var stack []*Record
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimRight(strings.TrimLeft(scanner.Text(), " \t"), "\r")
+		if text == "" {
+			continue
+		}
+
+		rec, err := parseLine(text, lineNo)
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.Level > len(stack) {
+			return nil, &ParseError{lineNo, fmt.Sprintf("level %d skips a level", rec.Level)}
+		}
+		stack = stack[:rec.Level]
+
+		if rec.Level == 0 {
+			if rec.Tag == "HEAD" {
+				f.Header = rec
+			} else if rec.Tag != "TRLR" {
+				f.Records = append(f.Records, rec)
+			}
+			if rec.XRef != "" {
+				if _, dup := f.xrefs[rec.XRef]; dup {
+					return nil, &ParseError{lineNo, "duplicate record " + rec.XRef}
+				}
+				f.xrefs[rec.XRef] = rec
+			}
+			stack = append(stack, rec)
+			continue
+		}
+
+		parent := stack[rec.Level-1]
+		switch rec.Tag {
+		case "CONT":
+			parent.Value += "\n" + rec.Value
+		case "CONC":
+			parent.Value += rec.Value
+		default:
+			parent.Children = append(parent.Children, rec)
+		}
+		stack = append(stack, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Header == nil {
+		return nil, &ParseError{1, "missing HEAD record; this doesn't look like a GEDCOM file"}
+	}
+
+	return f, nil
+}
+
+// parseLine splits a line into level, optional cross-reference, tag and value
+func parseLine(text string, lineNo int) (*Record, error) {
+	levelStr, rest, _ := strings.Cut(text, " ")
+	level, err := strconv.Atoi(levelStr)
+	if err != nil || level < 0 {
+		return nil, &ParseError{lineNo, fmt.Sprintf("invalid level %q", levelStr)}
+	}
+
+	rec := &Record{Level: level, Line: lineNo}
+	rest = strings.TrimLeft(rest, " ")
+	if strings.HasPrefix(rest, "@") {
+		xref, after, _ := strings.Cut(rest, " ")
+		if len(xref) < 3 || !strings.HasSuffix(xref, "@") {
+			return nil, &ParseError{lineNo, fmt.Sprintf("invalid cross-reference %q", xref)}
+		}
+		rec.XRef = xref
+		rest = strings.TrimLeft(after, " ")
+	}
+
+	tag, value, _ := strings.Cut(rest, " ")
+	if tag == "" {
+		return nil, &ParseError{lineNo, "missing tag"}
+	}
+	rec.Tag = strings.ToUpper(tag)
+	rec.Value = value
+	if !rec.IsPointer() {
+		// Literal @ signs in text are doubled
+		rec.Value = strings.ReplaceAll(value, "@@", "@")
+	}
+
+	return rec, nil
+}
+
+// latin1ToUTF8 converts ISO-8859-1 bytes to UTF-8
+func latin1ToUTF8(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/8)
+	for _, b := range data {
+		buf.WriteRune(rune(b))
+	}
+	return buf.Bytes()
+}
+
+// Lookup returns the level 0 record with the given cross-reference, e.g. "@I1@"
+func (f *File) Lookup(xref string) *Record {
+	return f.xrefs[xref]
+}
+
+// RecordsOf returns the level 0 records with the given tag, in file order
+func (f *File) RecordsOf(tag string) []*Record {
+	var out []*Record
+	for _, r := range f.Records {
+		if r.Tag == tag {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Version returns the GEDCOM version declared in HEAD.GEDC.VERS, e.g. "5.5.1"
+func (f *File) Version() string {
+	if gedc := f.Header.First("GEDC"); gedc != nil {
+		return gedc.Text("VERS")
+	}
+	return ""
+}
+
+// First returns the first child with the given tag, or nil
+func (r *Record) First(tag string) *Record {
+	for _, c := range r.Children {
+		if c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// All returns every child with the given tag
+func (r *Record) All(tag string) []*Record {
+	var out []*Record
+	for _, c := range r.Children {
+		if c.Tag == tag {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Text returns the trimmed value of the first child with the given tag, or ""
+func (r *Record) Text(tag string) string {
+	if c := r.First(tag); c != nil {
+		return strings.TrimSpace(c.Value)
+	}
+	return ""
+}
+
+// IsPointer reports whether the value is a cross-reference to another record
+func (r *Record) IsPointer() bool {
+	v := strings.TrimSpace(r.Value)
+	return len(v) > 2 && strings.HasPrefix(v, "@") && strings.HasSuffix(v, "@") && v != "@VOID@"
+}
@@ -0,0 +1,50 @@
+package gedcom
+
+import "strings"
+
+// Name is a personal name split into its parts
+type Name struct {
+	Given   string
+	Surname string
+	Suffix  string
+}
+
+// ParseName splits a NAME value such as "John Michael /Smith/ Jr." into given
+// names, surname and suffix. GIVN and SURN sub-records, when present, win over
+// the value.
+func ParseName(rec *Record) Name {
+	var n Name
+
+	value := strings.TrimSpace(rec.Value)
+	if start := strings.Index(value, "/"); start >= 0 {
+		n.Given = strings.TrimSpace(value[:start])
+		rest := value[start+1:]
+		if end := strings.Index(rest, "/"); end >= 0 {
+			n.Surname = strings.TrimSpace(rest[:end])
+			n.Suffix = strings.TrimSpace(rest[end+1:])
+		} else {
+			n.Surname = strings.TrimSpace(rest)
+		}
+	} else {
+		n.Given = value
+	}
+
+	if givn := rec.Text("GIVN"); givn != "" {
+		n.Given = givn
+	}
+	if surn := rec.Text("SURN"); surn != "" {
+		n.Surname = surn
+	}
+	if nsfx := rec.Text("NSFX"); nsfx != "" {
+		n.Suffix = nsfx
+	}
+
+	n.Given = strings.Join(strings.Fields(n.Given), " ")
+	n.Surname = strings.Join(strings.Fields(n.Surname), " ")
+	return n
+}
+
+// FormatName writes a NAME value with the surname between slashes
+func FormatName(given, surname string) string {
+	return strings.TrimSpace(given + " /" + surname + "/")
+}
@@ -0,0 +1,141 @@
+package gedcom
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxValueLen is how much of a value goes on one line before the rest is
+// continued with CONC, keeping lines well under the 255 character limit
+const maxValueLen = 200
+
+// NewRecord returns a record with the given tag and value
+func NewRecord(tag, value string) *Record {
+	return &Record{Tag: tag, Value: value}
+}
+
+// Add appends a child record and returns it so its own children can be added
+func (r *Record) Add(tag, value string) *Record {
+	child := NewRecord(tag, value)
+	r.Children = append(r.Children, child)
+	return child
+}
+
+// AddIf appends a child record only when value is not empty
+func (r *Record) AddIf(tag, value string) {
+	if value != "" {
+		r.Add(tag, value)
+	}
+}
+
+// Versions the encoder can write
+const (
+	Version551 = "5.5.1"
+	Version70  = "7.0"
+)
+
+// encoder writes records in the line syntax of one GEDCOM version
+type encoder struct {
+	bw *bufio.Writer
+	v7 bool
+}
+
+// Encode writes the header, records and trailer of a GEDCOM 5.5.1 file.
+// Levels come from the nesting of the records; multi-line values are split
+// with CONT and long lines with CONC.
+func Encode(w io.Writer, header *Record, records []*Record) error {
+	return EncodeVersion(w, Version551, header, records)
+}
+
+// EncodeVersion writes a file in the given version's line syntax. GEDCOM 7.0
+// has no line length limit or CONC, and only doubles an @ that starts a value.
+func EncodeVersion(w io.Writer, version string, header *Record, records []*Record) error {
+	e := &encoder{bw: bufio.NewWriter(w), v7: version == Version70}
+
+	// Byte order mark, as GEDCOM files in UTF-8 conventionally begin with
+	e.bw.WriteString("\xef\xbb\xbf")
+
+	e.writeRecord(header, 0)
+	for _, rec := range records {
+		e.writeRecord(rec, 0)
+	}
+	e.bw.WriteString("0 TRLR\n")
+
+	return e.bw.Flush()
+}
+
+func (e *encoder) writeRecord(rec *Record, level int) {
+	// Literal @ signs are escaped line by line so a cut never splits a pair
+	escape := !rec.IsPointer()
+
+	lines := strings.Split(strings.ReplaceAll(rec.Value, "\r\n", "\n"), "\n")
+	e.writeLine(level, rec.XRef, rec.Tag, lines[0], escape)
+	for _, line := range lines[1:] {
+		e.writeLine(level+1, "", "CONT", line, escape)
+	}
+
+	for _, child := range rec.Children {
+		e.writeRecord(child, level+1)
+	}
+}
+
+// writeLine writes one logical line. In 5.5.1 a long line is continued with
+// CONC; continuations of a CONT line sit beside it rather than under it.
+func (e *encoder) writeLine(level int, xref, tag, value string, escape bool) {
+	if e.v7 {
+		e.writeRaw(level, xref, tag, value, escape)
+		return
+	}
+
+	concLevel := level + 1
+	if tag == "CONT" {
+		concLevel = level
+	}
+
+	first, rest := splitValue(value)
+	e.writeRaw(level, xref, tag, first, escape)
+	for rest != "" {
+		first, rest = splitValue(rest)
+		e.writeRaw(concLevel, "", "CONC", first, escape)
+	}
+}
+
+func (e *encoder) writeRaw(level int, xref, tag, value string, escape bool) {
+	if escape {
+		if e.v7 {
+			if strings.HasPrefix(value, "@") {
+				value = "@" + value
+			}
+		} else {
+			value = strings.ReplaceAll(value, "@", "@@")
+		}
+	}
+
+	e.bw.WriteString(strconv.Itoa(level))
+	if xref != "" {
+		e.bw.WriteString(" " + xref)
+	}
+	e.bw.WriteString(" " + tag)
+	if value != "" {
+		e.bw.WriteString(" " + value)
+	}
+	e.bw.WriteString("\n")
+}
+
+// splitValue cuts value after at most maxValueLen characters. Cuts avoid
+// falling next to a space, which some readers trim from CONC lines.
+func splitValue(value string) (string, string) {
+	if utf8.RuneCountInString(value) <= maxValueLen {
+		return value, ""
+	}
+
+	runes := []rune(value)
+	cut := maxValueLen
+	for cut > maxValueLen/2 && (runes[cut-1] == ' ' || runes[cut] == ' ') {
+		cut--
+	}
+	return string(runes[:cut]), string(runes[cut:])
+}
@@ -0,0 +1,88 @@
+package gedcomx
+
+import (
+	"farmily/app/gedcom"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date is a GEDCOM X date: the original text and its formal form, such as
+// "+1900-01-02", "A+1900" (about 1900) or "+1890/+1900" (a range)
+type Date struct {
+	Original string `json:"original,omitempty"`
+	Formal   string `json:"formal,omitempty"`
+}
+
+// NewDate returns an exact date for the given day
+func NewDate(t time.Time) *Date {
+	return &Date{
+		Original: gedcom.FormatDate(t),
+		Formal:   fmt.Sprintf("+%04d-%02d-%02d", t.Year(), t.Month(), t.Day()),
+	}
+}
+
+// Parse reduces the date to a single day, preferring the formal form and
+// falling back to reading the original as a GEDCOM date. Ranges keep their
+// first date and partial dates fall on the first day of the period; exact
+// reports whether anything was lost.
+func (d *Date) Parse() (t time.Time, exact, ok bool) {
+	if d == nil {
+		return time.Time{}, false, false
+	}
+	if t, exact, ok := parseFormal(d.Formal); ok {
+		return t, exact, true
+	}
+	if gd, ok := gedcom.ParseDate(d.Original); ok {
+		return gd.Time, gd.Exact, true
+	}
+	return time.Time{}, false, false
+}
+
+func parseFormal(formal string) (time.Time, bool, bool) {
+	exact := true
+
+	s := strings.TrimSpace(formal)
+	if strings.HasPrefix(s, "A") {
+		exact = false
+		s = s[1:]
+	}
+	if start, _, isRange := strings.Cut(s, "/"); isRange {
+		exact = false
+		s = start
+	}
+	// Times of day aren't stored
+	s, _, _ = strings.Cut(s, "T")
+
+	// Years before the common era can't be stored
+	if !strings.HasPrefix(s, "+") {
+		return time.Time{}, false, false
+	}
+
+	parts := strings.Split(s[1:], "-")
+	if len(parts) > 3 {
+		return time.Time{}, false, false
+	}
+	values := []int{0, 1, 1}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, false, false
+		}
+		values[i] = n
+	}
+	if len(parts) < 3 {
+		exact = false
+	}
+
+	year, month, day := values[0], values[1], values[2]
+	if year < 1 || month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false, false
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day {
+		return time.Time{}, false, false
+	}
+	return t, exact, true
+}
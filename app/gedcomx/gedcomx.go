@@ -0,0 +1,227 @@
+// Package gedcomx reads and writes the GEDCOM X JSON serialization
+// (application/x-gedcomx-v1+json). Only the parts of the model this app
+// stores are declared; anything else in a document is ignored.
+package gedcomx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MediaType is the media type of GEDCOM X JSON documents
+const MediaType = "application/x-gedcomx-v1+json"
+
+// Type URIs
+const (
+	GenderMale     = "http://gedcomx.org/Male"
+	GenderFemale   = "http://gedcomx.org/Female"
+	GenderUnknown  = "http://gedcomx.org/Unknown"
+	GenderIntersex = "http://gedcomx.org/Intersex"
+
+	NameBirth   = "http://gedcomx.org/BirthName"
+	NameMarried = "http://gedcomx.org/MarriedName"
+
+	PartGiven   = "http://gedcomx.org/Given"
+	PartSurname = "http://gedcomx.org/Surname"
+	PartSuffix  = "http://gedcomx.org/Suffix"
+
+	RelationshipCouple      = "http://gedcomx.org/Couple"
+	RelationshipParentChild = "http://gedcomx.org/ParentChild"
+
+	FactBirth      = "http://gedcomx.org/Birth"
+	FactDeath      = "http://gedcomx.org/Death"
+	FactMarriage   = "http://gedcomx.org/Marriage"
+	FactDivorce    = "http://gedcomx.org/Divorce"
+	FactEducation  = "http://gedcomx.org/Education"
+	FactOccupation = "http://gedcomx.org/Occupation"
+	FactRetirement = "http://gedcomx.org/Retirement"
+
+	IdentifierPrimary    = "http://gedcomx.org/Primary"
+	IdentifierPersistent = "http://gedcomx.org/Persistent"
+)
+
+// FactOther is the custom fact type written for events of no standard type
+const FactOther = "data:,Other"
+
+// Document is a GEDCOM X data set
+type Document struct {
+	Description   string         `json:"description,omitempty"`
+	Attribution   *Attribution   `json:"attribution,omitempty"`
+	Persons       []Person       `json:"persons,omitempty"`
+	Relationships []Relationship `json:"relationships,omitempty"`
+}
+
+// Attribution records who made a document and when, in milliseconds since
+// the Unix epoch
+type Attribution struct {
+	ChangeMessage string `json:"changeMessage,omitempty"`
+	Modified      int64  `json:"modified,omitempty"`
+}
+
+// Identifiers maps identifier types to values. Identifiers without a type
+// use the empty key.
+type Identifiers map[string][]string
+
+// UnmarshalJSON accepts both forms the spec allows for a value: a single
+// string or an array of strings
+func (ids *Identifiers) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*ids = Identifiers{}
+	for key, value := range raw {
+		var values []string
+		if err := json.Unmarshal(value, &values); err != nil {
+			var single string
+			if err := json.Unmarshal(value, &single); err != nil {
+				return fmt.Errorf("identifier %q: %w", key, err)
+			}
+			values = []string{single}
+		}
+		(*ids)[key] = values
+	}
+	return nil
+}
+
+// Values returns every identifier value, whatever its type
+func (ids Identifiers) Values() []string {
+	var values []string
+	for _, vs := range ids {
+		values = append(values, vs...)
+	}
+	return values
+}
+
+type Person struct {
+	ID          string      `json:"id,omitempty"`
+	Identifiers Identifiers `json:"identifiers,omitempty"`
+	Living      *bool       `json:"living,omitempty"`
+	Gender      *Gender     `json:"gender,omitempty"`
+	Names       []Name      `json:"names,omitempty"`
+	Facts       []Fact      `json:"facts,omitempty"`
+	Notes       []Note      `json:"notes,omitempty"`
+}
+
+type Gender struct {
+	Type string `json:"type"`
+}
+
+type Name struct {
+	Type      string     `json:"type,omitempty"`
+	NameForms []NameForm `json:"nameForms,omitempty"`
+}
+
+type NameForm struct {
+	FullText string     `json:"fullText,omitempty"`
+	Parts    []NamePart `json:"parts,omitempty"`
+}
+
+type NamePart struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+// Part returns the value of the name's first part of the given type
+func (n *Name) Part(partType string) string {
+	for _, form := range n.NameForms {
+		for _, part := range form.Parts {
+			if part.Type == partType {
+				return strings.TrimSpace(part.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// FullText returns the name's first full text form
+func (n *Name) FullText() string {
+	for _, form := range n.NameForms {
+		if t := strings.TrimSpace(form.FullText); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+type Fact struct {
+	ID          string          `json:"id,omitempty"`
+	Identifiers Identifiers     `json:"identifiers,omitempty"`
+	Type        string          `json:"type"`
+	Date        *Date           `json:"date,omitempty"`
+	Place       *PlaceReference `json:"place,omitempty"`
+	Value       string          `json:"value,omitempty"`
+	Notes       []Note          `json:"notes,omitempty"`
+}
+
+type PlaceReference struct {
+	Original string `json:"original,omitempty"`
+}
+
+type Note struct {
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+}
+
+type Relationship struct {
+	ID          string            `json:"id,omitempty"`
+	Identifiers Identifiers       `json:"identifiers,omitempty"`
+	Type        string            `json:"type"`
+	Person1     ResourceReference `json:"person1"`
+	Person2     ResourceReference `json:"person2"`
+	Facts       []Fact            `json:"facts,omitempty"`
+}
+
+// ResourceReference points at a person, usually in the same document as
+// "#<id>"
+type ResourceReference struct {
+	Resource   string `json:"resource,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+}
+
+// LocalID returns the ID of the person referred to within the document
+func (r ResourceReference) LocalID() string {
+	if r.ResourceID != "" {
+		return r.ResourceID
+	}
+	if id, ok := strings.CutPrefix(r.Resource, "#"); ok {
+		return id
+	}
+	return ""
+}
+
+// Ref returns a reference to the person with the given ID in the document
+func Ref(id string) ResourceReference {
+	return ResourceReference{Resource: "#" + id, ResourceID: id}
+}
+
+// ParseError is returned for input that is not a GEDCOM X JSON document
+type ParseError struct {
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// Decode reads a GEDCOM X JSON document
+func Decode(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, &ParseError{Message: err.Error()}
+	}
+	if len(doc.Persons) == 0 && len(doc.Relationships) == 0 {
+		return nil, &ParseError{Message: "document has no persons or relationships"}
+	}
+	return &doc, nil
+}
+
+// Encode writes doc as indented JSON
+func Encode(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
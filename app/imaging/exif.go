@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"io"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Metadata is what we read from a photo's EXIF block
+type Metadata struct {
+	TakenAt   *time.Time
+	Latitude  *float64
+	Longitude *float64
+}
+
+// ReadMetadata extracts the capture date and GPS position from a JPEG's EXIF
+// data. Files without EXIF return empty metadata rather than an error.
+func ReadMetadata(r io.Reader) Metadata {
+	var meta Metadata
+
+	x, err := exif.Decode(r)
+	if err != nil {
+		return meta
+	}
+
+	// Cameras with an unset clock write zeroes, which fail to parse
+	if taken, err := x.DateTime(); err == nil && taken.Year() > 1800 {
+		meta.TakenAt = &taken
+	}
+
+	if lat, long, err := x.LatLong(); err == nil && !(lat == 0 && long == 0) {
+		meta.Latitude = &lat
+		meta.Longitude = &long
+	}
+
+	return meta
+}
+
+// ReadOrientation returns a photo's EXIF orientation (1-8), or 1 when the
+// file has none
+func ReadOrientation(r io.Reader) int {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return 1
+	}
+
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	orientation, err := tag.Int(0)
+	if err != nil || orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
@@ -0,0 +1,182 @@
+// Package imaging decodes uploaded photos and produces resized JPEG variants
+// using only pure Go decoders.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register decoders for every image format media uploads accept
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// JPEGQuality is the quality resized variants are encoded with
+const JPEGQuality = 82
+
+// MaxPixels is the largest image, in pixels, we are willing to decode. A small
+// file can declare huge dimensions, and decoding allocates them all up front.
+const MaxPixels = 50_000_000
+
+// ErrTooManyPixels is returned for images larger than MaxPixels
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// CheckSize reads only the image header and fails with ErrTooManyPixels if
+// the image is larger than MaxPixels
+func CheckSize(r io.Reader) error {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return ErrTooManyPixels
+	}
+	return nil
+}
+
+// Decode reads an image in any supported format and turns it upright
+// according to its EXIF orientation. Images larger than MaxPixels are
+// rejected before any pixels are decoded.
+func Decode(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckSize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return Orient(img, ReadOrientation(bytes.NewReader(data))), nil
+}
+
+// Orient applies an EXIF orientation (1-8) so the image displays upright.
+// Unknown values and 1 return img as is.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		// Orientations 5-8 swap width and height
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				sx, sy = y, x
+			case 6: // needs 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored along the top-right diagonal
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs 90° counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// Fit scales img down so that neither side exceeds maxSide, keeping the
+// aspect ratio. Images that are already small enough are returned as is.
+func Fit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		h = h * maxSide / w
+		w = maxSide
+	} else {
+		w = w * maxSide / h
+		h = maxSide
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// Crop returns the part of img inside the rectangle given as fractions (0-1)
+// of its width and height
+func Crop(img image.Image, x, y, w, h float64) image.Image {
+	b := img.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(x*float64(b.Dx())),
+		b.Min.Y+int(y*float64(b.Dy())),
+		b.Min.X+int((x+w)*float64(b.Dx())),
+		b.Min.Y+int((y+h)*float64(b.Dy())),
+	).Intersect(b)
+	if rect.Empty() {
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// CenterSquare crops img to the largest centered square
+func CenterSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x := (b.Dx() - side) / 2
+	y := (b.Dy() - side) / 2
+	return Crop(img,
+		float64(x)/float64(b.Dx()), float64(y)/float64(b.Dy()),
+		float64(side)/float64(b.Dx()), float64(side)/float64(b.Dy()))
+}
+
+// EncodeJPEG flattens transparency onto white and encodes img as JPEG
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
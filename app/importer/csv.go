@@ -0,0 +1,527 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"farmily/app/gedcom"
+	"farmily/app/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FormatCSV is the report format of CSV imports
+const FormatCSV = "csv"
+
+// CSV fields: every people column, a key other rows refer to the person by,
+// and the people it refers to
+const (
+	CSVKey        = "key"
+	CSVFirstName  = "first_name"
+	CSVMiddleName = "middle_name"
+	CSVLastName   = "last_name"
+	CSVMaidenName = "maiden_name"
+	CSVGender     = "gender"
+	CSVBirthDate  = "birth_date"
+	CSVBirthPlace = "birth_place"
+	CSVDeathDate  = "death_date"
+	CSVDeathPlace = "death_place"
+	CSVIsLiving   = "is_living"
+	CSVOccupation = "occupation"
+	CSVBiography  = "biography"
+	CSVFather     = "father"
+	CSVMother     = "mother"
+	CSVSpouse     = "spouse"
+)
+
+// csvFields lists the fields with the other headers they are found under
+// when no mapping is given
+var csvFields = []struct {
+	Name    string
+	Aliases []string
+}{
+	{CSVKey, []string{"id", "ref"}},
+	{CSVFirstName, []string{"first", "given_name", "given_names"}},
+	{CSVMiddleName, []string{"middle", "middle_names"}},
+	{CSVLastName, []string{"last", "surname", "family_name"}},
+	{CSVMaidenName, []string{"maiden", "birth_surname"}},
+	{CSVGender, []string{"sex"}},
+	{CSVBirthDate, []string{"born", "date_of_birth", "dob"}},
+	{CSVBirthPlace, []string{"place_of_birth", "birthplace"}},
+	{CSVDeathDate, []string{"died", "date_of_death", "dod"}},
+	{CSVDeathPlace, []string{"place_of_death", "deathplace"}},
+	{CSVIsLiving, []string{"living", "alive"}},
+	{CSVOccupation, []string{"job", "profession"}},
+	{CSVBiography, []string{"bio", "notes"}},
+	{CSVFather, []string{"father_id", "father_key"}},
+	{CSVMother, []string{"mother_id", "mother_key"}},
+	{CSVSpouse, []string{"spouse_id", "spouse_key", "spouses"}},
+}
+
+// csvRequired are the fields a file must have a column for
+var csvRequired = []string{CSVFirstName, CSVLastName, CSVGender}
+
+// csvSizes are the column sizes of the text fields
+var csvSizes = map[string]int{
+	CSVFirstName:  100,
+	CSVMiddleName: 100,
+	CSVLastName:   100,
+	CSVMaidenName: 100,
+	CSVBirthPlace: 255,
+	CSVDeathPlace: 255,
+	CSVOccupation: 255,
+}
+
+// csvGenders maps the accepted gender values, lower-cased, to genders
+var csvGenders = map[string]string{
+	"male": models.GenderMale, "m": models.GenderMale, "man": models.GenderMale,
+	"female": models.GenderFemale, "f": models.GenderFemale, "woman": models.GenderFemale,
+	"other": models.GenderOther, "o": models.GenderOther, "unknown": models.GenderOther, "u": models.GenderOther,
+}
+
+var csvBools = map[string]bool{
+	"true": true, "yes": true, "y": true, "1": true, "living": true, "alive": true,
+	"false": false, "no": false, "n": false, "0": false, "deceased": false, "dead": false,
+}
+
+// CSVOptions controls a CSV import
+type CSVOptions struct {
+	// Mapping maps fields to the headers of the columns holding them. Fields
+	// left out are looked for under their own name or a common alias.
+	Mapping map[string]string
+	// Delimiter separates fields; by default it is guessed from the header
+	Delimiter rune
+	// DryRun checks the file and runs the import without committing it
+	DryRun bool
+}
+
+// RowError is a problem with one value of a CSV file. Row is the line number
+// in the file, counting the header as line 1.
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ErrInvalidMapping is returned when the column mapping doesn't fit the file
+var ErrInvalidMapping = errors.New("invalid column mapping")
+
+// csvRow is one person read from the file
+type csvRow struct {
+	line   int
+	key    string
+	person models.Person
+	// references to other rows' keys or to existing people's IDs
+	father, mother string
+	spouses        []string
+}
+
+// ImportCSV loads people from a CSV file with a header row. Father, mother
+// and spouse columns refer to other rows by their key column, or to people
+// already in the tree by ID; parents are linked the way the people API links
+// father_id and mother_id.
+//
+// Every row is checked first. If any value is invalid nothing is written and
+// the report lists the errors by row. A dry run goes on to run the import in
+// a transaction that is rolled back, so the report shows what would be
+// created.
+func ImportCSV(db *sql.DB, r io.Reader, userID uuid.NullUUID, opts CSVOptions) (*Report, error) {
+	report := newReport(FormatCSV)
+	report.DryRun = opts.DryRun
+
+	rows, err := readCSV(r, opts, report)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*csvRow{}
+	for _, row := range rows {
+		if row.key == "" {
+			continue
+		}
+		if first, dup := keys[row.key]; dup {
+			report.rowError(row.line, CSVKey, "key %q is already used on line %d", row.key, first.line)
+			continue
+		}
+		keys[row.key] = row
+	}
+
+	// References to rows in the file, or to people already in the tree
+	existing := map[string]uuid.UUID{}
+	check := func(row *csvRow, field, ref string) {
+		if ref == "" {
+			return
+		}
+		if ref == row.key {
+			report.rowError(row.line, field, "refers to the row itself")
+			return
+		}
+		if _, ok := keys[ref]; ok {
+			return
+		}
+		if id, err := uuid.Parse(ref); err == nil {
+			var found uuid.UUID
+			err := db.QueryRow("SELECT id FROM people WHERE id = $1", id).Scan(&found)
+			if err == nil {
+				existing[ref] = found
+				return
+			}
+		}
+		report.rowError(row.line, field, "%q is neither a key in the file nor a person's ID", ref)
+	}
+	for _, row := range rows {
+		check(row, CSVFather, row.father)
+		check(row, CSVMother, row.mother)
+		for _, spouse := range row.spouses {
+			check(row, CSVSpouse, spouse)
+		}
+		if row.father != "" && row.father == row.mother {
+			report.rowError(row.line, CSVMother, "father and mother are the same person")
+		}
+		if parent, ok := keys[row.father]; ok && parent.person.Gender == models.GenderFemale {
+			report.warn(row.line, "father %q is recorded as female", row.father)
+		}
+		if parent, ok := keys[row.mother]; ok && parent.person.Gender == models.GenderMale {
+			report.warn(row.line, "mother %q is recorded as male", row.mother)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		sort.SliceStable(report.Errors, func(i, j int) bool {
+			return report.Errors[i].Row < report.Errors[j].Row
+		})
+		report.finish()
+		return report, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	w := newWriter(tx, userID, report)
+
+	if err := writeCSV(w, rows, keys, existing); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := w.check(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if opts.DryRun {
+		err = tx.Rollback()
+	} else {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.finish()
+	return report, nil
+}
+
+// writeCSV creates the people, then links them
+func writeCSV(w *writer, rows []*csvRow, keys map[string]*csvRow, existing map[string]uuid.UUID) error {
+	ids := map[string]uuid.UUID{}
+	for ref, id := range existing {
+		ids[ref] = id
+	}
+
+	for _, row := range rows {
+		key := row.key
+		if key == "" {
+			key = fmt.Sprintf("line %d", row.line)
+		}
+		id, err := w.createPerson(key, &row.person)
+		if err != nil {
+			return err
+		}
+		if row.key != "" && keys[row.key] == row {
+			ids[row.key] = id
+		}
+	}
+
+	for _, row := range rows {
+		for _, parent := range []string{row.father, row.mother} {
+			if parent == "" {
+				continue
+			}
+			if _, err := w.linkParent(row.person.ID, ids[parent]); err != nil {
+				return err
+			}
+		}
+		for _, spouse := range row.spouses {
+			if _, err := w.linkSpouses(row.person.ID, ids[spouse], sql.NullTime{}, sql.NullTime{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readCSV reads and checks every row of the file
+func readCSV(r io.Reader, opts CSVOptions, report *Report) ([]*csvRow, error) {
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		header, _ := br.Peek(4096)
+		delimiter = guessDelimiter(header)
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, &RowError{Row: 1, Message: "the file is empty"}
+	} else if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns, err := mapColumns(header, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*csvRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		empty := true
+		for _, value := range record {
+			if strings.TrimSpace(value) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, readRow(line, value, report))
+	}
+
+	if len(rows) == 0 {
+		return nil, &RowError{Row: 2, Message: "the file has no rows"}
+	}
+	return rows, nil
+}
+
+// readRow checks one row and reads it into a person
+func readRow(line int, value func(string) string, report *Report) *csvRow {
+	row := &csvRow{
+		line:   line,
+		key:    value(CSVKey),
+		father: value(CSVFather),
+		mother: value(CSVMother),
+	}
+	for _, spouse := range strings.Split(value(CSVSpouse), ";") {
+		if spouse = strings.TrimSpace(spouse); spouse != "" {
+			row.spouses = append(row.spouses, spouse)
+		}
+	}
+
+	text := func(field string) string {
+		v := value(field)
+		if size := csvSizes[field]; size > 0 && utf8.RuneCountInString(v) > size {
+			report.rowError(line, field, "longer than %d characters", size)
+		}
+		return v
+	}
+	optional := func(field string) sql.NullString {
+		return nullString(text(field), 0)
+	}
+
+	p := &row.person
+	p.FirstName = text(CSVFirstName)
+	p.LastName = text(CSVLastName)
+	for _, field := range []string{CSVFirstName, CSVLastName} {
+		if value(field) == "" {
+			report.rowError(line, field, "is required")
+		}
+	}
+	p.MiddleName = optional(CSVMiddleName)
+	p.MaidenName = optional(CSVMaidenName)
+	p.BirthPlace = optional(CSVBirthPlace)
+	p.DeathPlace = optional(CSVDeathPlace)
+	p.Occupation = optional(CSVOccupation)
+	p.Biography = optional(CSVBiography)
+
+	gender := value(CSVGender)
+	if gender == "" {
+		report.rowError(line, CSVGender, "is required")
+	} else if g, ok := csvGenders[strings.ToLower(gender)]; ok {
+		p.Gender = g
+	} else {
+		report.rowError(line, CSVGender, "%q is not Male, Female or Other", gender)
+	}
+
+	p.BirthDate = readCSVDate(line, CSVBirthDate, value(CSVBirthDate), report)
+	p.DeathDate = readCSVDate(line, CSVDeathDate, value(CSVDeathDate), report)
+	if p.BirthDate.Valid && p.DeathDate.Valid && p.DeathDate.Time.Before(p.BirthDate.Time) {
+		report.rowError(line, CSVDeathDate, "is before the birth date")
+	}
+
+	p.IsLiving = !p.DeathDate.Valid && !p.DeathPlace.Valid
+	if living := value(CSVIsLiving); living != "" {
+		b, ok := csvBools[strings.ToLower(living)]
+		if !ok {
+			report.rowError(line, CSVIsLiving, "%q is not yes or no", living)
+		} else if b && !p.IsLiving {
+			report.rowError(line, CSVIsLiving, "is yes but a death is recorded")
+		}
+		p.IsLiving = b
+	}
+
+	return row
+}
+
+// readCSVDate reads a date written as 1900-01-02 or in GEDCOM style, such as
+// "2 JAN 1900" or "ABT 1900". Partial and approximate dates are stored as
+// their first day with a warning.
+func readCSVDate(line int, field, value string, report *Report) sql.NullTime {
+	if value == "" {
+		return sql.NullTime{}
+	}
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return sql.NullTime{Time: t, Valid: true}
+	}
+
+	d, ok := gedcom.ParseDate(value)
+	if !ok {
+		report.rowError(line, field, "%q is not a date; use YYYY-MM-DD", value)
+		return sql.NullTime{}
+	}
+	if !d.Exact {
+		report.warn(line, "%s %q stored as %s", field, value, d.Time.Format("2006-01-02"))
+	}
+	return sql.NullTime{Time: d.Time, Valid: true}
+}
+
+// mapColumns finds the column of each field: the mapped header, or else the
+// field's name or an alias. Headers are compared ignoring case, spaces and
+// punctuation.
+func mapColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	byHeader := map[string]int{}
+	for i, h := range header {
+		name := normalizeHeader(h)
+		if _, dup := byHeader[name]; !dup {
+			byHeader[name] = i
+		}
+	}
+
+	known := map[string]bool{}
+	for _, f := range csvFields {
+		known[f.Name] = true
+	}
+	for field, h := range mapping {
+		if !known[field] {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidMapping, field)
+		}
+		if _, ok := byHeader[normalizeHeader(h)]; !ok {
+			return nil, fmt.Errorf("%w: no column %q for %s", ErrInvalidMapping, h, field)
+		}
+	}
+
+	columns := map[string]int{}
+	for _, f := range csvFields {
+		if h, ok := mapping[f.Name]; ok {
+			columns[f.Name] = byHeader[normalizeHeader(h)]
+			continue
+		}
+		for _, name := range append([]string{f.Name}, f.Aliases...) {
+			if i, ok := byHeader[name]; ok {
+				columns[f.Name] = i
+				break
+			}
+		}
+	}
+
+	var missing []string
+	for _, field := range csvRequired {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &RowError{Row: 1, Message: "no column for " + strings.Join(missing, ", ")}
+	}
+	return columns, nil
+}
+
+// normalizeHeader turns "First Name" or "first-name" into "first_name"
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(h)) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+		} else {
+			underscore = true
+		}
+	}
+	return b.String()
+}
+
+// guessDelimiter picks whichever of comma, semicolon and tab is most common
+// in the header line
+func guessDelimiter(data []byte) rune {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	best, count := ',', bytes.Count(line, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+// csvError turns a CSV syntax error into a row error
+func csvError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return &RowError{Row: perr.Line, Message: perr.Err.Error()}
+	}
+	return err
+}
+
+func (e *RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("line %d: %s %s", e.Row, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Row, e.Message)
+}
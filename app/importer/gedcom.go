@@ -0,0 +1,509 @@
+package importer
+
+import (
+	"database/sql"
+	"farmily/app/gedcom"
+	"farmily/app/models"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FormatGEDCOM is the report format of GEDCOM imports
+const FormatGEDCOM = "gedcom"
+
+// MaxLifespan is how many years after their birth someone without a recorded
+// death is still presumed living
+const MaxLifespan = 110
+
+// individualEvents maps INDI event tags to event types. Tags mapped to
+// "other" keep their label in the description.
+var individualEvents = map[string]string{
+	"BIRT": models.EventBirth,
+	"DEAT": models.EventDeath,
+	"OCCU": models.EventEmployment,
+	"GRAD": models.EventGraduation,
+	"RETI": models.EventRetirement,
+	"EVEN": models.EventOther,
+	"CHR":  models.EventOther,
+	"BAPM": models.EventOther,
+	"BURI": models.EventOther,
+	"CREM": models.EventOther,
+	"RESI": models.EventOther,
+	"EMIG": models.EventOther,
+	"IMMI": models.EventOther,
+	"NATU": models.EventOther,
+	"CENS": models.EventOther,
+}
+
+var eventLabels = map[string]string{
+	"CHR":  "Christening",
+	"BAPM": "Baptism",
+	"BURI": "Burial",
+	"CREM": "Cremation",
+	"RESI": "Residence",
+	"EMIG": "Emigration",
+	"IMMI": "Immigration",
+	"NATU": "Naturalization",
+	"CENS": "Census",
+}
+
+// familyEvents maps FAM event tags to the event type recorded for each spouse
+var familyEvents = map[string]string{
+	"MARR": models.EventMarriage,
+	"DIV":  models.EventDivorce,
+	"EVEN": models.EventOther,
+}
+
+var familyEventVerbs = map[string]string{
+	"MARR": "Married",
+	"DIV":  "Divorced",
+	"EVEN": "With",
+}
+
+// Tags that only link records together or describe the file itself
+var structuralTags = map[string]bool{
+	"FAMC": true,
+	"FAMS": true,
+	"SUBM": true,
+	"SUBN": true,
+}
+
+// gedcomImport maps one parsed file onto the database
+type gedcomImport struct {
+	file   *gedcom.File
+	w      *writer
+	report *Report
+
+	people map[string]uuid.UUID
+	names  map[uuid.UUID]string
+}
+
+// ImportGEDCOM loads a GEDCOM 5.5.1 file: INDI records become people, FAM
+// records parent and spouse relationships, and individual and family events
+// become events. It runs in one transaction; a parse or database error leaves
+// nothing behind. Files that parse return a report even when some records
+// had problems.
+func ImportGEDCOM(db *sql.DB, r io.Reader, userID uuid.NullUUID) (*Report, error) {
+	file, err := gedcom.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport(FormatGEDCOM)
+	report.Version = file.Version()
+	for _, msg := range file.Warnings {
+		report.warn(0, "%s", msg)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gedcomImport{
+		file:   file,
+		w:      newWriter(tx, userID, report),
+		report: report,
+		people: map[string]uuid.UUID{},
+		names:  map[uuid.UUID]string{},
+	}
+
+	if err := g.run(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := g.w.check(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	report.finish()
+	return report, nil
+}
+
+func (g *gedcomImport) run() error {
+	// People first so families can refer to them
+	for _, rec := range g.file.RecordsOf("INDI") {
+		if err := g.importIndividual(rec); err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range g.file.Records {
+		switch rec.Tag {
+		case "INDI":
+		case "FAM":
+			if err := g.importFamily(rec); err != nil {
+				return err
+			}
+		case "NOTE", "SUBM", "SUBN":
+			// Shared notes are read where they are referenced
+		default:
+			g.report.skip(rec.Tag, rec.Line)
+		}
+	}
+
+	return nil
+}
+
+func (g *gedcomImport) importIndividual(rec *gedcom.Record) error {
+	var p models.Person
+	p.IsLiving = true
+	p.Gender = models.GenderOther
+	var christened sql.NullTime
+
+	g.readNames(rec, &p)
+
+	switch strings.ToUpper(rec.Text("SEX")) {
+	case "M":
+		p.Gender = models.GenderMale
+	case "F":
+		p.Gender = models.GenderFemale
+	}
+
+	// Vital facts go on the person; the first of each wins
+	for _, child := range rec.Children {
+		switch child.Tag {
+		case "BIRT":
+			if !p.BirthDate.Valid && !p.BirthPlace.Valid {
+				p.BirthDate = dateOf(child)
+				p.BirthPlace = nullString(child.Text("PLAC"), 255)
+			}
+		case "CHR", "BAPM":
+			if !christened.Valid {
+				christened = dateOf(child)
+			}
+		case "BURI", "CREM":
+			p.IsLiving = false
+		case "DEAT":
+			p.IsLiving = false
+			if !p.DeathDate.Valid && !p.DeathPlace.Valid {
+				p.DeathDate = dateOf(child)
+				p.DeathPlace = nullString(child.Text("PLAC"), 255)
+			}
+		case "OCCU":
+			if !p.Occupation.Valid {
+				p.Occupation = nullString(strings.TrimSpace(child.Value), 255)
+			}
+		}
+	}
+
+	// Most files only record deaths that are known, so anyone born too long
+	// ago is presumed dead. A christening stands in for a missing birth date.
+	born := p.BirthDate
+	if !born.Valid {
+		born = christened
+	}
+	if born.Valid && born.Time.Before(time.Now().AddDate(-MaxLifespan, 0, 0)) {
+		p.IsLiving = false
+	}
+
+	personID, err := g.w.createPerson(rec.XRef, &p)
+	if err != nil {
+		return err
+	}
+	if rec.XRef != "" {
+		g.people[rec.XRef] = personID
+	}
+	g.names[personID] = p.FirstName + " " + p.LastName
+
+	for _, child := range rec.Children {
+		switch child.Tag {
+		case "NAME", "SEX":
+			// Read above
+		case "NOTE":
+			if err := g.importNote(personID, child); err != nil {
+				return err
+			}
+		default:
+			if eventType, ok := individualEvents[child.Tag]; ok {
+				if err := g.importEvent(personID, eventType, child, "INDI", ""); err != nil {
+					return err
+				}
+			} else if !structuralTags[child.Tag] {
+				g.report.skip("INDI."+child.Tag, child.Line)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readNames fills in the person's names from their first NAME record. A
+// married name, given as a second NAME of TYPE married or a _MARNM
+// sub-record, becomes the last name and the birth surname the maiden name.
+func (g *gedcomImport) readNames(rec *gedcom.Record, p *models.Person) {
+	names := rec.All("NAME")
+	if len(names) == 0 {
+		g.report.warn(rec.Line, "INDI %s has no NAME; imported as Unknown", rec.XRef)
+		p.FirstName, p.LastName = "Unknown", "Unknown"
+		return
+	}
+
+	birth := gedcom.ParseName(names[0])
+	given := strings.Fields(birth.Given)
+	if len(given) > 0 {
+		p.FirstName = clip(given[0], 100)
+		p.MiddleName = nullString(strings.Join(given[1:], " "), 100)
+	} else {
+		p.FirstName = "Unknown"
+		g.report.warn(names[0].Line, "INDI %s has no given name; imported as Unknown", rec.XRef)
+	}
+	if birth.Surname != "" {
+		p.LastName = clip(birth.Surname, 100)
+	} else {
+		p.LastName = "Unknown"
+		g.report.warn(names[0].Line, "INDI %s has no surname; imported as Unknown", rec.XRef)
+	}
+	if birth.Suffix != "" {
+		g.report.warn(names[0].Line, "INDI %s name suffix %q was not imported", rec.XRef, birth.Suffix)
+	}
+
+	married := ""
+	if marnm := names[0].First("_MARNM"); marnm != nil {
+		married = gedcom.ParseName(marnm).Surname
+		if married == "" {
+			married = strings.TrimSpace(marnm.Value)
+		}
+	}
+	for _, name := range names[1:] {
+		if strings.EqualFold(name.Text("TYPE"), "married") {
+			married = gedcom.ParseName(name).Surname
+		} else {
+			g.report.skip("INDI.NAME", name.Line)
+		}
+	}
+	if married != "" && married != birth.Surname {
+		p.MaidenName = nullString(p.LastName, 100)
+		p.LastName = clip(married, 100)
+	}
+
+	for _, child := range names[0].Children {
+		switch child.Tag {
+		case "GIVN", "SURN", "NSFX", "TYPE", "_MARNM":
+		default:
+			g.report.skip("INDI.NAME."+child.Tag, child.Line)
+		}
+	}
+}
+
+// dateOf returns an event's DATE, or null if it has none that can be read
+func dateOf(event *gedcom.Record) sql.NullTime {
+	if d, ok := gedcom.ParseDate(event.Text("DATE")); ok {
+		return sql.NullTime{Time: d.Time, Valid: true}
+	}
+	return sql.NullTime{}
+}
+
+// readDate reads an event's DATE, warning when it had to be approximated
+func (g *gedcomImport) readDate(event *gedcom.Record, context string) sql.NullTime {
+	dateRec := event.First("DATE")
+	if dateRec == nil || strings.TrimSpace(dateRec.Value) == "" {
+		return sql.NullTime{}
+	}
+
+	d, ok := gedcom.ParseDate(dateRec.Value)
+	if !ok {
+		g.report.warn(dateRec.Line, "%s date %q could not be read; left empty", context, dateRec.Value)
+		return sql.NullTime{}
+	}
+	if !d.Exact {
+		g.report.warn(dateRec.Line, "%s date %q stored as %s", context, dateRec.Value, d.Time.Format("2006-01-02"))
+	}
+	return sql.NullTime{Time: d.Time, Valid: true}
+}
+
+// importEvent creates an event for the person from an event record. extra is
+// prepended to the description, e.g. the spouse of a marriage.
+func (g *gedcomImport) importEvent(personID uuid.UUID, eventType string, rec *gedcom.Record, recordTag, extra string) error {
+	e := models.Event{
+		PersonID:   personID,
+		EventType:  eventType,
+		EventDate:  g.readDate(rec, recordTag+" "+rec.Tag),
+		EventPlace: nullString(rec.Text("PLAC"), 255),
+	}
+
+	var parts []string
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	if label := eventLabels[rec.Tag]; label != "" {
+		parts = append(parts, label)
+	}
+	if t := rec.Text("TYPE"); t != "" {
+		parts = append(parts, t)
+	}
+	// The value of most events is just "Y"; OCCU and EVEN carry text
+	if v := strings.TrimSpace(rec.Value); v != "" && !strings.EqualFold(v, "Y") {
+		parts = append(parts, v)
+	}
+	if note := g.noteText(rec); note != "" {
+		parts = append(parts, note)
+	}
+	e.Description = nullString(strings.Join(parts, ": "), 0)
+
+	for _, child := range rec.Children {
+		switch child.Tag {
+		case "DATE", "PLAC", "TYPE", "NOTE":
+		default:
+			g.report.skip(recordTag+"."+rec.Tag+"."+child.Tag, child.Line)
+		}
+	}
+
+	return g.w.createEvent(&e)
+}
+
+func (g *gedcomImport) importFamily(rec *gedcom.Record) error {
+	husband, hok := g.pointer(rec, "HUSB")
+	wife, wok := g.pointer(rec, "WIFE")
+
+	var parents []uuid.UUID
+	if hok {
+		parents = append(parents, husband)
+	}
+	if wok {
+		parents = append(parents, wife)
+	}
+
+	for _, childRec := range rec.All("CHIL") {
+		child, ok := g.resolve(childRec)
+		if !ok {
+			continue
+		}
+		for _, parent := range parents {
+			linked, err := g.w.linkParent(child, parent)
+			if err != nil {
+				return err
+			}
+			if !linked && child == parent {
+				g.report.warn(childRec.Line, "FAM %s lists a parent as their own child; link skipped", rec.XRef)
+			}
+		}
+	}
+
+	var marriage, divorce sql.NullTime
+	if marr := rec.First("MARR"); marr != nil {
+		marriage = dateOf(marr)
+	}
+	if div := rec.First("DIV"); div != nil {
+		divorce = dateOf(div)
+	}
+
+	if hok && wok {
+		if husband == wife {
+			g.report.warn(rec.Line, "FAM %s has the same person as both spouses; marriage skipped", rec.XRef)
+		} else if _, err := g.w.linkSpouses(husband, wife, marriage, divorce); err != nil {
+			return err
+		}
+	}
+
+	// Family events and notes are recorded for each spouse, naming the other
+	type spouse struct {
+		ID      uuid.UUID
+		Partner string
+	}
+	var spouses []spouse
+	if hok {
+		s := spouse{ID: husband}
+		if wok && wife != husband {
+			s.Partner = g.names[wife]
+		}
+		spouses = append(spouses, s)
+	}
+	if wok && (!hok || wife != husband) {
+		s := spouse{ID: wife}
+		if hok {
+			s.Partner = g.names[husband]
+		}
+		spouses = append(spouses, s)
+	}
+
+	for _, child := range rec.Children {
+		switch child.Tag {
+		case "HUSB", "WIFE", "CHIL":
+		case "MARR", "DIV", "EVEN":
+			for _, s := range spouses {
+				extra := ""
+				if s.Partner != "" {
+					extra = familyEventVerbs[child.Tag] + " " + s.Partner
+				}
+				if err := g.importEvent(s.ID, familyEvents[child.Tag], child, "FAM", extra); err != nil {
+					return err
+				}
+			}
+		case "NOTE":
+			for _, s := range spouses {
+				if err := g.importNote(s.ID, child); err != nil {
+					return err
+				}
+			}
+		default:
+			g.report.skip("FAM."+child.Tag, child.Line)
+		}
+	}
+
+	return nil
+}
+
+// pointer resolves the first child with the given tag to an imported person
+func (g *gedcomImport) pointer(rec *gedcom.Record, tag string) (uuid.UUID, bool) {
+	child := rec.First(tag)
+	if child == nil {
+		return uuid.Nil, false
+	}
+	return g.resolve(child)
+}
+
+// resolve returns the person a pointer record refers to
+func (g *gedcomImport) resolve(rec *gedcom.Record) (uuid.UUID, bool) {
+	xref := strings.TrimSpace(rec.Value)
+	if xref == "@VOID@" {
+		return uuid.Nil, false
+	}
+	id, ok := g.people[xref]
+	if !ok {
+		g.report.warn(rec.Line, "%s points to %s, which is not an individual in this file", rec.Tag, xref)
+	}
+	return id, ok
+}
+
+// noteText returns the text of a record's notes, following pointers to
+// shared NOTE records
+func (g *gedcomImport) noteText(rec *gedcom.Record) string {
+	var texts []string
+	for _, note := range rec.All("NOTE") {
+		if t := g.resolveNote(note); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func (g *gedcomImport) resolveNote(note *gedcom.Record) string {
+	if !note.IsPointer() {
+		return strings.TrimSpace(note.Value)
+	}
+
+	xref := strings.TrimSpace(note.Value)
+	shared := g.file.Lookup(xref)
+	if shared == nil || shared.Tag != "NOTE" {
+		g.report.warn(note.Line, "NOTE points to %s, which is not a note in this file", xref)
+		return ""
+	}
+	return strings.TrimSpace(shared.Value)
+}
+
+func (g *gedcomImport) importNote(personID uuid.UUID, note *gedcom.Record) error {
+	text := g.resolveNote(note)
+	if text == "" {
+		return nil
+	}
+	return g.w.createNote(personID, text)
+}
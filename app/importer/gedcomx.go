@@ -0,0 +1,458 @@
+package importer
+
+import (
+	"database/sql"
+	"farmily/app/gedcomx"
+	"farmily/app/models"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// FormatGEDCOMX is the report format of GEDCOM X imports
+const FormatGEDCOMX = "gedcomx"
+
+// factEvents maps GEDCOM X fact types to event types. Other types become
+// "other" events labelled with the type's name.
+var factEvents = map[string]string{
+	gedcomx.FactBirth:      models.EventBirth,
+	gedcomx.FactDeath:      models.EventDeath,
+	gedcomx.FactMarriage:   models.EventMarriage,
+	gedcomx.FactDivorce:    models.EventDivorce,
+	gedcomx.FactEducation:  models.EventGraduation,
+	gedcomx.FactOccupation: models.EventEmployment,
+	gedcomx.FactRetirement: models.EventRetirement,
+	gedcomx.FactOther:      models.EventOther,
+}
+
+// gedcomxImport maps one document onto the database
+type gedcomxImport struct {
+	doc    *gedcomx.Document
+	w      *writer
+	report *Report
+
+	// people by their ID in the document
+	people map[string]uuid.UUID
+}
+
+// GEDCOMXOptions controls how a GEDCOM X document is matched to existing rows
+type GEDCOMXOptions struct {
+	// Source names where the document comes from, such as the program or
+	// tree it was exported from. Person and fact IDs are only unique within
+	// a document, so they are matched against earlier imports from the same
+	// source, and ignored for matching when Source is empty.
+	Source string
+}
+
+// ImportGEDCOMX loads a GEDCOM X JSON document: persons become people with
+// their facts as events, and Couple and ParentChild relationships become
+// spouse and parent links.
+//
+// Persons and facts are matched to existing rows by their identifiers: our
+// own UUIDs, persistent identifiers mapped by an earlier import, and, with
+// a source, document IDs mapped by an earlier import from that source.
+// Matched rows are updated rather than created again, and new rows have
+// their IDs mapped, so a document can be exported, edited and imported
+// again, or imported repeatedly from another program, without duplicating
+// anyone.
+func ImportGEDCOMX(db *sql.DB, r io.Reader, userID uuid.NullUUID, opts GEDCOMXOptions) (*Report, error) {
+	doc, err := gedcomx.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport(FormatGEDCOMX)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gedcomxImport{
+		doc:    doc,
+		w:      newWriter(tx, userID, report),
+		report: report,
+		people: map[string]uuid.UUID{},
+	}
+	g.w.source = strings.TrimSpace(opts.Source)
+
+	if err := g.run(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := g.w.check(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	report.finish()
+	return report, nil
+}
+
+func (g *gedcomxImport) run() error {
+	for i := range g.doc.Persons {
+		if err := g.importPerson(i, &g.doc.Persons[i]); err != nil {
+			return err
+		}
+	}
+	for i := range g.doc.Relationships {
+		if err := g.importRelationship(i, &g.doc.Relationships[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// externalIDs lists the ways a person or fact can be referred to: its
+// identifiers and, as "#<id>", its ID in the document
+func externalIDs(id string, identifiers gedcomx.Identifiers) []string {
+	ids := identifiers.Values()
+	if id != "" {
+		ids = append(ids, "#"+id)
+	}
+	return ids
+}
+
+func (g *gedcomxImport) importPerson(index int, gp *gedcomx.Person) error {
+	key := gp.ID
+	if key == "" {
+		key = fmt.Sprintf("persons[%d]", index)
+	}
+
+	var p models.Person
+	g.readNames(key, gp, &p)
+
+	p.Gender = models.GenderOther
+	if gp.Gender != nil {
+		switch gp.Gender.Type {
+		case gedcomx.GenderMale:
+			p.Gender = models.GenderMale
+		case gedcomx.GenderFemale:
+			p.Gender = models.GenderFemale
+		}
+	}
+
+	// The first birth, death and occupation facts go on the person. Those
+	// without IDs carry nothing else and don't become events.
+	vital := map[int]bool{}
+	seen := map[string]bool{}
+	for i, fact := range gp.Facts {
+		if seen[fact.Type] {
+			continue
+		}
+		onlyVital := fact.ID == "" && len(fact.Identifiers) == 0
+
+		// Facts that also become events warn about their dates there
+		date := func(context string) sql.NullTime {
+			if onlyVital {
+				return g.readDate(fact.Date, key+" "+context)
+			}
+			t, _, ok := fact.Date.Parse()
+			return sql.NullTime{Time: t, Valid: ok}
+		}
+
+		switch fact.Type {
+		case gedcomx.FactBirth:
+			p.BirthDate = date("birth")
+			p.BirthPlace = nullString(placeOf(fact.Place), 255)
+		case gedcomx.FactDeath:
+			p.DeathDate = date("death")
+			p.DeathPlace = nullString(placeOf(fact.Place), 255)
+		case gedcomx.FactOccupation:
+			p.Occupation = nullString(strings.TrimSpace(fact.Value), 255)
+		default:
+			continue
+		}
+		seen[fact.Type] = true
+		vital[i] = onlyVital
+	}
+
+	p.IsLiving = !seen[gedcomx.FactDeath]
+	if gp.Living != nil {
+		p.IsLiving = *gp.Living
+	}
+
+	var notes []string
+	for _, note := range gp.Notes {
+		text := strings.TrimSpace(note.Text)
+		if text == "" {
+			continue
+		}
+		if strings.EqualFold(note.Subject, "Biography") && !p.Biography.Valid {
+			p.Biography = nullString(text, 0)
+		} else {
+			notes = append(notes, text)
+		}
+	}
+
+	ids := externalIDs(gp.ID, gp.Identifiers)
+	personID, found, err := g.w.resolve(models.ExternalPerson, ids)
+	if err != nil {
+		return err
+	}
+	if found {
+		p.ID = personID
+		err = g.w.updatePerson(key, &p)
+	} else {
+		personID, err = g.w.createPerson(key, &p)
+	}
+	if err != nil {
+		return err
+	}
+	if err := g.w.mapIDs(models.ExternalPerson, personID, ids); err != nil {
+		return err
+	}
+	if gp.ID != "" {
+		if _, dup := g.people[gp.ID]; dup {
+			g.report.warn(0, "person ID %q is used more than once; relationships use the first", gp.ID)
+		} else {
+			g.people[gp.ID] = personID
+		}
+	}
+
+	for i := range gp.Facts {
+		if !vital[i] {
+			if err := g.importFact(key, personID, &gp.Facts[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, text := range notes {
+		// Notes have no IDs, so one already on the person isn't added again
+		if found {
+			var exists bool
+			err := g.w.tx.QueryRow(
+				"SELECT EXISTS(SELECT 1 FROM notes WHERE person_id = $1 AND content = $2 AND deleted_at IS NULL)", personID, text,
+			).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+		}
+		if err := g.w.createNote(personID, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readNames fills in the person's names from their birth name, or first
+// name of no other type. A married name becomes the last name and the birth
+// surname the maiden name.
+func (g *gedcomxImport) readNames(key string, gp *gedcomx.Person, p *models.Person) {
+	var birth, married *gedcomx.Name
+	for i := range gp.Names {
+		name := &gp.Names[i]
+		switch name.Type {
+		case gedcomx.NameMarried:
+			if married == nil {
+				married = name
+			}
+		case gedcomx.NameBirth, "":
+			if birth == nil {
+				birth = name
+			}
+		default:
+			g.report.skip("names."+typeName(name.Type), 0)
+		}
+	}
+	if birth == nil && len(gp.Names) > 0 {
+		birth = &gp.Names[0]
+	}
+
+	given, surname := "", ""
+	if birth != nil {
+		given, surname = nameParts(birth)
+		if suffix := birth.Part(gedcomx.PartSuffix); suffix != "" {
+			g.report.warn(0, "person %s name suffix %q was not imported", key, suffix)
+		}
+	}
+
+	fields := strings.Fields(given)
+	if len(fields) > 0 {
+		p.FirstName = clip(fields[0], 100)
+		p.MiddleName = nullString(strings.Join(fields[1:], " "), 100)
+	} else {
+		p.FirstName = "Unknown"
+		g.report.warn(0, "person %s has no given name; imported as Unknown", key)
+	}
+	if surname != "" {
+		p.LastName = clip(surname, 100)
+	} else {
+		p.LastName = "Unknown"
+		g.report.warn(0, "person %s has no surname; imported as Unknown", key)
+	}
+
+	if married != nil && married != birth {
+		if _, marriedSurname := nameParts(married); marriedSurname != "" && marriedSurname != surname {
+			p.MaidenName = nullString(p.LastName, 100)
+			p.LastName = clip(marriedSurname, 100)
+		}
+	}
+}
+
+// nameParts returns a name's given names and surname, splitting the full
+// text at its last space when the name has no parts
+func nameParts(name *gedcomx.Name) (given, surname string) {
+	given, surname = name.Part(gedcomx.PartGiven), name.Part(gedcomx.PartSurname)
+	if given == "" && surname == "" {
+		full := name.FullText()
+		if i := strings.LastIndex(full, " "); i >= 0 {
+			return strings.TrimSpace(full[:i]), strings.TrimSpace(full[i+1:])
+		}
+		return full, ""
+	}
+	return given, surname
+}
+
+// importFact creates or updates the event for a fact
+func (g *gedcomxImport) importFact(key string, personID uuid.UUID, fact *gedcomx.Fact) error {
+	e := models.Event{
+		PersonID:   personID,
+		EventType:  factEvents[fact.Type],
+		EventDate:  g.readDate(fact.Date, key+" "+typeName(fact.Type)),
+		EventPlace: nullString(placeOf(fact.Place), 255),
+	}
+
+	var parts []string
+	if e.EventType == "" {
+		e.EventType = models.EventOther
+		parts = append(parts, typeName(fact.Type))
+	}
+	if v := strings.TrimSpace(fact.Value); v != "" {
+		parts = append(parts, v)
+	}
+	for _, note := range fact.Notes {
+		if text := strings.TrimSpace(note.Text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	e.Description = nullString(strings.Join(parts, ": "), 0)
+
+	ids := externalIDs(fact.ID, fact.Identifiers)
+	eventID, found, err := g.w.resolve(models.ExternalEvent, ids)
+	if err != nil {
+		return err
+	}
+	if found {
+		e.ID = eventID
+		err = g.w.updateEvent(&e)
+	} else {
+		err = g.w.createEvent(&e)
+	}
+	if err != nil {
+		return err
+	}
+	return g.w.mapIDs(models.ExternalEvent, e.ID, ids)
+}
+
+func (g *gedcomxImport) importRelationship(index int, rel *gedcomx.Relationship) error {
+	person1, ok1 := g.people[rel.Person1.LocalID()]
+	person2, ok2 := g.people[rel.Person2.LocalID()]
+	if !ok1 || !ok2 {
+		g.report.warn(0, "relationships[%d] refers to a person not in the document; skipped", index)
+		return nil
+	}
+
+	switch rel.Type {
+	case gedcomx.RelationshipCouple:
+		var marriage, divorce sql.NullTime
+		for _, fact := range rel.Facts {
+			switch fact.Type {
+			case gedcomx.FactMarriage:
+				if !marriage.Valid {
+					marriage = g.readDate(fact.Date, fmt.Sprintf("relationships[%d] marriage", index))
+				}
+			case gedcomx.FactDivorce:
+				if !divorce.Valid {
+					divorce = g.readDate(fact.Date, fmt.Sprintf("relationships[%d] divorce", index))
+				}
+			default:
+				g.report.skip("Couple."+typeName(fact.Type), 0)
+			}
+		}
+		if person1 == person2 {
+			g.report.warn(0, "relationships[%d] has the same person as both spouses; skipped", index)
+			return nil
+		}
+		_, err := g.w.linkSpouses(person1, person2, marriage, divorce)
+		return err
+	case gedcomx.RelationshipParentChild:
+		for _, fact := range rel.Facts {
+			g.report.skip("ParentChild."+typeName(fact.Type), 0)
+		}
+		linked, err := g.w.linkParent(person2, person1)
+		if err == nil && !linked && person1 == person2 {
+			g.report.warn(0, "relationships[%d] lists a person as their own parent; skipped", index)
+		}
+		return err
+	default:
+		g.report.skip(typeName(rel.Type), 0)
+		return nil
+	}
+}
+
+// readDate reads a fact's date, warning when it had to be approximated
+func (g *gedcomxImport) readDate(d *gedcomx.Date, context string) sql.NullTime {
+	if d == nil || (d.Formal == "" && strings.TrimSpace(d.Original) == "") {
+		return sql.NullTime{}
+	}
+
+	value := d.Original
+	if value == "" {
+		value = d.Formal
+	}
+
+	t, exact, ok := d.Parse()
+	if !ok {
+		g.report.warn(0, "%s date %q could not be read; left empty", context, value)
+		return sql.NullTime{}
+	}
+	if !exact {
+		g.report.warn(0, "%s date %q stored as %s", context, value, t.Format("2006-01-02"))
+	}
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func placeOf(place *gedcomx.PlaceReference) string {
+	if place == nil {
+		return ""
+	}
+	return strings.TrimSpace(place.Original)
+}
+
+// typeName turns a type URI into a label: "http://gedcomx.org/MilitaryService"
+// becomes "Military Service" and "data:,Baptism" "Baptism"
+func typeName(uri string) string {
+	if name, ok := strings.CutPrefix(uri, "data:,"); ok {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			return unescaped
+		}
+		return name
+	}
+
+	name, ok := strings.CutPrefix(uri, "http://gedcomx.org/")
+	if !ok {
+		return uri
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+// Package importer loads family data from other genealogy programs. Every
+// import runs in a single transaction: either the whole file is loaded or,
+// on any error, nothing is.
+package importer
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Report describes what an import created or updated and what it couldn't
+// map
+type Report struct {
+	Format  string `json:"format"`
+	Version string `json:"version,omitempty"`
+	// DryRun reports that nothing was committed
+	DryRun   bool          `json:"dry_run,omitempty"`
+	Created  RowIDs        `json:"created"`
+	Updated  RowIDs        `json:"updated"`
+	Unmapped []UnmappedTag `json:"unmapped"`
+	Warnings []string      `json:"warnings"`
+	// Errors lists invalid values that stopped the import
+	Errors []RowError `json:"errors,omitempty"`
+
+	unmapped map[string]*UnmappedTag
+}
+
+// RowIDs lists rows an import inserted or updated. People are keyed by their
+// identifier in the source file.
+type RowIDs struct {
+	People        map[string]uuid.UUID `json:"people"`
+	Relationships []uuid.UUID          `json:"relationships"`
+	Events        []uuid.UUID          `json:"events"`
+	Notes         []uuid.UUID          `json:"notes"`
+}
+
+// UnmappedTag is a source tag, given as its path from the record (e.g.
+// "INDI.BIRT.SOUR"), that has nowhere to go in this app
+type UnmappedTag struct {
+	Tag       string `json:"tag"`
+	Count     int    `json:"count"`
+	FirstLine int    `json:"first_line"`
+}
+
+func newReport(format string) *Report {
+	return &Report{
+		Format:   format,
+		Created:  newRowIDs(),
+		Updated:  newRowIDs(),
+		Unmapped: []UnmappedTag{},
+		Warnings: []string{},
+		unmapped: map[string]*UnmappedTag{},
+	}
+}
+
+func newRowIDs() RowIDs {
+	return RowIDs{
+		People:        map[string]uuid.UUID{},
+		Relationships: []uuid.UUID{},
+		Events:        []uuid.UUID{},
+		Notes:         []uuid.UUID{},
+	}
+}
+
+func (r *Report) warn(line int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if line > 0 {
+		msg = fmt.Sprintf("line %d: %s", line, msg)
+	}
+	r.Warnings = append(r.Warnings, msg)
+}
+
+func (r *Report) rowError(row int, column, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, RowError{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) skip(tag string, line int) {
+	if u, ok := r.unmapped[tag]; ok {
+		u.Count++
+		return
+	}
+	r.unmapped[tag] = &UnmappedTag{Tag: tag, Count: 1, FirstLine: line}
+}
+
+// finish sorts the unmapped tags, most frequent first
+func (r *Report) finish() {
+	r.Unmapped = r.Unmapped[:0]
+	for _, u := range r.unmapped {
+		r.Unmapped = append(r.Unmapped, *u)
+	}
+	sort.Slice(r.Unmapped, func(i, j int) bool {
+		if r.Unmapped[i].Count != r.Unmapped[j].Count {
+			return r.Unmapped[i].Count > r.Unmapped[j].Count
+		}
+		return r.Unmapped[i].Tag < r.Unmapped[j].Tag
+	})
+}
@@ -0,0 +1,339 @@
+package importer
+
+import (
+	"database/sql"
+	"farmily/app/family"
+	"farmily/app/models"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// writer inserts imported rows inside one transaction and records their IDs
+// in the report
+type writer struct {
+	tx     *sql.Tx
+	userID uuid.NullUUID
+	report *Report
+
+	// source scopes IDs local to the imported document ("#<id>"). Without
+	// one, local IDs are neither matched nor recorded.
+	source string
+
+	// links already created, so a pair isn't linked twice
+	links map[string]bool
+	// people that were in the database before the import, whose links have
+	// to be checked against stored relationships
+	existing map[uuid.UUID]bool
+}
+
+func newWriter(tx *sql.Tx, userID uuid.NullUUID, report *Report) *writer {
+	return &writer{
+		tx:       tx,
+		userID:   userID,
+		report:   report,
+		links:    map[string]bool{},
+		existing: map[uuid.UUID]bool{},
+	}
+}
+
+// entityTables maps external ID entity types to the tables they refer to
+var entityTables = map[string]string{
+	models.ExternalPerson: "people",
+	models.ExternalEvent:  "events",
+}
+
+// scope returns the source an external ID is recorded under: the import's
+// source for local IDs ("#<id>"), or none for persistent identifiers. ok is
+// false for local IDs when the import has no source.
+func (w *writer) scope(externalID string) (source string, ok bool) {
+	if strings.HasPrefix(externalID, "#") {
+		return w.source, w.source != ""
+	}
+	return "", true
+}
+
+// resolve finds the row an imported record refers to: an ID of ours, given
+// as a UUID, "#<id>" or "urn:uuid:<id>", or an identifier mapped by an
+// earlier import. Local IDs only match those mapped from the same source.
+// ok is false when none of the candidates matches a row.
+func (w *writer) resolve(entityType string, candidates []string) (id uuid.UUID, ok bool, err error) {
+	table := entityTables[entityType]
+
+	for _, candidate := range candidates {
+		own, err := uuid.Parse(strings.TrimPrefix(strings.TrimPrefix(candidate, "#"), "urn:uuid:"))
+		if err != nil {
+			continue
+		}
+		err = w.tx.QueryRow("SELECT id FROM "+table+" WHERE id = $1", own).Scan(&id)
+		if err == nil {
+			return id, true, nil
+		} else if err != sql.ErrNoRows {
+			return uuid.Nil, false, err
+		}
+	}
+
+	for _, candidate := range candidates {
+		source, ok := w.scope(candidate)
+		if !ok {
+			continue
+		}
+		err := w.tx.QueryRow(`
+			SELECT t.id FROM external_ids x
+			JOIN `+table+` t ON t.id = x.entity_id
+			WHERE x.entity_type = $1 AND x.source = $2 AND x.external_id = $3
+		`, entityType, source, candidate).Scan(&id)
+		if err == nil {
+			return id, true, nil
+		} else if err != sql.ErrNoRows {
+			return uuid.Nil, false, err
+		}
+	}
+
+	return uuid.Nil, false, nil
+}
+
+// mapIDs records that the external identifiers refer to the row, taking them
+// over from any row they referred to before. IDs of our own are not stored,
+// nor local IDs when the import has no source.
+func (w *writer) mapIDs(entityType string, id uuid.UUID, externalIDs []string) error {
+	for _, externalID := range externalIDs {
+		if own, err := uuid.Parse(strings.TrimPrefix(strings.TrimPrefix(externalID, "#"), "urn:uuid:")); externalID == "" || (err == nil && own == id) {
+			continue
+		}
+		source, ok := w.scope(externalID)
+		if !ok {
+			continue
+		}
+		_, err := w.tx.Exec(`
+			INSERT INTO external_ids (entity_type, source, external_id, entity_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (entity_type, source, external_id) DO UPDATE SET entity_id = EXCLUDED.entity_id
+		`, entityType, source, externalID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createPerson inserts p under a new ID. key is the person's identifier in the
+// source file and may be empty.
+func (w *writer) createPerson(key string, p *models.Person) (uuid.UUID, error) {
+	p.ID = uuid.New()
+	_, err := w.tx.Exec(`
+		INSERT INTO people (id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			occupation, biography, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, p.ID, p.FirstName, p.MiddleName, p.LastName, p.MaidenName, p.Gender,
+		p.BirthDate, p.BirthPlace, p.DeathDate, p.DeathPlace, p.IsLiving,
+		p.Occupation, p.Biography, w.userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if key == "" {
+		key = p.ID.String()
+	}
+	w.report.Created.People[key] = p.ID
+	return p.ID, nil
+}
+
+// updatePerson overwrites the details of an existing person with p. Photos
+// and authorship are left as they are.
+func (w *writer) updatePerson(key string, p *models.Person) error {
+	_, err := w.tx.Exec(`
+		UPDATE people
+		SET first_name = $2, middle_name = $3, last_name = $4, maiden_name = $5, gender = $6,
+			birth_date = $7, birth_place = $8, death_date = $9, death_place = $10, is_living = $11,
+			occupation = $12, biography = $13, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, p.ID, p.FirstName, p.MiddleName, p.LastName, p.MaidenName, p.Gender,
+		p.BirthDate, p.BirthPlace, p.DeathDate, p.DeathPlace, p.IsLiving,
+		p.Occupation, p.Biography)
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		key = p.ID.String()
+	}
+	w.existing[p.ID] = true
+	w.report.Updated.People[key] = p.ID
+	return nil
+}
+
+// linkParent records parentID as a parent of childID, stored as
+// ('child', child -> parent) like people created in the app
+func (w *writer) linkParent(childID, parentID uuid.UUID) (bool, error) {
+	if childID == parentID {
+		return false, nil
+	}
+	return w.link(childID, parentID, models.RelationshipChild, sql.NullTime{}, sql.NullTime{})
+}
+
+// linkSpouses records a marriage between a and b
+func (w *writer) linkSpouses(a, b uuid.UUID, start, end sql.NullTime) (bool, error) {
+	if a == b || w.links[models.RelationshipSpouse+b.String()+a.String()] {
+		return false, nil
+	}
+	return w.link(a, b, models.RelationshipSpouse, start, end)
+}
+
+func (w *writer) link(person1, person2 uuid.UUID, relType string, start, end sql.NullTime) (bool, error) {
+	key := relType + person1.String() + person2.String()
+	if w.links[key] {
+		return false, nil
+	}
+
+	if w.existing[person1] && w.existing[person2] {
+		found, err := w.updateLink(person1, person2, relType, start, end)
+		if err != nil || found {
+			w.links[key] = found
+			return false, err
+		}
+	}
+
+	id := uuid.New()
+	_, err := w.tx.Exec(`
+		INSERT INTO relationships (id, person1_id, person2_id, relationship_type, start_date, end_date)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, id, person1, person2, relType, start, end)
+	if err != nil {
+		return false, err
+	}
+
+	w.links[key] = true
+	w.report.Created.Relationships = append(w.report.Created.Relationships, id)
+	return true, nil
+}
+
+// updateLink looks for a stored relationship between two existing people,
+// in either of the ways parent links are stored, and brings a marriage's
+// dates up to date. found is false when there is none.
+func (w *writer) updateLink(person1, person2 uuid.UUID, relType string, start, end sql.NullTime) (found bool, err error) {
+	var id uuid.UUID
+	switch relType {
+	case models.RelationshipChild:
+		err = w.tx.QueryRow(`
+			SELECT id FROM relationships
+			WHERE (relationship_type = 'child' AND person1_id = $1 AND person2_id = $2)
+				OR (relationship_type = 'parent' AND person1_id = $2 AND person2_id = $1)
+			LIMIT 1
+		`, person1, person2).Scan(&id)
+	default:
+		err = w.tx.QueryRow(`
+			SELECT id FROM relationships
+			WHERE relationship_type = $3
+				AND ((person1_id = $1 AND person2_id = $2) OR (person1_id = $2 AND person2_id = $1))
+			LIMIT 1
+		`, person1, person2, relType).Scan(&id)
+	}
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if relType == models.RelationshipSpouse {
+		_, err := w.tx.Exec(`
+			UPDATE relationships SET start_date = $2, end_date = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, id, start, end)
+		if err != nil {
+			return false, err
+		}
+		w.report.Updated.Relationships = append(w.report.Updated.Relationships, id)
+	}
+	return true, nil
+}
+
+func (w *writer) createEvent(e *models.Event) error {
+	e.ID = uuid.New()
+	_, err := w.tx.Exec(`
+		INSERT INTO events (id, person_id, event_type, event_date, event_place, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, e.ID, e.PersonID, e.EventType, e.EventDate, e.EventPlace, e.Description)
+	if err != nil {
+		return err
+	}
+
+	w.report.Created.Events = append(w.report.Created.Events, e.ID)
+	return nil
+}
+
+// updateEvent overwrites an existing event with e, moving it to e's person
+func (w *writer) updateEvent(e *models.Event) error {
+	_, err := w.tx.Exec(`
+		UPDATE events
+		SET person_id = $2, event_type = $3, event_date = $4, event_place = $5, description = $6,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, e.ID, e.PersonID, e.EventType, e.EventDate, e.EventPlace, e.Description)
+	if err != nil {
+		return err
+	}
+
+	w.report.Updated.Events = append(w.report.Updated.Events, e.ID)
+	return nil
+}
+
+func (w *writer) createNote(personID uuid.UUID, content string) error {
+	id := uuid.New()
+	_, err := w.tx.Exec(`
+		INSERT INTO notes (id, person_id, content, created_by)
+		VALUES ($1, $2, $3, $4)
+	`, id, personID, content, w.userID)
+	if err != nil {
+		return err
+	}
+
+	w.report.Created.Notes = append(w.report.Created.Notes, id)
+	return nil
+}
+
+// check runs the genealogy validator over the people the import touched and
+// adds what it finds to the report's warnings. Imported files often carry
+// old mistakes, so these are flagged rather than stopping the import.
+func (w *writer) check() error {
+	var ids []uuid.UUID
+	for _, id := range w.report.Created.People {
+		ids = append(ids, id)
+	}
+	for _, id := range w.report.Updated.People {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	issues, err := family.Check(w.tx, ids...)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		w.report.warn(0, "%s: %s", issue.Severity, issue.Message)
+	}
+	return nil
+}
+
+// nullString returns s as a nullable column value, cut to the column's size
+func nullString(s string, size int) sql.NullString {
+	if s == "" {
+		return sql.NullString{}
+	}
+	if size > 0 && utf8.RuneCountInString(s) > size {
+		s = string([]rune(s)[:size])
+	}
+	return sql.NullString{String: s, Valid: true}
+}
+
+// clip cuts s to the column's size
+func clip(s string, size int) string {
+	if utf8.RuneCountInString(s) > size {
+		return string([]rune(s)[:size])
+	}
+	return s
+}
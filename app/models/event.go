@@ -41,3 +41,49 @@ type EventResponse struct {
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
+
+// EventTypes lists every event type allowed by the events table
+var EventTypes = []string{
+	EventBirth,
+	EventDeath,
+	EventMarriage,
+	EventDivorce,
+	EventGraduation,
+	EventEmployment,
+	EventRetirement,
+	EventOther,
+}
+
+// IsValidEventType reports whether t is one of the allowed event types
+func IsValidEventType(t string) bool {
+	for _, allowed := range EventTypes {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ToResponse converts Event to EventResponse
+func (e *Event) ToResponse(personName string) EventResponse {
+	resp := EventResponse{
+		ID:         e.ID,
+		PersonID:   e.PersonID,
+		PersonName: personName,
+		EventType:  e.EventType,
+		CreatedAt:  e.CreatedAt,
+		UpdatedAt:  e.UpdatedAt,
+	}
+
+	if e.EventDate.Valid {
+		resp.EventDate = &e.EventDate.Time
+	}
+	if e.EventPlace.Valid {
+		resp.EventPlace = e.EventPlace.String
+	}
+	if e.Description.Valid {
+		resp.Description = e.Description.String
+	}
+
+	return resp
+}
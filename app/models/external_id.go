@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// External ID entity types
+const (
+	ExternalPerson = "person"
+	ExternalEvent  = "event"
+)
+
+// ExternalID maps an identifier from another program to a row in this app
+type ExternalID struct {
+	EntityType string `json:"entity_type"`
+	// Source names the document a local ID ("#<id>") came from; it is empty
+	// for persistent identifiers
+	Source     string    `json:"source"`
+	ExternalID string    `json:"external_id"`
+	EntityID   uuid.UUID `json:"entity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,22 +15,133 @@ const (
 	MediaVideo    = "video"
 )
 
+// Resized image variants
+const (
+	VariantThumb  = "thumb"
+	VariantMedium = "medium"
+)
+
 type Media struct {
-	ID          uuid.UUID      `json:"id"`
-	PersonID    sql.NullString `json:"person_id"`
-	EventID     sql.NullString `json:"event_id"`
-	FilePath    string         `json:"file_path"`
-	FileType    string         `json:"file_type"`
-	Title       sql.NullString `json:"title"`
-	Description sql.NullString `json:"description"`
-	UploadDate  time.Time      `json:"upload_date"`
-}
-
-type Note struct {
-	ID        uuid.UUID `json:"id"`
-	PersonID  uuid.UUID `json:"person_id"`
-	Content   string    `json:"content"`
-	CreatedBy uuid.UUID `json:"created_by"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uuid.UUID       `json:"id"`
+	PersonID     sql.NullString  `json:"person_id"`
+	EventID      sql.NullString  `json:"event_id"`
+	FilePath     string          `json:"file_path"`
+	FileType     string          `json:"file_type"`
+	Title        sql.NullString  `json:"title"`
+	Description  sql.NullString  `json:"description"`
+	OriginalName sql.NullString  `json:"original_name"`
+	ContentType  sql.NullString  `json:"content_type"`
+	FileSize     sql.NullInt64   `json:"file_size"`
+	UploadedBy   sql.NullString  `json:"uploaded_by"`
+	TakenAt      sql.NullTime    `json:"taken_at"`
+	Latitude     sql.NullFloat64 `json:"latitude"`
+	Longitude    sql.NullFloat64 `json:"longitude"`
+	UploadDate   time.Time       `json:"upload_date"`
+}
+
+// MediaResponse is used for API responses; it never exposes the storage path
+type MediaResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	PersonID     string     `json:"person_id,omitempty"`
+	PersonName   string     `json:"person_name,omitempty"`
+	EventID      string     `json:"event_id,omitempty"`
+	FileType     string     `json:"file_type"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	OriginalName string     `json:"original_name"`
+	ContentType  string     `json:"content_type"`
+	FileSize     int64      `json:"file_size"`
+	URL          string     `json:"url"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	MediumURL    string     `json:"medium_url,omitempty"`
+	TakenAt      *time.Time `json:"taken_at"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	Tags         []MediaTag `json:"tags"`
+	UploadDate   time.Time  `json:"upload_date"`
+}
+
+// Region is a rectangle within a photo, as fractions (0-1) of its width and
+// height; used for face tags and portrait crops
+type Region struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// IsValid reports whether the region lies inside the image and has an area
+func (r Region) IsValid() bool {
+	return r.X >= 0 && r.Y >= 0 && r.Width > 0 && r.Height > 0 &&
+		r.X+r.Width <= 1 && r.Y+r.Height <= 1
+}
+
+// MediaTag links a person to a photo they appear in
+type MediaTag struct {
+	MediaID    uuid.UUID `json:"media_id"`
+	PersonID   uuid.UUID `json:"person_id"`
+	PersonName string    `json:"person_name"`
+	Region     *Region   `json:"region"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FileURL returns the authenticated URL the media file is served from
+func (m *Media) FileURL() string {
+	return "/api/media/" + m.ID.String() + "/file"
+}
+
+// MediaVariantURL returns the URL of a resized variant when url points at a
+// managed media file; external URLs are returned unchanged
+func MediaVariantURL(url, size string) string {
+	if strings.HasPrefix(url, "/api/media/") && strings.HasSuffix(url, "/file") {
+		return url + "?size=" + size
+	}
+	return url
+}
+
+// ToResponse converts Media to MediaResponse
+func (m *Media) ToResponse(personName string) MediaResponse {
+	resp := MediaResponse{
+		ID:         m.ID,
+		PersonName: personName,
+		Tags:       []MediaTag{},
+		FileType:   m.FileType,
+		URL:        m.FileURL(),
+		UploadDate: m.UploadDate,
+	}
+
+	if m.PersonID.Valid {
+		resp.PersonID = m.PersonID.String
+	}
+	if m.EventID.Valid {
+		resp.EventID = m.EventID.String
+	}
+	if m.Title.Valid {
+		resp.Title = m.Title.String
+	}
+	if m.Description.Valid {
+		resp.Description = m.Description.String
+	}
+	if m.OriginalName.Valid {
+		resp.OriginalName = m.OriginalName.String
+	}
+	if m.ContentType.Valid {
+		resp.ContentType = m.ContentType.String
+	}
+	if m.FileSize.Valid {
+		resp.FileSize = m.FileSize.Int64
+	}
+	if m.TakenAt.Valid {
+		resp.TakenAt = &m.TakenAt.Time
+	}
+	if m.Latitude.Valid && m.Longitude.Valid {
+		resp.Latitude = &m.Latitude.Float64
+		resp.Longitude = &m.Longitude.Float64
+	}
+	if m.FileType == MediaImage {
+		resp.ThumbnailURL = MediaVariantURL(resp.URL, VariantThumb)
+		resp.MediumURL = MediaVariantURL(resp.URL, VariantMedium)
+	}
+
+	return resp
 }
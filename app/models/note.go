@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Note struct {
+	ID        uuid.UUID     `json:"id"`
+	PersonID  uuid.UUID     `json:"person_id"`
+	Content   string        `json:"content"`
+	CreatedBy uuid.NullUUID `json:"created_by"`
+	UpdatedBy uuid.NullUUID `json:"updated_by"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type NoteResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	PersonID      uuid.UUID  `json:"person_id"`
+	Content       string     `json:"content"`
+	CreatedBy     *uuid.UUID `json:"created_by"`
+	AuthorName    string     `json:"author_name"`
+	UpdatedBy     *uuid.UUID `json:"updated_by"`
+	EditorName    string     `json:"editor_name"`
+	RevisionCount int        `json:"revision_count"`
+	CanEdit       bool       `json:"can_edit"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// NoteRevision is an earlier version of a note; EditedBy and CreatedAt are
+// who wrote that version and when
+type NoteRevision struct {
+	ID         uuid.UUID  `json:"id"`
+	NoteID     uuid.UUID  `json:"note_id"`
+	Content    string     `json:"content"`
+	EditedBy   *uuid.UUID `json:"edited_by"`
+	EditorName string     `json:"editor_name"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ToResponse converts Note to NoteResponse
+func (n *Note) ToResponse(authorName, editorName string, revisionCount int, canEdit bool) NoteResponse {
+	resp := NoteResponse{
+		ID:            n.ID,
+		PersonID:      n.PersonID,
+		Content:       n.Content,
+		AuthorName:    authorName,
+		EditorName:    editorName,
+		RevisionCount: revisionCount,
+		CanEdit:       canEdit,
+		CreatedAt:     n.CreatedAt,
+		UpdatedAt:     n.UpdatedAt,
+	}
+
+	if n.CreatedBy.Valid {
+		resp.CreatedBy = &n.CreatedBy.UUID
+	}
+	if n.UpdatedBy.Valid {
+		resp.UpdatedBy = &n.UpdatedBy.UUID
+	}
+
+	return resp
+}
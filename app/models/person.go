@@ -8,6 +8,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// Genders allowed by the people table
+const (
+	GenderMale   = "Male"
+	GenderFemale = "Female"
+	GenderOther  = "Other"
+)
+
 type Person struct {
 	ID              uuid.UUID      `json:"id"`
 	FirstName       string         `json:"first_name"`
@@ -23,6 +30,7 @@ type Person struct {
 	Occupation      sql.NullString `json:"occupation"`
 	Biography       sql.NullString `json:"biography"`
 	ProfilePhotoURL sql.NullString `json:"profile_photo_url"`
+	ProfileMediaID  uuid.NullUUID  `json:"profile_media_id"`
 	CreatedBy       uuid.UUID      `json:"created_by"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -44,6 +52,8 @@ type PersonResponse struct {
 	Occupation      string     `json:"occupation"`
 	Biography       string     `json:"biography"`
 	ProfilePhotoURL string     `json:"profile_photo_url"`
+	ThumbnailURL    string     `json:"thumbnail_url"`
+	ProfileMediaID  *uuid.UUID `json:"profile_media_id"`
 	DisplayName     string     `json:"display_name"`
 	Age             *int       `json:"age"`
 	Lifespan        string     `json:"lifespan"`
@@ -66,17 +76,22 @@ func (p *Person) GetDisplayName() string {
 
 // GetAge calculates the age of the person
 func (p *Person) GetAge() *int {
-	if !p.BirthDate.Valid {
-		return nil
-	}
-
 	endDate := time.Now()
 	if p.DeathDate.Valid {
 		endDate = p.DeathDate.Time
 	}
 
-	age := endDate.Year() - p.BirthDate.Time.Year()
-	if endDate.YearDay() < p.BirthDate.Time.YearDay() {
+	return p.GetAgeAt(endDate)
+}
+
+// GetAgeAt calculates how old the person was on the given date
+func (p *Person) GetAgeAt(date time.Time) *int {
+	if !p.BirthDate.Valid {
+		return nil
+	}
+
+	age := date.Year() - p.BirthDate.Time.Year()
+	if date.YearDay() < p.BirthDate.Time.YearDay() {
 		age--
 	}
 
@@ -102,6 +117,12 @@ func (p *Person) GetLifespan() string {
 	return fmt.Sprintf("%d - ?", birthYear)
 }
 
+// PortraitURL returns the URL of a person's managed, resized portrait.
+// version busts browser caches when the portrait changes.
+func PortraitURL(personID uuid.UUID, size string, version time.Time) string {
+	return fmt.Sprintf("/api/people/%s/photo?size=%s&v=%d", personID, size, version.Unix())
+}
+
 // ToResponse converts Person to PersonResponse
 func (p *Person) ToResponse() PersonResponse {
 	resp := PersonResponse{
@@ -141,8 +162,14 @@ func (p *Person) ToResponse() PersonResponse {
 	if p.Biography.Valid {
 		resp.Biography = p.Biography.String
 	}
-	if p.ProfilePhotoURL.Valid {
+	if p.ProfileMediaID.Valid {
+		resp.ProfileMediaID = &p.ProfileMediaID.UUID
+		resp.ProfilePhotoURL = PortraitURL(p.ID, VariantMedium, p.UpdatedAt)
+		resp.ThumbnailURL = PortraitURL(p.ID, VariantThumb, p.UpdatedAt)
+	} else if p.ProfilePhotoURL.Valid {
+		// Legacy free-text URL entered before managed portraits
 		resp.ProfilePhotoURL = p.ProfilePhotoURL.String
+		resp.ThumbnailURL = MediaVariantURL(p.ProfilePhotoURL.String, VariantThumb)
 	}
 
 	return resp
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Search match sources
+const (
+	SearchSourcePerson = "person"
+	SearchSourceNote   = "note"
+	SearchSourceEvent  = "event"
+	SearchSourceMedia  = "media"
+)
+
+// SearchMatch is one record that matched a search, with the matching text
+// highlighted by <mark> tags
+type SearchMatch struct {
+	Source   string    `json:"source"`
+	SourceID uuid.UUID `json:"source_id"`
+	Snippet  string    `json:"snippet"`
+	Rank     float64   `json:"rank"`
+}
+
+// SearchResult is a person and every record of theirs that matched a search
+type SearchResult struct {
+	Person  PersonResponse `json:"person"`
+	Score   float64        `json:"score"`
+	Matches []SearchMatch  `json:"matches"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Timeline entry types that don't come straight from the events table
+const (
+	TimelineWidowed    = "widowed"
+	TimelineChildBirth = "child_birth"
+)
+
+// Timeline entry sources
+const (
+	TimelineSourcePerson       = "person"
+	TimelineSourceEvent        = "event"
+	TimelineSourceRelationship = "relationship"
+)
+
+// TimelineEntry is a single fact in a person's life story
+type TimelineEntry struct {
+	Date              *time.Time `json:"date"`
+	Type              string     `json:"type"`
+	Title             string     `json:"title"`
+	Place             string     `json:"place,omitempty"`
+	Description       string     `json:"description,omitempty"`
+	Age               *int       `json:"age"`
+	RelatedPersonID   *uuid.UUID `json:"related_person_id,omitempty"`
+	RelatedPersonName string     `json:"related_person_name,omitempty"`
+	Source            string     `json:"source"`
+	SourceID          uuid.UUID  `json:"source_id"`
+}
@@ -6,16 +6,29 @@ import (
 	"github.com/google/uuid"
 )
 
+// User roles
+const (
+	RoleMember = "member"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
 	FirstName    string    `json:"first_name"`
 	LastName     string    `json:"last_name"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// CanEditOthers reports whether the user may change content other users wrote
+func (u *User) CanEditOthers() bool {
+	return u.Role == RoleEditor || u.Role == RoleAdmin
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -62,13 +62,11 @@ func RegisterAPI(c *fiber.Ctx, db *sql.DB) error {
 		})
 	}
 
-	// Create user
+	// Create user. The first account registered administers the instance;
+	// the table lock keeps two simultaneous first registrations from both
+	// becoming admin.
 	userID := uuid.New()
-	_, err = db.Exec(`
-		INSERT INTO users (id, email, password_hash, first_name, last_name)
-		VALUES ($1, $2, $3, $4, $5)
-	`, userID, req.Email, string(hashedPassword), req.FirstName, req.LastName)
-
+	role, err := insertUser(db, userID, req, string(hashedPassword))
 	if err != nil {
 		return c.Status(500).JSON(models.AuthResponse{
 			Success: false,
@@ -98,6 +96,7 @@ func RegisterAPI(c *fiber.Ctx, db *sql.DB) error {
 		Email:     req.Email,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Role:      role,
 	}
 
 	return c.JSON(models.AuthResponse{
@@ -108,6 +107,34 @@ func RegisterAPI(c *fiber.Ctx, db *sql.DB) error {
 	})
 }
 
+// insertUser creates the account and returns the role it was given: admin
+// for the first account, member for everyone after
+func insertUser(db *sql.DB, userID uuid.UUID, req models.RegisterRequest, passwordHash string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if err := lockUsers(tx); err != nil {
+		return "", err
+	}
+
+	var role string
+	err = tx.QueryRow(`
+		INSERT INTO users (id, email, password_hash, first_name, last_name, role)
+		SELECT $1, $2, $3, $4, $5,
+			CASE WHEN EXISTS(SELECT 1 FROM users) THEN $6 ELSE $7 END
+		RETURNING role
+	`, userID, req.Email, passwordHash, req.FirstName, req.LastName,
+		models.RoleMember, models.RoleAdmin).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+
+	return role, tx.Commit()
+}
+
 func LoginAPI(c *fiber.Ctx, db *sql.DB) error {
 	var req models.LoginRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -120,11 +147,11 @@ func LoginAPI(c *fiber.Ctx, db *sql.DB) error {
 	// Get user from database
 	var user models.User
 	err := db.QueryRow(`
-		SELECT id, email, password_hash, first_name, last_name, created_at, updated_at
+		SELECT id, email, password_hash, first_name, last_name, role, created_at, updated_at
 		FROM users WHERE email = $1
 	`, req.Email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash,
-		&user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt,
+		&user.FirstName, &user.LastName, &user.Role, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetCurrentUser loads the authenticated user, including their role
+func GetCurrentUser(c *fiber.Ctx, db *sql.DB) (*models.User, error) {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = db.QueryRow(`
+		SELECT id, email, first_name, last_name, role, created_at, updated_at
+		FROM users WHERE id = $1
+	`, userID).Scan(
+		&user.ID, &user.Email, &user.FirstName, &user.LastName,
+		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// lockUsers locks the users table for the rest of tx so that checks on who
+// is admin can't race with other registrations or role changes. Reads are
+// still allowed.
+func lockUsers(tx *sql.Tx) error {
+	_, err := tx.Exec("LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE")
+	return err
+}
+
+// requireAdmin writes the error response itself and returns false unless the
+// current user is an admin
+func requireAdmin(c *fiber.Ctx, db *sql.DB) (bool, error) {
+	user, err := GetCurrentUser(c, db)
+	if err != nil {
+		return false, c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	if user.Role != models.RoleAdmin {
+		return false, c.Status(403).JSON(fiber.Map{
+			"success": false,
+			"message": "Only admins can manage user roles",
+		})
+	}
+	return true, nil
+}
+
+// ListUsersAPI lists every account with its role (admins only)
+func ListUsersAPI(c *fiber.Ctx, db *sql.DB) error {
+	if ok, err := requireAdmin(c, db); !ok {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, email, first_name, last_name, role, created_at, updated_at
+		FROM users ORDER BY created_at, email
+	`)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	defer rows.Close()
+
+	// Initialize as empty slice to ensure JSON [] instead of null
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.FirstName, &user.LastName,
+			&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    users,
+	})
+}
+
+// SetUserRoleAPI changes a user's role (admins only). The last admin can't
+// be demoted, so the instance always has someone who can hand out roles.
+func SetUserRoleAPI(c *fiber.Ctx, db *sql.DB) error {
+	if ok, err := requireAdmin(c, db); !ok {
+		return err
+	}
+
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid user ID",
+		})
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if req.Role != models.RoleMember && req.Role != models.RoleEditor && req.Role != models.RoleAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Role must be member, editor or admin",
+		})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	defer tx.Rollback()
+
+	if err := lockUsers(tx); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	var current string
+	err = tx.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	if current == models.RoleAdmin && req.Role != models.RoleAdmin {
+		var otherAdmins bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE role = $1 AND id != $2)",
+			models.RoleAdmin, userID).Scan(&otherAdmins)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		if !otherAdmins {
+			return c.Status(409).JSON(fiber.Map{
+				"success": false,
+				"message": "Cannot demote the last admin",
+			})
+		}
+	}
+
+	_, err = tx.Exec("UPDATE users SET role = $1, updated_at = $2 WHERE id = $3", req.Role, time.Now(), userID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update role",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Role updated successfully",
+	})
+}
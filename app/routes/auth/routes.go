@@ -30,4 +30,16 @@ func SetupAuthRoutes(app *fiber.App, db *sql.DB) {
 	})
 
 	app.Post("/api/auth/logout", LogoutAPI)
+
+	// User roles (admins only)
+	users := app.Group("/api/users")
+	users.Use(AuthMiddleware)
+
+	users.Get("/", func(c *fiber.Ctx) error {
+		return ListUsersAPI(c, db)
+	})
+
+	users.Put("/:id/role", func(c *fiber.Ctx) error {
+		return SetUserRoleAPI(c, db)
+	})
 }
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"farmily/app/backup"
+	"farmily/app/config"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MaxRestoreSize is the largest backup accepted for upload; restore larger
+// archives from the command line
+const MaxRestoreSize = 1 << 30
+
+// requireAdmin writes the error response itself and returns false unless the
+// current user is an admin
+func requireAdmin(c *fiber.Ctx, db *sql.DB) (bool, error) {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return false, c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	if user.Role != models.RoleAdmin {
+		return false, c.Status(403).JSON(fiber.Map{
+			"success": false,
+			"message": "Only admins can back up and restore the database",
+		})
+	}
+	return true, nil
+}
+
+// BackupAPI downloads a backup of the whole database: by default a tar.gz
+// archive with the media files, or with ?format=json the backup document
+// alone. Password hashes are left out unless ?passwords=true.
+func BackupAPI(c *fiber.Ctx, db *sql.DB) error {
+	if ok, err := requireAdmin(c, db); !ok {
+		return err
+	}
+
+	format := c.Query("format", "tar.gz")
+	if format != "tar.gz" && format != "json" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid format: must be tar.gz or json",
+		})
+	}
+
+	b, err := backup.Dump(db, backup.Options{PasswordHashes: c.QueryBool("passwords")})
+	if err != nil {
+		log.Printf("backup failed: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to back up the database",
+		})
+	}
+
+	name := "farmily-backup-" + b.CreatedAt.Format("20060102-150405")
+	if format == "json" {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.json"`)
+		return backup.WriteJSON(c, b)
+	}
+
+	// The archive can be large, so it is streamed once headers are sent;
+	// a failure past that point can only cut the download short
+	backend := config.GetStorage()
+
+	c.Set(fiber.HeaderContentType, "application/gzip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+name+`.tar.gz"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := backup.WriteArchive(context.Background(), w, b, backend); err != nil {
+			log.Printf("backup archive failed: %v", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// RestoreAPI restores an uploaded backup, archive or document, into this
+// instance. The database must not hold any family data yet.
+func RestoreAPI(c *fiber.Ctx, db *sql.DB) error {
+	if ok, err := requireAdmin(c, db); !ok {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "No file uploaded",
+		})
+	}
+
+	f, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read upload",
+		})
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	report, err := backup.Restore(ctx, db, f, config.GetStorage())
+	if errors.Is(err, backup.ErrInvalidBackup) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	} else if errors.Is(err, backup.ErrNotEmpty) {
+		return c.Status(409).JSON(fiber.Map{
+			"success": false,
+			"message": "Restore needs an empty database: " + err.Error(),
+		})
+	} else if err != nil {
+		log.Printf("restore failed: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Restore failed; nothing was restored",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Backup restored",
+		"data":    report,
+	})
+}
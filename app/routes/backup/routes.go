@@ -0,0 +1,20 @@
+package backup
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupBackupRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/backup")
+	api.Use(auth.AuthMiddleware)
+
+	api.Get("/", func(c *fiber.Ctx) error {
+		return BackupAPI(c, db)
+	})
+	api.Post("/restore", func(c *fiber.Ctx) error {
+		return RestoreAPI(c, db)
+	})
+}
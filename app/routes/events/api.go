@@ -0,0 +1,319 @@
+package events
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"farmily/app/routes/media"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const eventSelect = `
+	SELECT e.id, e.person_id, e.event_type, e.event_date, e.event_place,
+		e.description, e.created_at, e.updated_at,
+		p.first_name || ' ' || p.last_name as person_name
+	FROM events e
+	JOIN people p ON e.person_id = p.id
+`
+
+type eventRequest struct {
+	PersonID    string  `json:"person_id"`
+	EventType   string  `json:"event_type"`
+	EventDate   *string `json:"event_date"`
+	EventPlace  *string `json:"event_place"`
+	Description *string `json:"description"`
+}
+
+func scanEvents(rows *sql.Rows) []models.EventResponse {
+	// Initialize as empty slice to ensure JSON [] instead of null
+	events := []models.EventResponse{}
+	for rows.Next() {
+		var e models.Event
+		var personName string
+		err := rows.Scan(
+			&e.ID, &e.PersonID, &e.EventType, &e.EventDate, &e.EventPlace,
+			&e.Description, &e.CreatedAt, &e.UpdatedAt, &personName,
+		)
+		if err != nil {
+			continue
+		}
+		events = append(events, e.ToResponse(personName))
+	}
+	return events
+}
+
+// parseEventRequest validates the request body and returns the values to store
+func parseEventRequest(c *fiber.Ctx) (*eventRequest, uuid.UUID, interface{}, string) {
+	var req eventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, uuid.Nil, nil, "Invalid request body"
+	}
+
+	personID, err := uuid.Parse(req.PersonID)
+	if err != nil {
+		return nil, uuid.Nil, nil, "Invalid person ID"
+	}
+
+	if !models.IsValidEventType(req.EventType) {
+		return nil, uuid.Nil, nil, "Invalid event type"
+	}
+
+	var eventDate interface{}
+	if req.EventDate != nil && *req.EventDate != "" {
+		if _, err := time.Parse("2006-01-02", *req.EventDate); err != nil {
+			return nil, uuid.Nil, nil, "Invalid event date, expected YYYY-MM-DD"
+		}
+		eventDate = *req.EventDate
+	}
+
+	return &req, personID, eventDate, ""
+}
+
+func personExists(db *sql.DB, personID uuid.UUID) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", personID).Scan(&exists)
+	return exists, err
+}
+
+func GetAllEventsAPI(c *fiber.Ctx, db *sql.DB) error {
+	query := eventSelect
+	var args []interface{}
+
+	if eventType := c.Query("type"); eventType != "" {
+		if !models.IsValidEventType(eventType) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid event type",
+			})
+		}
+		query += " WHERE e.event_type = $1"
+		args = append(args, eventType)
+	}
+	query += " ORDER BY e.event_date ASC NULLS LAST, e.created_at ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch events",
+		})
+	}
+	defer rows.Close()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    scanEvents(rows),
+	})
+}
+
+func GetPersonEventsAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	rows, err := db.Query(eventSelect+`
+		WHERE e.person_id = $1
+		ORDER BY e.event_date ASC NULLS LAST, e.created_at ASC
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch events",
+		})
+	}
+	defer rows.Close()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    scanEvents(rows),
+	})
+}
+
+func GetEventAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event ID",
+		})
+	}
+
+	var e models.Event
+	var personName string
+	err = db.QueryRow(eventSelect+" WHERE e.id = $1", eventID).Scan(
+		&e.ID, &e.PersonID, &e.EventType, &e.EventDate, &e.EventPlace,
+		&e.Description, &e.CreatedAt, &e.UpdatedAt, &personName,
+	)
+
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Event not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    e.ToResponse(personName),
+	})
+}
+
+func CreateEventAPI(c *fiber.Ctx, db *sql.DB) error {
+	_, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	req, personID, eventDate, msg := parseEventRequest(c)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	exists, err := personExists(db, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	eventID := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO events (id, person_id, event_type, event_date, event_place, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, eventID, personID, req.EventType, eventDate, req.EventPlace, req.Description)
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create event",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Event created successfully",
+		"id":      eventID,
+	})
+}
+
+func UpdateEventAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event ID",
+		})
+	}
+
+	req, personID, eventDate, msg := parseEventRequest(c)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	exists, err := personExists(db, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	result, err := db.Exec(`
+		UPDATE events SET
+			person_id = $1, event_type = $2, event_date = $3, event_place = $4,
+			description = $5, updated_at = $6
+		WHERE id = $7
+	`, personID, req.EventType, eventDate, req.EventPlace, req.Description, time.Now(), eventID)
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update event",
+		})
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Event not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Event updated successfully",
+	})
+}
+
+func DeleteEventAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event ID",
+		})
+	}
+
+	// Media rows cascade with the event, so collect their files first
+	mediaFiles, err := media.EventFileKeys(db, eventID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete event",
+		})
+	}
+
+	_, err = db.Exec("DELETE FROM events WHERE id = $1", eventID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete event",
+		})
+	}
+
+	media.RemoveFiles(mediaFiles)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Event deleted successfully",
+	})
+}
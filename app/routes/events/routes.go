@@ -0,0 +1,38 @@
+package events
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupEventsRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/events")
+	api.Use(auth.AuthMiddleware)
+
+	api.Get("/", func(c *fiber.Ctx) error {
+		return GetAllEventsAPI(c, db)
+	})
+
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		return GetEventAPI(c, db)
+	})
+
+	api.Post("/", func(c *fiber.Ctx) error {
+		return CreateEventAPI(c, db)
+	})
+
+	api.Put("/:id", func(c *fiber.Ctx) error {
+		return UpdateEventAPI(c, db)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		return DeleteEventAPI(c, db)
+	})
+
+	// Get events for a specific person
+	app.Get("/api/people/:id/events", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetPersonEventsAPI(c, db)
+	})
+}
@@ -0,0 +1,352 @@
+package exports
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"farmily/app/config"
+	"farmily/app/exporter"
+	"farmily/app/gedcom"
+	"farmily/app/gedcomx"
+	"farmily/app/routes/auth"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// selectionFromQuery reads which people to export from ?root=<id>[,<id>...],
+// ?scope=ancestors|descendants|both|relatives, ?distance=<links> and
+// ?exclude_living=true
+func selectionFromQuery(c *fiber.Ctx) (exporter.Selection, error) {
+	var sel exporter.Selection
+
+	for _, raw := range strings.Split(c.Query("root"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return sel, errors.New("Invalid root person ID")
+		}
+		sel.Roots = append(sel.Roots, id)
+	}
+
+	sel.Scope = c.Query("scope")
+	sel.Distance = c.QueryInt("distance")
+	sel.ExcludeLiving = c.QueryBool("exclude_living")
+
+	if err := sel.Validate(); err != nil {
+		return sel, errors.New("Invalid scope: " + err.Error())
+	}
+	return sel, nil
+}
+
+// loadSelection loads the dataset the query asks for, writing the error
+// response itself when it fails
+func loadSelection(c *fiber.Ctx, db *sql.DB) (*exporter.Dataset, error) {
+	sel, err := selectionFromQuery(c)
+	if err != nil {
+		return nil, c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	d, err := exporter.Load(db, sel)
+	if err != nil {
+		var notFound *exporter.ErrRootNotFound
+		if errors.As(err, &notFound) {
+			return nil, c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Root person not found",
+			})
+		}
+		return nil, c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load family tree",
+		})
+	}
+
+	return d, nil
+}
+
+// ExportGEDCOMAPI downloads the tree, or the selected branch, as GEDCOM 5.5.1
+// or, with ?version=7.0, GEDCOM 7.0
+func ExportGEDCOMAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	version := c.Query("version", gedcom.Version551)
+	if version != gedcom.Version551 && version != gedcom.Version70 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid version: must be " + gedcom.Version551 + " or " + gedcom.Version70,
+		})
+	}
+
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.GEDCOM(&buf, d, exporter.GEDCOMOptions{
+		Version:   version,
+		Submitter: user.FirstName + " " + user.LastName,
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write GEDCOM",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-gedcom; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.ged"`)
+	return c.Send(buf.Bytes())
+}
+
+// ExportGEDCOMXAPI downloads the tree, or the selected branch, as a GEDCOM X
+// JSON document
+func ExportGEDCOMXAPI(c *fiber.Ctx, db *sql.DB) error {
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.GEDCOMX(&buf, d); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write GEDCOM X",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, gedcomx.MediaType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.json"`)
+	return c.Send(buf.Bytes())
+}
+
+// ExportXLSXAPI downloads people, relationships and events as an Excel
+// workbook with a sheet for each
+func ExportXLSXAPI(c *fiber.Ctx, db *sql.DB) error {
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.WriteXLSX(&buf, exporter.Sheets(d)); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write workbook",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.xlsx"`)
+	return c.Send(buf.Bytes())
+}
+
+// ExportCSVAPI downloads one table as CSV with ?sheet=people|relationships|events,
+// or all three as a zip of CSV files
+func ExportCSVAPI(c *fiber.Ctx, db *sql.DB) error {
+	name := strings.ToLower(c.Query("sheet"))
+	if name != "" && name != "people" && name != "relationships" && name != "events" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid sheet: must be people, relationships or events",
+		})
+	}
+
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	sheets := exporter.Sheets(d)
+
+	var buf bytes.Buffer
+	if name == "" {
+		err = exporter.WriteCSVZip(&buf, sheets)
+		c.Set(fiber.HeaderContentType, "application/zip")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily-csv.zip"`)
+	} else {
+		for _, sheet := range sheets {
+			if strings.ToLower(sheet.Name) == name {
+				err = exporter.WriteCSV(&buf, sheet)
+			}
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily-`+name+`.csv"`)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write CSV",
+		})
+	}
+
+	return c.Send(buf.Bytes())
+}
+
+// ExportGEDZIPAPI downloads the tree, or the selected branch, as a GEDZIP
+// bundle of a GEDCOM 7.0 file and the media files it references
+func ExportGEDZIPAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	// The archive can be large, so it is streamed once headers are sent;
+	// a failure past that point can only cut the download short
+	submitter := user.FirstName + " " + user.LastName
+	backend := config.GetStorage()
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.gdz"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := exporter.GEDZIP(context.Background(), w, d, submitter, backend); err != nil {
+			log.Printf("gedzip export failed: %v", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// ExportDOTAPI downloads the tree, or the selected branch, as a Graphviz DOT
+// graph
+func ExportDOTAPI(c *fiber.Ctx, db *sql.DB) error {
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.DOT(&buf, d); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write DOT",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/vnd.graphviz; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.dot"`)
+	return c.Send(buf.Bytes())
+}
+
+// ExportSVGAPI draws the tree, or the selected branch, as an SVG family
+// chart. With ?download=true it is sent as an attachment.
+func ExportSVGAPI(c *fiber.Ctx, db *sql.DB) error {
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.SVG(&buf, d, "Family Tree"); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to draw tree",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "image/svg+xml")
+	if c.QueryBool("download") {
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="farmily.svg"`)
+	}
+	return c.Send(buf.Bytes())
+}
+
+// ExportBookAPI generates the family book of ?root=<id>: a register-style
+// report of their descendants, as HTML ready to print or with ?format=pdf
+// as a PDF. ?generations=<n> limits its length.
+func ExportBookAPI(c *fiber.Ctx, db *sql.DB) error {
+	format := c.Query("format", "html")
+	if format != "html" && format != "pdf" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid format: must be html or pdf",
+		})
+	}
+
+	root, err := uuid.Parse(c.Query("root"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A root person ID is required",
+		})
+	}
+
+	generations := c.QueryInt("generations")
+	if generations < 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid generations: must be positive",
+		})
+	}
+
+	d, err := exporter.Load(db, exporter.Selection{
+		Roots:         []uuid.UUID{root},
+		Scope:         exporter.ScopeDescendants,
+		ExcludeLiving: c.QueryBool("exclude_living"),
+	})
+	if err != nil {
+		var notFound *exporter.ErrRootNotFound
+		if errors.As(err, &notFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Root person not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load family tree",
+		})
+	}
+
+	book := exporter.NewBook(d, root, generations)
+	if book.Root == nil {
+		// The root was left out as living
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "The root person is living and was excluded",
+		})
+	}
+
+	var buf bytes.Buffer
+	if format == "pdf" {
+		err = exporter.BookPDF(&buf, book)
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `inline; filename="family-book.pdf"`)
+	} else {
+		err = exporter.BookHTML(&buf, book)
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write family book",
+		})
+	}
+
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,167 @@
+package exports
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"farmily/app/exporter"
+	"farmily/app/routes/auth"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newCalendarToken returns a random token for a calendar feed URL
+func newCalendarToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// calendarURLs returns the feed's https and webcal URLs
+func calendarURLs(c *fiber.Ctx, token string) fiber.Map {
+	url := c.BaseURL() + "/calendar/" + token + ".ics"
+	return fiber.Map{
+		"token":      token,
+		"url":        url,
+		"webcal_url": "webcal://" + strings.SplitN(url, "://", 2)[1],
+	}
+}
+
+// GetCalendarAPI returns the current user's calendar feed URL, creating its
+// token the first time
+func GetCalendarAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	var token sql.NullString
+	if err := db.QueryRow("SELECT calendar_token FROM users WHERE id = $1", user.ID).Scan(&token); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load calendar",
+		})
+	}
+	if token.Valid {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    calendarURLs(c, token.String),
+		})
+	}
+
+	return ResetCalendarTokenAPI(c, db)
+}
+
+// ResetCalendarTokenAPI gives the current user a new calendar feed URL. The
+// old URL stops working.
+func ResetCalendarTokenAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	token, err := newCalendarToken()
+	if err == nil {
+		_, err = db.Exec("UPDATE users SET calendar_token = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", token, user.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create calendar URL",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    calendarURLs(c, token),
+	})
+}
+
+// DeleteCalendarTokenAPI turns off the current user's calendar feed
+func DeleteCalendarTokenAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	if _, err := db.Exec("UPDATE users SET calendar_token = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1", user.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to turn off calendar",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Calendar URL turned off",
+	})
+}
+
+// CalendarFeedAPI serves the iCalendar feed of the user whose token is in
+// the URL. It takes the export filters, and ?types= with a comma-separated
+// list of birthdays, anniversaries and memorials.
+func CalendarFeedAPI(c *fiber.Ctx, db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE calendar_token = $1)", c.Params("token")).Scan(&exists)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load calendar",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Calendar not found",
+		})
+	}
+
+	opts := exporter.CalendarOptions{Name: "Farmily"}
+	for _, kind := range strings.Split(c.Query("types"), ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		valid := false
+		for _, k := range exporter.CalendarKinds {
+			valid = valid || k == kind
+		}
+		if !valid {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid type: must be " + strings.Join(exporter.CalendarKinds, ", "),
+			})
+		}
+		opts.Kinds = append(opts.Kinds, kind)
+	}
+
+	d, err := loadSelection(c, db)
+	if d == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.Calendar(&buf, d, opts); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to write calendar",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="farmily.ics"`)
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,55 @@
+package exports
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupExportRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/export")
+	api.Use(auth.AuthMiddleware)
+
+	api.Get("/gedcom", func(c *fiber.Ctx) error {
+		return ExportGEDCOMAPI(c, db)
+	})
+	api.Get("/gedcomx", func(c *fiber.Ctx) error {
+		return ExportGEDCOMXAPI(c, db)
+	})
+	api.Get("/csv", func(c *fiber.Ctx) error {
+		return ExportCSVAPI(c, db)
+	})
+	api.Get("/xlsx", func(c *fiber.Ctx) error {
+		return ExportXLSXAPI(c, db)
+	})
+	api.Get("/gedzip", func(c *fiber.Ctx) error {
+		return ExportGEDZIPAPI(c, db)
+	})
+	api.Get("/dot", func(c *fiber.Ctx) error {
+		return ExportDOTAPI(c, db)
+	})
+	api.Get("/svg", func(c *fiber.Ctx) error {
+		return ExportSVGAPI(c, db)
+	})
+	api.Get("/book", func(c *fiber.Ctx) error {
+		return ExportBookAPI(c, db)
+	})
+
+	// Calendar feed URL of the current user
+	api.Get("/calendar", func(c *fiber.Ctx) error {
+		return GetCalendarAPI(c, db)
+	})
+	api.Post("/calendar/token", func(c *fiber.Ctx) error {
+		return ResetCalendarTokenAPI(c, db)
+	})
+	api.Delete("/calendar/token", func(c *fiber.Ctx) error {
+		return DeleteCalendarTokenAPI(c, db)
+	})
+
+	// The feed itself is fetched by calendar apps, which can't log in; the
+	// token in the URL identifies the user instead
+	app.Get("/calendar/:token.ics", func(c *fiber.Ctx) error {
+		return CalendarFeedAPI(c, db)
+	})
+}
@@ -0,0 +1,157 @@
+package imports
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"farmily/app/gedcom"
+	"farmily/app/gedcomx"
+	"farmily/app/importer"
+	"farmily/app/routes/auth"
+	"io"
+	"log"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// MaxImportSize is the largest file accepted for import
+const MaxImportSize = 50 << 20
+
+// importFunc loads one file format into the database
+type importFunc func(db *sql.DB, r io.Reader, userID uuid.NullUUID) (*importer.Report, error)
+
+// ImportGEDCOMAPI loads an uploaded GEDCOM file (multipart "file"). Imports
+// add many people at once, so only editors and admins may run them.
+func ImportGEDCOMAPI(c *fiber.Ctx, db *sql.DB) error {
+	return importUpload(c, db, "GEDCOM", importer.ImportGEDCOM)
+}
+
+// ImportGEDCOMXAPI loads an uploaded GEDCOM X JSON document (multipart
+// "file"). People and events it already describes are updated in place.
+// The optional "source" field names where the document comes from, so its
+// person and fact IDs can be matched on later imports from the same source.
+func ImportGEDCOMXAPI(c *fiber.Ctx, db *sql.DB) error {
+	opts := importer.GEDCOMXOptions{Source: c.FormValue("source")}
+
+	return importUpload(c, db, "GEDCOM X", func(db *sql.DB, r io.Reader, userID uuid.NullUUID) (*importer.Report, error) {
+		return importer.ImportGEDCOMX(db, r, userID, opts)
+	})
+}
+
+// ImportCSVAPI loads people from an uploaded CSV file (multipart "file").
+// Optional form fields: "mapping", a JSON object of field to column header
+// such as {"first_name": "Given names"}; "delimiter"; and "dry_run=true" to
+// check the file and report what would be created without saving anything.
+func ImportCSVAPI(c *fiber.Ctx, db *sql.DB) error {
+	opts := importer.CSVOptions{DryRun: c.FormValue("dry_run") == "true"}
+
+	if mapping := c.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid mapping: must be a JSON object of field to column name",
+			})
+		}
+	}
+
+	switch d := c.FormValue("delimiter"); d {
+	case "":
+	case "tab", "\\t":
+		opts.Delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(d)
+		if size != len(d) || r == '"' || r == '\r' || r == '\n' {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid delimiter: must be a single character",
+			})
+		}
+		opts.Delimiter = r
+	}
+
+	return importUpload(c, db, "CSV", func(db *sql.DB, r io.Reader, userID uuid.NullUUID) (*importer.Report, error) {
+		return importer.ImportCSV(db, r, userID, opts)
+	})
+}
+
+// importUpload runs an import of the uploaded file and responds with its
+// report
+func importUpload(c *fiber.Ctx, db *sql.DB, format string, run importFunc) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	if !user.CanEditOthers() {
+		return c.Status(403).JSON(fiber.Map{
+			"success": false,
+			"message": "Only editors and admins can import files",
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "No file uploaded",
+		})
+	}
+
+	if fileHeader.Size > MaxImportSize {
+		return c.Status(413).JSON(fiber.Map{
+			"success": false,
+			"message": "File is too large to import (limit 50 MB)",
+		})
+	}
+
+	f, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read upload",
+		})
+	}
+	defer f.Close()
+
+	report, err := run(db, f, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		var perr *gedcom.ParseError
+		var xerr *gedcomx.ParseError
+		var rerr *importer.RowError
+		if errors.As(err, &perr) || errors.As(err, &xerr) || errors.As(err, &rerr) ||
+			errors.Is(err, importer.ErrInvalidMapping) {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid " + format + " file: " + err.Error(),
+			})
+		}
+		log.Printf("%s import failed: %v", format, err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Import failed; nothing was imported",
+		})
+	}
+
+	if len(report.Errors) > 0 {
+		return c.Status(422).JSON(fiber.Map{
+			"success": false,
+			"message": "Some rows are invalid; nothing was imported",
+			"data":    report,
+		})
+	}
+
+	message := format + " imported successfully"
+	if report.DryRun {
+		message = format + " checked; nothing was saved"
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"data":    report,
+	})
+}
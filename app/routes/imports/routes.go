@@ -0,0 +1,23 @@
+package imports
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupImportRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/import")
+	api.Use(auth.AuthMiddleware)
+
+	api.Post("/gedcom", func(c *fiber.Ctx) error {
+		return ImportGEDCOMAPI(c, db)
+	})
+	api.Post("/csv", func(c *fiber.Ctx) error {
+		return ImportCSVAPI(c, db)
+	})
+	api.Post("/gedcomx", func(c *fiber.Ctx) error {
+		return ImportGEDCOMXAPI(c, db)
+	})
+}
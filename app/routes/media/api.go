@@ -0,0 +1,386 @@
+package media
+
+import (
+	"database/sql"
+	"errors"
+	"farmily/app/config"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"farmily/app/storage"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const mediaSelect = `
+	SELECT m.id, m.person_id, m.event_id, m.file_path, m.file_type, m.title,
+		m.description, m.original_name, m.content_type, m.file_size, m.uploaded_by,
+		m.taken_at, m.latitude, m.longitude, m.upload_date, COALESCE(p.first_name || ' ' || p.last_name, '') as person_name
+	FROM media m
+	LEFT JOIN people p ON m.person_id = p.id
+`
+
+func scanMediaRow(scan func(dest ...interface{}) error) (models.Media, string, error) {
+	var m models.Media
+	var personName string
+	err := scan(
+		&m.ID, &m.PersonID, &m.EventID, &m.FilePath, &m.FileType, &m.Title,
+		&m.Description, &m.OriginalName, &m.ContentType, &m.FileSize, &m.UploadedBy,
+		&m.TakenAt, &m.Latitude, &m.Longitude, &m.UploadDate, &personName,
+	)
+	return m, personName, err
+}
+
+func scanMediaList(rows *sql.Rows) []models.MediaResponse {
+	// Initialize as empty slice to ensure JSON [] instead of null
+	items := []models.MediaResponse{}
+	for rows.Next() {
+		m, personName, err := scanMediaRow(rows.Scan)
+		if err != nil {
+			continue
+		}
+		items = append(items, m.ToResponse(personName))
+	}
+	return items
+}
+
+func getMedia(db *sql.DB, mediaID uuid.UUID) (models.Media, string, error) {
+	return scanMediaRow(db.QueryRow(mediaSelect+" WHERE m.id = $1", mediaID).Scan)
+}
+
+// parseOptionalID parses a form value that may be empty
+func parseOptionalID(value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func UploadMediaAPI(c *fiber.Ctx, db *sql.DB) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "No file uploaded",
+		})
+	}
+
+	personID, err := parseOptionalID(c.FormValue("person_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	eventID, err := parseOptionalID(c.FormValue("event_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event ID",
+		})
+	}
+
+	if personID == nil && eventID == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Media must be linked to a person or an event",
+		})
+	}
+
+	if personID != nil {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", *personID).Scan(&exists)
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Person not found",
+			})
+		}
+	}
+
+	if eventID != nil {
+		var exists bool
+		db.QueryRow("SELECT EXISTS(SELECT 1 FROM events WHERE id = $1)", *eventID).Scan(&exists)
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Event not found",
+			})
+		}
+	}
+
+	mediaID, uerr := createMedia(db, newUpload{
+		File:          fileHeader,
+		PersonID:      personID,
+		EventID:       eventID,
+		Title:         c.FormValue("title"),
+		Description:   c.FormValue("description"),
+		RequestedType: c.FormValue("file_type"),
+		UploadedBy:    userID,
+	})
+	if uerr != nil {
+		return c.Status(uerr.Status).JSON(fiber.Map{
+			"success": false,
+			"message": uerr.Message,
+		})
+	}
+
+	m, personName, err := getMedia(db, mediaID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Media uploaded successfully",
+		"id":      mediaID,
+		"data":    m.ToResponse(personName),
+	})
+}
+
+func GetMediaAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	m, personName, err := getMedia(db, mediaID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    withTags(db, []models.MediaResponse{m.ToResponse(personName)})[0],
+	})
+}
+
+func ServeMediaFileAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	m, _, err := getMedia(db, mediaID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	// Resized variants of images are requested with ?size=thumb|medium
+	if size := c.Query("size"); size != "" {
+		if _, ok := variantSizes[size]; !ok {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid size, expected thumb or medium",
+			})
+		}
+		if m.FileType != models.MediaImage {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Only images have resized variants",
+			})
+		}
+
+		variantKey, err := ensureVariant(c.Context(), m.ID, m.FilePath, size)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to resize image",
+			})
+		}
+
+		c.Set(fiber.HeaderContentType, "image/jpeg")
+		c.Set("X-Content-Type-Options", "nosniff")
+		c.Set(fiber.HeaderCacheControl, "private, max-age=604800")
+		return sendObject(c, variantKey)
+	}
+
+	if m.ContentType.Valid {
+		c.Set(fiber.HeaderContentType, m.ContentType.String)
+	}
+	if m.OriginalName.Valid {
+		c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{
+			"filename": m.OriginalName.String,
+		}))
+	}
+	c.Set("X-Content-Type-Options", "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=86400")
+
+	return sendObject(c, m.FilePath)
+}
+
+// sendObject writes a stored object to the response, serving local files
+// directly so range requests (video seeking) keep working
+func sendObject(c *fiber.Ctx, key string) error {
+	backend := config.GetStorage()
+	if fb, ok := backend.(storage.FileBackend); ok {
+		file, err := fb.Path(key)
+		if err != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "File not found",
+			})
+		}
+		return c.SendFile(file)
+	}
+
+	r, info, err := backend.Get(c.Context(), key)
+	if err == storage.ErrNotFound || errors.Is(err, storage.ErrInvalidKey) {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "File not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to read file",
+		})
+	}
+
+	// SendStream closes the reader once the body has been written
+	return c.SendStream(r, int(info.Size))
+}
+
+func GetPersonMediaAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	// A person's gallery holds media filed under them and photos they are tagged in
+	rows, err := db.Query(mediaSelect+`
+		WHERE m.person_id = $1
+			OR EXISTS(SELECT 1 FROM media_people mp WHERE mp.media_id = m.id AND mp.person_id = $1)
+		ORDER BY COALESCE(m.taken_at, m.upload_date) DESC
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch media",
+		})
+	}
+	defer rows.Close()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    withTags(db, scanMediaList(rows)),
+	})
+}
+
+func GetEventMediaAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	eventID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event ID",
+		})
+	}
+
+	rows, err := db.Query(mediaSelect+`
+		WHERE m.event_id = $1
+		ORDER BY m.upload_date DESC
+	`, eventID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch media",
+		})
+	}
+	defer rows.Close()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    withTags(db, scanMediaList(rows)),
+	})
+}
+
+func DeleteMediaAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	// People using this image as their portrait lose it along with the media
+	var portraits []string
+	if rows, err := db.Query("SELECT id FROM people WHERE profile_media_id = $1", mediaID); err == nil {
+		for rows.Next() {
+			var personID uuid.UUID
+			if rows.Scan(&personID) == nil {
+				portraits = append(portraits, portraitKeys(personID)...)
+			}
+		}
+		rows.Close()
+	}
+
+	var key string
+	err = db.QueryRow("DELETE FROM media WHERE id = $1 RETURNING file_path", mediaID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete media",
+		})
+	}
+
+	keys := append([]string{key}, variantKeys(mediaID)...)
+	RemoveFiles(append(keys, portraits...))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Media deleted successfully",
+	})
+}
@@ -0,0 +1,265 @@
+package media
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"farmily/app/config"
+	"farmily/app/imaging"
+	"farmily/app/models"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload size limits per media type
+const (
+	MaxImageSize    = 20 * 1024 * 1024
+	MaxDocumentSize = 25 * 1024 * 1024
+	MaxVideoSize    = 200 * 1024 * 1024
+
+	// MaxUploadSize is the largest request body the app must accept for uploads
+	MaxUploadSize = MaxVideoSize + 1024*1024
+)
+
+var maxSizes = map[string]int64{
+	models.MediaImage:    MaxImageSize,
+	models.MediaDocument: MaxDocumentSize,
+	models.MediaVideo:    MaxVideoSize,
+}
+
+type uploadFormat struct {
+	FileType     string
+	ContentTypes []string
+}
+
+// allowedFormats maps file extensions to the media type they are stored as
+// and the content types http.DetectContentType may report for them
+var allowedFormats = map[string]uploadFormat{
+	".jpg":  {models.MediaImage, []string{"image/jpeg"}},
+	".jpeg": {models.MediaImage, []string{"image/jpeg"}},
+	".png":  {models.MediaImage, []string{"image/png"}},
+	".gif":  {models.MediaImage, []string{"image/gif"}},
+	".webp": {models.MediaImage, []string{"image/webp"}},
+	".pdf":  {models.MediaDocument, []string{"application/pdf"}},
+	".txt":  {models.MediaDocument, []string{"text/plain"}},
+	".docx": {models.MediaDocument, []string{"application/zip"}},
+	".odt":  {models.MediaDocument, []string{"application/zip"}},
+	".mp4":  {models.MediaVideo, []string{"video/mp4"}},
+	".webm": {models.MediaVideo, []string{"video/webm"}},
+}
+
+// uploadError is a validation failure that should be reported to the client
+type uploadError struct {
+	Status  int
+	Message string
+}
+
+func (e *uploadError) Error() string {
+	return e.Message
+}
+
+// inspectUpload checks the file's extension, size and sniffed content type
+// and returns the media type and content type to store it with
+func inspectUpload(fh *multipart.FileHeader, requestedType string) (string, string, error) {
+	ext := strings.ToLower(filepath.Ext(fh.Filename))
+	format, ok := allowedFormats[ext]
+	if !ok {
+		return "", "", &uploadError{400, "Unsupported file type " + ext}
+	}
+
+	if requestedType != "" && requestedType != format.FileType {
+		return "", "", &uploadError{400, "File does not match the requested file type " + requestedType}
+	}
+
+	if fh.Size > maxSizes[format.FileType] {
+		return "", "", &uploadError{413, "File is too large for type " + format.FileType}
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", err
+	}
+
+	sniffed := http.DetectContentType(head[:n])
+	sniffed = strings.TrimSpace(strings.Split(sniffed, ";")[0])
+	for _, allowed := range format.ContentTypes {
+		if sniffed == allowed {
+			contentType := allowed
+			if allowed == "application/zip" {
+				// Office documents are zip containers; keep the more useful type
+				contentType = mimeByExtension(ext)
+			}
+			return format.FileType, contentType, nil
+		}
+	}
+
+	return "", "", &uploadError{400, "File content does not match its extension"}
+}
+
+func mimeByExtension(ext string) string {
+	switch ext {
+	case ".docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ".odt":
+		return "application/vnd.oasis.opendocument.text"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// storeUpload copies the upload into the storage backend and returns its key
+func storeUpload(fh *multipart.FileHeader, mediaID uuid.UUID, contentType string) (string, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	key := time.Now().Format("2006/01") + "/" + mediaID.String() + strings.ToLower(filepath.Ext(fh.Filename))
+	if err := config.GetStorage().Put(context.Background(), key, src, fh.Size, contentType); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// newUpload describes a file being added to the media table
+type newUpload struct {
+	File          *multipart.FileHeader
+	PersonID      *uuid.UUID
+	EventID       *uuid.UUID
+	Title         string
+	Description   string
+	RequestedType string
+	UploadedBy    uuid.UUID
+}
+
+// createMedia validates and stores an upload, inserts its media row and
+// schedules resized variants for images
+func createMedia(db *sql.DB, u newUpload) (uuid.UUID, *uploadError) {
+	fileType, contentType, err := inspectUpload(u.File, u.RequestedType)
+	if err != nil {
+		var uerr *uploadError
+		if errors.As(err, &uerr) {
+			return uuid.Nil, uerr
+		}
+		return uuid.Nil, &uploadError{500, "Failed to read upload"}
+	}
+
+	// Refuse images whose dimensions would exhaust memory when resized
+	if fileType == models.MediaImage {
+		if f, err := u.File.Open(); err == nil {
+			err = imaging.CheckSize(f)
+			f.Close()
+			if errors.Is(err, imaging.ErrTooManyPixels) {
+				return uuid.Nil, &uploadError{413, "Image is too large (limit 50 megapixels)"}
+			}
+		}
+	}
+
+	mediaID := uuid.New()
+	key, err := storeUpload(u.File, mediaID, contentType)
+	if err != nil {
+		return uuid.Nil, &uploadError{500, "Failed to store file"}
+	}
+
+	var title, description interface{}
+	if u.Title != "" {
+		title = u.Title
+	}
+	if u.Description != "" {
+		description = u.Description
+	}
+
+	// Photos may carry their capture date and position in EXIF
+	var meta imaging.Metadata
+	if fileType == models.MediaImage {
+		if f, err := u.File.Open(); err == nil {
+			meta = imaging.ReadMetadata(f)
+			f.Close()
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO media (id, person_id, event_id, file_path, file_type, title, description,
+			original_name, content_type, file_size, uploaded_by, taken_at, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, mediaID, u.PersonID, u.EventID, key, fileType, title, description,
+		u.File.Filename, contentType, u.File.Size, u.UploadedBy,
+		meta.TakenAt, meta.Latitude, meta.Longitude)
+
+	if err != nil {
+		RemoveFiles([]string{key})
+		return uuid.Nil, &uploadError{500, "Failed to save media"}
+	}
+
+	if fileType == models.MediaImage {
+		QueueVariants(mediaID, key)
+	}
+
+	return mediaID, nil
+}
+
+// RemoveFiles deletes stored media files, logging any that can't be removed
+func RemoveFiles(keys []string) {
+	backend := config.GetStorage()
+	for _, key := range keys {
+		if err := backend.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to remove media file %s: %v", key, err)
+		}
+	}
+}
+
+// PersonFileKeys returns the storage keys, including resized variants, of every media row that is removed when
+// the person is deleted, including media attached to the person's events, plus the person's portraits
+func PersonFileKeys(db *sql.DB, personID uuid.UUID) ([]string, error) {
+	keys, err := queryFileKeys(db, `
+		SELECT id, file_path FROM media
+		WHERE person_id = $1 OR event_id IN (SELECT id FROM events WHERE person_id = $1)
+	`, personID)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, portraitKeys(personID)...), nil
+}
+
+// EventFileKeys returns the storage keys, including resized variants, of every
+// media row attached to the event
+func EventFileKeys(db *sql.DB, eventID uuid.UUID) ([]string, error) {
+	return queryFileKeys(db, "SELECT id, file_path FROM media WHERE event_id = $1", eventID)
+}
+
+// queryFileKeys returns the original and variant keys of the selected media
+func queryFileKeys(db *sql.DB, query string, id uuid.UUID) ([]string, error) {
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var mediaID uuid.UUID
+		var key string
+		if err := rows.Scan(&mediaID, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		keys = append(keys, variantKeys(mediaID)...)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,397 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"farmily/app/config"
+	"farmily/app/imaging"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// portraitKey returns the storage key a person's resized portrait is cached under
+func portraitKey(personID uuid.UUID, size string) string {
+	return "portraits/" + personID.String() + "/" + size + ".jpg"
+}
+
+// portraitKeys returns the keys of every portrait size a person may have
+func portraitKeys(personID uuid.UUID) []string {
+	keys := make([]string, 0, len(variantSizes))
+	for size := range variantSizes {
+		keys = append(keys, portraitKey(personID, size))
+	}
+	return keys
+}
+
+// generatePortrait crops the image stored at key and stores every portrait
+// size. Without a crop the largest centered square is used.
+//
+// Crops are chosen on the browser's upright preview, so the image must be
+// turned upright by its EXIF orientation before cropping.
+func generatePortrait(ctx context.Context, personID uuid.UUID, key string, crop *models.Region) error {
+	backend := config.GetStorage()
+
+	r, _, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	img, err := imaging.Decode(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	if crop != nil {
+		img = imaging.Crop(img, crop.X, crop.Y, crop.Width, crop.Height)
+	} else {
+		img = imaging.CenterSquare(img)
+	}
+
+	for size, maxSide := range variantSizes {
+		data, err := imaging.EncodeJPEG(imaging.Fit(img, maxSide))
+		if err != nil {
+			return err
+		}
+		err = backend.Put(ctx, portraitKey(personID, size), bytes.NewReader(data), int64(len(data)), "image/jpeg")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discardUpload deletes a media row and its files, for an upload that
+// couldn't be made into a portrait
+func discardUpload(db *sql.DB, mediaID uuid.UUID) {
+	keys, err := queryFileKeys(db, "SELECT id, file_path FROM media WHERE id = $1", mediaID)
+	if err != nil {
+		log.Printf("Failed to look up discarded upload %s: %v", mediaID, err)
+		return
+	}
+	if _, err := db.Exec("DELETE FROM media WHERE id = $1", mediaID); err != nil {
+		log.Printf("Failed to delete discarded upload %s: %v", mediaID, err)
+		return
+	}
+	RemoveFiles(keys)
+}
+
+// loadPortrait returns the original image key and crop of a person's portrait,
+// or ok=false if the person has none
+func loadPortrait(db *sql.DB, personID uuid.UUID) (key string, crop *models.Region, ok bool, err error) {
+	var x, y, w, h sql.NullFloat64
+	err = db.QueryRow(`
+		SELECT m.file_path, p.profile_crop_x, p.profile_crop_y, p.profile_crop_width, p.profile_crop_height
+		FROM people p
+		JOIN media m ON p.profile_media_id = m.id
+		WHERE p.id = $1
+	`, personID).Scan(&key, &x, &y, &w, &h)
+	if err == sql.ErrNoRows {
+		return "", nil, false, nil
+	} else if err != nil {
+		return "", nil, false, err
+	}
+
+	if x.Valid && y.Valid && w.Valid && h.Valid {
+		crop = &models.Region{X: x.Float64, Y: y.Float64, Width: w.Float64, Height: h.Float64}
+	}
+	return key, crop, true, nil
+}
+
+// parseCropForm reads an optional crop from crop_x, crop_y, crop_width and
+// crop_height form fields
+func parseCropForm(c *fiber.Ctx) (*models.Region, error) {
+	fields := []string{"crop_x", "crop_y", "crop_width", "crop_height"}
+	values := make([]float64, len(fields))
+	set := 0
+	for i, field := range fields {
+		v := strings.TrimSpace(c.FormValue(field))
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = f
+		set++
+	}
+
+	if set == 0 {
+		return nil, nil
+	}
+	if set != len(fields) {
+		return nil, strconv.ErrSyntax
+	}
+	return &models.Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}, nil
+}
+
+// SetProfilePhotoAPI sets a person's portrait, either from a new upload
+// (multipart "file" plus optional crop_* fields) or from an existing image
+// (JSON media_id plus optional crop). The resized portraits are generated
+// before responding so the returned URLs work immediately.
+func SetProfilePhotoAPI(c *fiber.Ctx, db *sql.DB) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", personID).Scan(&exists)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	var mediaID uuid.UUID
+	var crop *models.Region
+	uploaded := false
+
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		crop, err = parseCropForm(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Crop needs numeric crop_x, crop_y, crop_width and crop_height",
+			})
+		}
+		if crop != nil && !crop.IsValid() {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Crop must be fractions of the image between 0 and 1",
+			})
+		}
+
+		var uerr *uploadError
+		mediaID, uerr = createMedia(db, newUpload{
+			File:          fileHeader,
+			PersonID:      &personID,
+			Title:         c.FormValue("title"),
+			RequestedType: models.MediaImage,
+			UploadedBy:    userID,
+		})
+		if uerr != nil {
+			return c.Status(uerr.Status).JSON(fiber.Map{
+				"success": false,
+				"message": uerr.Message,
+			})
+		}
+		uploaded = true
+	} else {
+		var req struct {
+			MediaID string         `json:"media_id"`
+			Crop    *models.Region `json:"crop"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request body",
+			})
+		}
+
+		mediaID, err = uuid.Parse(req.MediaID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Upload a file or give the media_id of an existing image",
+			})
+		}
+
+		crop = req.Crop
+		if crop != nil && !crop.IsValid() {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Crop must be fractions of the image between 0 and 1",
+			})
+		}
+
+		var fileType string
+		err = db.QueryRow("SELECT file_type FROM media WHERE id = $1", mediaID).Scan(&fileType)
+		if err == sql.ErrNoRows {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Media not found",
+			})
+		} else if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+
+		if fileType != models.MediaImage {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Profile photos must be images",
+			})
+		}
+	}
+
+	// A new upload that can't become the portrait isn't kept
+	fail := func(status int, message string) error {
+		if uploaded {
+			discardUpload(db, mediaID)
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": message,
+		})
+	}
+
+	var key string
+	if err := db.QueryRow("SELECT file_path FROM media WHERE id = $1", mediaID).Scan(&key); err != nil {
+		return fail(500, "Database error")
+	}
+
+	if err := generatePortrait(c.Context(), personID, key, crop); errors.Is(err, imaging.ErrTooManyPixels) {
+		return fail(413, "Image is too large (limit 50 megapixels)")
+	} else if err != nil {
+		return fail(422, "Could not read the image")
+	}
+
+	var x, y, w, h interface{}
+	if crop != nil {
+		x, y, w, h = crop.X, crop.Y, crop.Width, crop.Height
+	}
+
+	updatedAt := time.Now()
+	_, err = db.Exec(`
+		UPDATE people SET profile_media_id = $1, profile_photo_url = NULL,
+			profile_crop_x = $2, profile_crop_y = $3, profile_crop_width = $4, profile_crop_height = $5,
+			updated_at = $6
+		WHERE id = $7
+	`, mediaID, x, y, w, h, updatedAt, personID)
+	if err != nil {
+		return fail(500, "Failed to set profile photo")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Profile photo updated successfully",
+		"data": fiber.Map{
+			"media_id":          mediaID,
+			"profile_photo_url": models.PortraitURL(personID, models.VariantMedium, updatedAt),
+			"thumbnail_url":     models.PortraitURL(personID, models.VariantThumb, updatedAt),
+		},
+	})
+}
+
+// GetProfilePhotoAPI serves a person's cropped portrait. ?size=thumb|medium,
+// defaulting to medium. Missing sizes are regenerated from the original.
+func GetProfilePhotoAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	size := c.Query("size", models.VariantMedium)
+	if _, ok := variantSizes[size]; !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Size must be thumb or medium",
+		})
+	}
+
+	key, crop, ok, err := loadPortrait(db, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person has no profile photo",
+		})
+	}
+
+	ctx := c.Context()
+	exists, err := config.GetStorage().Exists(ctx, portraitKey(personID, size))
+	if err == nil && !exists {
+		err = generatePortrait(ctx, personID, key, crop)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to prepare profile photo",
+		})
+	}
+
+	c.Set("Cache-Control", "private, max-age=86400")
+	return sendObject(c, portraitKey(personID, size))
+}
+
+// DeleteProfilePhotoAPI clears a person's portrait. The original image stays
+// in their gallery.
+func DeleteProfilePhotoAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	result, err := db.Exec(`
+		UPDATE people SET profile_media_id = NULL, profile_photo_url = NULL,
+			profile_crop_x = NULL, profile_crop_y = NULL, profile_crop_width = NULL, profile_crop_height = NULL,
+			updated_at = $1
+		WHERE id = $2
+	`, time.Now(), personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to remove profile photo",
+		})
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	RemoveFiles(portraitKeys(personID))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Profile photo removed successfully",
+	})
+}
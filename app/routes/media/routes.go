@@ -0,0 +1,71 @@
+package media
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupMediaRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/media")
+	api.Use(auth.AuthMiddleware)
+
+	api.Post("/", func(c *fiber.Ctx) error {
+		return UploadMediaAPI(c, db)
+	})
+
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		return GetMediaAPI(c, db)
+	})
+
+	api.Get("/:id/file", func(c *fiber.Ctx) error {
+		return ServeMediaFileAPI(c, db)
+	})
+
+	api.Get("/:id/event-suggestions", func(c *fiber.Ctx) error {
+		return GetEventSuggestionsAPI(c, db)
+	})
+
+	api.Post("/:id/event", func(c *fiber.Ctx) error {
+		return AttachEventAPI(c, db)
+	})
+
+	api.Get("/:id/tags", func(c *fiber.Ctx) error {
+		return GetMediaTagsAPI(c, db)
+	})
+
+	api.Post("/:id/tags", func(c *fiber.Ctx) error {
+		return TagPersonAPI(c, db)
+	})
+
+	api.Delete("/:id/tags/:personId", func(c *fiber.Ctx) error {
+		return UntagPersonAPI(c, db)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		return DeleteMediaAPI(c, db)
+	})
+
+	// Get media for a specific person or event
+	app.Get("/api/people/:id/media", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetPersonMediaAPI(c, db)
+	})
+
+	// Managed profile photos
+	app.Put("/api/people/:id/photo", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return SetProfilePhotoAPI(c, db)
+	})
+
+	app.Get("/api/people/:id/photo", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetProfilePhotoAPI(c, db)
+	})
+
+	app.Delete("/api/people/:id/photo", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return DeleteProfilePhotoAPI(c, db)
+	})
+
+	app.Get("/api/events/:id/media", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetEventMediaAPI(c, db)
+	})
+}
@@ -0,0 +1,321 @@
+package media
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// suggestionWindowDays is how far from the capture date an event may be and
+// still be suggested; EXIF dates carry no time zone so a day either way is common
+const suggestionWindowDays = 2
+
+type eventMatch struct {
+	Event      models.EventResponse `json:"event"`
+	DaysApart  int                  `json:"days_apart"`
+	SamePerson bool                 `json:"same_person"`
+}
+
+type newEventSuggestion struct {
+	PersonID   string `json:"person_id,omitempty"`
+	EventType  string `json:"event_type"`
+	EventDate  string `json:"event_date"`
+	EventPlace string `json:"event_place,omitempty"`
+}
+
+// coordinatesPlace formats GPS coordinates as a place string for new events
+func coordinatesPlace(m models.Media) string {
+	if !m.Latitude.Valid || !m.Longitude.Valid {
+		return ""
+	}
+	return fmt.Sprintf("%.5f, %.5f", m.Latitude.Float64, m.Longitude.Float64)
+}
+
+// GetEventSuggestionsAPI suggests events a photo could belong to, based on
+// the capture date read from its EXIF data
+func GetEventSuggestionsAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	m, _, err := getMedia(db, mediaID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	resp := m.ToResponse("")
+	matches := []eventMatch{}
+
+	if !m.TakenAt.Valid {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "This file has no capture date",
+			"data": fiber.Map{
+				"taken_at":  nil,
+				"latitude":  resp.Latitude,
+				"longitude": resp.Longitude,
+				"matches":   matches,
+			},
+		})
+	}
+
+	takenDate := m.TakenAt.Time.Format("2006-01-02")
+	rows, err := db.Query(`
+		SELECT e.id, e.person_id, e.event_type, e.event_date, e.event_place,
+			e.description, e.created_at, e.updated_at,
+			p.first_name || ' ' || p.last_name as person_name,
+			ABS(e.event_date - $1::date) as days_apart,
+			COALESCE(e.person_id = $2, false) as same_person
+		FROM events e
+		JOIN people p ON e.person_id = p.id
+		WHERE e.event_date BETWEEN $1::date - $3::int AND $1::date + $3::int
+		ORDER BY days_apart, same_person DESC, e.event_type
+	`, takenDate, m.PersonID, suggestionWindowDays)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch events",
+		})
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Event
+		var personName string
+		var match eventMatch
+		err := rows.Scan(
+			&e.ID, &e.PersonID, &e.EventType, &e.EventDate, &e.EventPlace,
+			&e.Description, &e.CreatedAt, &e.UpdatedAt, &personName,
+			&match.DaysApart, &match.SamePerson,
+		)
+		if err != nil {
+			continue
+		}
+		match.Event = e.ToResponse(personName)
+		matches = append(matches, match)
+	}
+
+	suggestion := newEventSuggestion{
+		EventType:  models.EventOther,
+		EventDate:  takenDate,
+		EventPlace: coordinatesPlace(m),
+	}
+	if m.PersonID.Valid {
+		suggestion.PersonID = m.PersonID.String
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"taken_at":  resp.TakenAt,
+			"latitude":  resp.Latitude,
+			"longitude": resp.Longitude,
+			"matches":   matches,
+			"new_event": suggestion,
+		},
+	})
+}
+
+// AttachEventAPI links media to an existing event, or creates an event dated
+// from the photo's capture date and links it
+func AttachEventAPI(c *fiber.Ctx, db *sql.DB) error {
+	_, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	var req struct {
+		EventID     *string `json:"event_id"`
+		Create      bool    `json:"create"`
+		PersonID    *string `json:"person_id"`
+		EventType   *string `json:"event_type"`
+		EventPlace  *string `json:"event_place"`
+		Description *string `json:"description"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	m, _, err := getMedia(db, mediaID)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	if !req.Create {
+		if req.EventID == nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Provide event_id or set create to true",
+			})
+		}
+		eventID, err := uuid.Parse(*req.EventID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid event ID",
+			})
+		}
+
+		result, err := db.Exec(`
+			UPDATE media SET event_id = $1
+			WHERE id = $2 AND EXISTS(SELECT 1 FROM events WHERE id = $1)
+		`, eventID, mediaID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to attach event",
+			})
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Event not found",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Media attached to event",
+			"id":      eventID,
+		})
+	}
+
+	// Create a new event from the photo's metadata
+	if !m.TakenAt.Valid {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "This file has no capture date to create an event from",
+		})
+	}
+
+	personIDStr := m.PersonID.String
+	if req.PersonID != nil && *req.PersonID != "" {
+		personIDStr = *req.PersonID
+	}
+	personID, err := uuid.Parse(personIDStr)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A valid person_id is required to create an event",
+		})
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", personID).Scan(&exists)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	eventType := models.EventOther
+	if req.EventType != nil && *req.EventType != "" {
+		eventType = *req.EventType
+	}
+	if !models.IsValidEventType(eventType) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid event type",
+		})
+	}
+
+	var eventPlace interface{}
+	if req.EventPlace != nil && *req.EventPlace != "" {
+		eventPlace = *req.EventPlace
+	} else if place := coordinatesPlace(m); place != "" {
+		eventPlace = place
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start transaction",
+		})
+	}
+
+	eventID := uuid.New()
+	_, err = tx.Exec(`
+		INSERT INTO events (id, person_id, event_type, event_date, event_place, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, eventID, personID, eventType, m.TakenAt.Time.Format("2006-01-02"), eventPlace, req.Description)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to create event for media %s: %v", mediaID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create event",
+		})
+	}
+
+	_, err = tx.Exec("UPDATE media SET event_id = $1 WHERE id = $2", eventID, mediaID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to attach event",
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to commit transaction",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Event created and attached",
+		"id":      eventID,
+	})
+}
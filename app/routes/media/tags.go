@@ -0,0 +1,239 @@
+package media
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// loadTags returns the people tagged in each of the given media
+func loadTags(db *sql.DB, mediaIDs []uuid.UUID) (map[uuid.UUID][]models.MediaTag, error) {
+	tags := map[uuid.UUID][]models.MediaTag{}
+	if len(mediaIDs) == 0 {
+		return tags, nil
+	}
+
+	ids := make([]string, len(mediaIDs))
+	for i, id := range mediaIDs {
+		ids[i] = id.String()
+	}
+
+	rows, err := db.Query(`
+		SELECT mp.media_id, mp.person_id, p.first_name || ' ' || p.last_name as person_name,
+			mp.region_x, mp.region_y, mp.region_width, mp.region_height, mp.created_at
+		FROM media_people mp
+		JOIN people p ON mp.person_id = p.id
+		WHERE mp.media_id = ANY($1::uuid[])
+		ORDER BY mp.region_x NULLS LAST, p.last_name, p.first_name
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.MediaTag
+		var x, y, w, h sql.NullFloat64
+		if err := rows.Scan(&t.MediaID, &t.PersonID, &t.PersonName, &x, &y, &w, &h, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if x.Valid && y.Valid && w.Valid && h.Valid {
+			t.Region = &models.Region{X: x.Float64, Y: y.Float64, Width: w.Float64, Height: h.Float64}
+		}
+		tags[t.MediaID] = append(tags[t.MediaID], t)
+	}
+
+	return tags, rows.Err()
+}
+
+// withTags fills in the tags of each media response
+func withTags(db *sql.DB, items []models.MediaResponse) []models.MediaResponse {
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	tags, err := loadTags(db, ids)
+	if err != nil {
+		return items
+	}
+	for i := range items {
+		if t, ok := tags[items[i].ID]; ok {
+			items[i].Tags = t
+		}
+	}
+	return items
+}
+
+func GetMediaTagsAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	tags, err := loadTags(db, []uuid.UUID{mediaID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch tags",
+		})
+	}
+
+	data := tags[mediaID]
+	if data == nil {
+		data = []models.MediaTag{}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// TagPersonAPI tags a person in a photo, optionally with the rectangle their
+// face occupies. Tagging the same person again replaces the region.
+func TagPersonAPI(c *fiber.Ctx, db *sql.DB) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	var req struct {
+		PersonID string         `json:"person_id"`
+		Region   *models.Region `json:"region"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	personID, err := uuid.Parse(req.PersonID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	var x, y, w, h interface{}
+	if req.Region != nil {
+		if !req.Region.IsValid() {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Region must be fractions of the image between 0 and 1",
+			})
+		}
+		x, y, w, h = req.Region.X, req.Region.Y, req.Region.Width, req.Region.Height
+	}
+
+	var fileType string
+	err = db.QueryRow("SELECT file_type FROM media WHERE id = $1", mediaID).Scan(&fileType)
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Media not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	if fileType != models.MediaImage && req.Region != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Regions can only be set on images",
+		})
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", personID).Scan(&exists)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO media_people (media_id, person_id, region_x, region_y, region_width, region_height, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (media_id, person_id) DO UPDATE SET
+			region_x = EXCLUDED.region_x, region_y = EXCLUDED.region_y,
+			region_width = EXCLUDED.region_width, region_height = EXCLUDED.region_height
+	`, mediaID, personID, x, y, w, h, userID)
+	if err != nil {
+		log.Printf("Failed to tag person %s in media %s: %v", personID, mediaID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to tag person",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Person tagged successfully",
+	})
+}
+
+func UntagPersonAPI(c *fiber.Ctx, db *sql.DB) error {
+	mediaID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid media ID",
+		})
+	}
+
+	personID, err := uuid.Parse(c.Params("personId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	_, err = db.Exec("DELETE FROM media_people WHERE media_id = $1 AND person_id = $2", mediaID, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to remove tag",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Tag removed successfully",
+	})
+}
@@ -0,0 +1,108 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"farmily/app/config"
+	"farmily/app/imaging"
+	"farmily/app/models"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// variantSizes is the longest side, in pixels, of each resized variant
+var variantSizes = map[string]int{
+	models.VariantThumb:  200,
+	models.VariantMedium: 800,
+}
+
+type variantJob struct {
+	MediaID uuid.UUID
+	Key     string
+}
+
+var variantJobs = make(chan variantJob, 256)
+
+// StartVariantWorkers starts the background goroutines that resize new images
+func StartVariantWorkers(n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for job := range variantJobs {
+				if err := generateVariants(context.Background(), job.MediaID, job.Key); err != nil {
+					log.Printf("Failed to generate variants for media %s: %v", job.MediaID, err)
+				}
+			}
+		}()
+	}
+}
+
+// QueueVariants schedules thumbnail and medium variants for an image. If the
+// queue is full the variants are generated on first request instead.
+func QueueVariants(mediaID uuid.UUID, key string) {
+	select {
+	case variantJobs <- variantJob{MediaID: mediaID, Key: key}:
+	default:
+		log.Printf("Variant queue full; media %s will be resized on first request", mediaID)
+	}
+}
+
+// VariantKey returns the storage key a resized variant is cached under
+func VariantKey(mediaID uuid.UUID, size string) string {
+	return "variants/" + mediaID.String() + "/" + size + ".jpg"
+}
+
+// variantKeys returns the keys of every variant the media may have
+func variantKeys(mediaID uuid.UUID) []string {
+	keys := make([]string, 0, len(variantSizes))
+	for size := range variantSizes {
+		keys = append(keys, VariantKey(mediaID, size))
+	}
+	return keys
+}
+
+// generateVariants decodes the original image and stores every variant
+func generateVariants(ctx context.Context, mediaID uuid.UUID, key string) error {
+	backend := config.GetStorage()
+
+	r, _, err := backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	img, err := imaging.Decode(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	for size, maxSide := range variantSizes {
+		data, err := imaging.EncodeJPEG(imaging.Fit(img, maxSide))
+		if err != nil {
+			return err
+		}
+		err = backend.Put(ctx, VariantKey(mediaID, size), bytes.NewReader(data), int64(len(data)), "image/jpeg")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureVariant returns the key of the requested variant, generating and
+// caching it first if the background worker hasn't produced it yet
+func ensureVariant(ctx context.Context, mediaID uuid.UUID, key, size string) (string, error) {
+	variantKey := VariantKey(mediaID, size)
+
+	exists, err := config.GetStorage().Exists(ctx, variantKey)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if err := generateVariants(ctx, mediaID, key); err != nil {
+			return "", err
+		}
+	}
+
+	return variantKey, nil
+}
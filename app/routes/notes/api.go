@@ -0,0 +1,381 @@
+package notes
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"farmily/app/routes/auth"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const noteSelect = `
+	SELECT n.id, n.person_id, n.content, n.created_by, n.updated_by, n.created_at, n.updated_at,
+		COALESCE(a.first_name || ' ' || a.last_name, 'Unknown') as author_name,
+		COALESCE(e.first_name || ' ' || e.last_name, '') as editor_name,
+		(SELECT COUNT(*) FROM note_revisions r WHERE r.note_id = n.id) as revision_count
+	FROM notes n
+	LEFT JOIN users a ON n.created_by = a.id
+	LEFT JOIN users e ON n.updated_by = e.id
+`
+
+// canEdit reports whether the user may change or delete the note
+func canEdit(user *models.User, n *models.Note) bool {
+	return user.CanEditOthers() || (n.CreatedBy.Valid && n.CreatedBy.UUID == user.ID)
+}
+
+func scanNote(scan func(dest ...interface{}) error, user *models.User) (models.NoteResponse, error) {
+	var n models.Note
+	var authorName, editorName string
+	var revisionCount int
+	err := scan(
+		&n.ID, &n.PersonID, &n.Content, &n.CreatedBy, &n.UpdatedBy, &n.CreatedAt, &n.UpdatedAt,
+		&authorName, &editorName, &revisionCount,
+	)
+	if err != nil {
+		return models.NoteResponse{}, err
+	}
+	return n.ToResponse(authorName, editorName, revisionCount, canEdit(user, &n)), nil
+}
+
+func GetPersonNotesAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	rows, err := db.Query(noteSelect+`
+		WHERE n.person_id = $1 AND n.deleted_at IS NULL
+		ORDER BY n.created_at DESC
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch notes",
+		})
+	}
+	defer rows.Close()
+
+	// Initialize as empty slice to ensure JSON [] instead of null
+	notes := []models.NoteResponse{}
+	for rows.Next() {
+		note, err := scanNote(rows.Scan, user)
+		if err != nil {
+			continue
+		}
+		notes = append(notes, note)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    notes,
+	})
+}
+
+func CreateNoteAPI(c *fiber.Ctx, db *sql.DB) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(req.Content) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Content is required",
+		})
+	}
+
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", personID).Scan(&exists)
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	noteID := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO notes (id, person_id, content, created_by)
+		VALUES ($1, $2, $3, $4)
+	`, noteID, personID, req.Content, userID)
+
+	if err != nil {
+		log.Printf("Failed to create note for person %s: %v", personID, err)
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create note",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Note created successfully",
+		"id":      noteID,
+	})
+}
+
+// loadNoteForChange fetches a note inside tx, locking it, and checks the user may change it
+func loadNoteForChange(tx *sql.Tx, noteID uuid.UUID, user *models.User) (*models.Note, int, string) {
+	var n models.Note
+	err := tx.QueryRow(`
+		SELECT id, person_id, content, created_by, updated_by, created_at, updated_at
+		FROM notes WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, noteID).Scan(&n.ID, &n.PersonID, &n.Content, &n.CreatedBy, &n.UpdatedBy, &n.CreatedAt, &n.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, 404, "Note not found"
+	} else if err != nil {
+		return nil, 500, "Database error"
+	}
+
+	if !canEdit(user, &n) {
+		return nil, 403, "Only the author or an editor can change this note"
+	}
+
+	return &n, 0, ""
+}
+
+func UpdateNoteAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	noteID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid note ID",
+		})
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(req.Content) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Content is required",
+		})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start transaction",
+		})
+	}
+
+	n, status, msg := loadNoteForChange(tx, noteID, user)
+	if n == nil {
+		tx.Rollback()
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	if n.Content == req.Content {
+		tx.Rollback()
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Note unchanged",
+		})
+	}
+
+	// Keep the current version, credited to whoever wrote it
+	writtenBy := n.CreatedBy
+	if n.UpdatedBy.Valid {
+		writtenBy = n.UpdatedBy
+	}
+	_, err = tx.Exec(`
+		INSERT INTO note_revisions (note_id, content, edited_by, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, n.ID, n.Content, writtenBy, n.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save revision",
+		})
+	}
+
+	_, err = tx.Exec(`
+		UPDATE notes SET content = $1, updated_by = $2, updated_at = $3
+		WHERE id = $4
+	`, req.Content, user.ID, time.Now(), n.ID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update note",
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to commit transaction",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Note updated successfully",
+	})
+}
+
+func DeleteNoteAPI(c *fiber.Ctx, db *sql.DB) error {
+	user, err := auth.GetCurrentUser(c, db)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"success": false,
+			"message": "Unauthorized",
+		})
+	}
+
+	id := c.Params("id")
+	noteID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid note ID",
+		})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start transaction",
+		})
+	}
+
+	n, status, msg := loadNoteForChange(tx, noteID, user)
+	if n == nil {
+		tx.Rollback()
+		return c.Status(status).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	// The note is only marked deleted; its content and revisions are kept
+	_, err = tx.Exec("UPDATE notes SET deleted_at = $1, deleted_by = $2 WHERE id = $3", time.Now(), user.ID, n.ID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete note",
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to commit transaction",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Note deleted successfully",
+	})
+}
+
+func GetNoteRevisionsAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	noteID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid note ID",
+		})
+	}
+
+	rows, err := db.Query(`
+		SELECT r.id, r.note_id, r.content, r.edited_by, r.created_at,
+			COALESCE(u.first_name || ' ' || u.last_name, 'Unknown') as editor_name
+		FROM note_revisions r
+		LEFT JOIN users u ON r.edited_by = u.id
+		WHERE r.note_id = $1
+		ORDER BY r.created_at DESC
+	`, noteID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch revisions",
+		})
+	}
+	defer rows.Close()
+
+	revisions := []models.NoteRevision{}
+	for rows.Next() {
+		var r models.NoteRevision
+		var editedBy uuid.NullUUID
+		if err := rows.Scan(&r.ID, &r.NoteID, &r.Content, &editedBy, &r.CreatedAt, &r.EditorName); err != nil {
+			continue
+		}
+		if editedBy.Valid {
+			r.EditedBy = &editedBy.UUID
+		}
+		revisions = append(revisions, r)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    revisions,
+	})
+}
@@ -0,0 +1,34 @@
+package notes
+
+import (
+	"database/sql"
+	"farmily/app/routes/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupNotesRoutes(app *fiber.App, db *sql.DB) {
+	api := app.Group("/api/notes")
+	api.Use(auth.AuthMiddleware)
+
+	api.Put("/:id", func(c *fiber.Ctx) error {
+		return UpdateNoteAPI(c, db)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		return DeleteNoteAPI(c, db)
+	})
+
+	api.Get("/:id/revisions", func(c *fiber.Ctx) error {
+		return GetNoteRevisionsAPI(c, db)
+	})
+
+	// Notes for a specific person
+	app.Get("/api/people/:id/notes", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetPersonNotesAPI(c, db)
+	})
+
+	app.Post("/api/people/:id/notes", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CreateNoteAPI(c, db)
+	})
+}
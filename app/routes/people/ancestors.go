@@ -0,0 +1,82 @@
+package people
+
+import (
+	"database/sql"
+	"farmily/app/family"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetAncestorsAPI lists a person's ancestors with their generation and
+// Ahnentafel number. ?generations=<n> sets how far back to go.
+func GetAncestorsAPI(c *fiber.Ctx, db *sql.DB) error {
+	personID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	generations := c.QueryInt("generations", family.DefaultGenerations)
+	if generations < 1 || generations > family.MaxGenerations {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid generations: must be between 1 and " + strconv.Itoa(family.MaxGenerations),
+		})
+	}
+
+	people, err := loadPeople(db, []uuid.UUID{personID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	p, ok := people[personID]
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	ancestors, err := family.Ancestors(db, personID, generations)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch ancestors",
+		})
+	}
+
+	depth := 0
+	data := make([]fiber.Map, len(ancestors))
+	for i, a := range ancestors {
+		entry := fiber.Map{
+			"person":     a.Person.ToResponse(),
+			"generation": a.Generation,
+			"number":     a.Number,
+			"relation":   a.Relation,
+			"child_id":   a.ChildID,
+		}
+		if len(a.Numbers) > 0 {
+			entry["numbers"] = a.Numbers
+		}
+		data[i] = entry
+		if a.Generation > depth {
+			depth = a.Generation
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"person":      p.ToResponse(),
+			"generations": generations,
+			"depth":       depth,
+			"ancestors":   data,
+		},
+	})
+}
@@ -2,8 +2,10 @@ package people
 
 import (
 	"database/sql"
+	"farmily/app/family"
 	"farmily/app/models"
 	"farmily/app/routes/auth"
+	"farmily/app/routes/media"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,7 +16,7 @@ func GetAllPeopleAPI(c *fiber.Ctx, db *sql.DB) error {
 	rows, err := db.Query(`
 		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
 			birth_date, birth_place, death_date, death_place, is_living,
-			occupation, biography, profile_photo_url, created_by, created_at, updated_at
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
 		FROM people
 		ORDER BY last_name, first_name
 	`)
@@ -32,7 +34,7 @@ func GetAllPeopleAPI(c *fiber.Ctx, db *sql.DB) error {
 		err := rows.Scan(
 			&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
 			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
-			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
 		)
 		if err != nil {
 			continue
@@ -60,12 +62,12 @@ func GetPersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	err = db.QueryRow(`
 		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
 			birth_date, birth_place, death_date, death_place, is_living,
-			occupation, biography, profile_photo_url, created_by, created_at, updated_at
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
 		FROM people WHERE id = $1
 	`, personID).Scan(
 		&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
 		&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
-		&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+		&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -96,21 +98,20 @@ func CreatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	}
 
 	var req struct {
-		FirstName       string  `json:"first_name"`
-		MiddleName      *string `json:"middle_name"`
-		LastName        string  `json:"last_name"`
-		MaidenName      *string `json:"maiden_name"`
-		Gender          string  `json:"gender"`
-		BirthDate       *string `json:"birth_date"`
-		BirthPlace      *string `json:"birth_place"`
-		DeathDate       *string `json:"death_date"`
-		DeathPlace      *string `json:"death_place"`
-		IsLiving        bool    `json:"is_living"`
-		Occupation      *string `json:"occupation"`
-		Biography       *string `json:"biography"`
-		ProfilePhotoURL *string `json:"profile_photo_url"`
-		FatherID        *string `json:"father_id"`
-		MotherID        *string `json:"mother_id"`
+		FirstName  string  `json:"first_name"`
+		MiddleName *string `json:"middle_name"`
+		LastName   string  `json:"last_name"`
+		MaidenName *string `json:"maiden_name"`
+		Gender     string  `json:"gender"`
+		BirthDate  *string `json:"birth_date"`
+		BirthPlace *string `json:"birth_place"`
+		DeathDate  *string `json:"death_date"`
+		DeathPlace *string `json:"death_place"`
+		IsLiving   bool    `json:"is_living"`
+		Occupation *string `json:"occupation"`
+		Biography  *string `json:"biography"`
+		FatherID   *string `json:"father_id"`
+		MotherID   *string `json:"mother_id"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -150,11 +151,11 @@ func CreatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	_, err = tx.Exec(`
 		INSERT INTO people (id, first_name, middle_name, last_name, maiden_name, gender,
 			birth_date, birth_place, death_date, death_place, is_living,
-			occupation, biography, profile_photo_url, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			occupation, biography, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`, personID, req.FirstName, req.MiddleName, req.LastName, req.MaidenName, req.Gender,
 		birthDate, req.BirthPlace, deathDate, req.DeathPlace, req.IsLiving,
-		req.Occupation, req.Biography, req.ProfilePhotoURL, userID)
+		req.Occupation, req.Biography, userID)
 
 	if err != nil {
 		tx.Rollback()
@@ -195,6 +196,26 @@ func CreatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 		}
 	}
 
+	issues, err := family.Check(tx, personID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate person",
+		})
+	}
+	if errs := family.Errors(issues); len(errs) > 0 {
+		tx.Rollback()
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": errs[0].Message,
+			"issues":  errs,
+		})
+	}
+	if issues == nil {
+		issues = []family.Issue{}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -203,9 +224,10 @@ func CreatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Person created successfully",
-		"id":      personID,
+		"success":  true,
+		"message":  "Person created successfully",
+		"id":       personID,
+		"warnings": issues,
 	})
 }
 
@@ -220,21 +242,20 @@ func UpdatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	}
 
 	var req struct {
-		FirstName       string  `json:"first_name"`
-		MiddleName      *string `json:"middle_name"`
-		LastName        string  `json:"last_name"`
-		MaidenName      *string `json:"maiden_name"`
-		Gender          string  `json:"gender"`
-		BirthDate       *string `json:"birth_date"`
-		BirthPlace      *string `json:"birth_place"`
-		DeathDate       *string `json:"death_date"`
-		DeathPlace      *string `json:"death_place"`
-		IsLiving        bool    `json:"is_living"`
-		Occupation      *string `json:"occupation"`
-		Biography       *string `json:"biography"`
-		ProfilePhotoURL *string `json:"profile_photo_url"`
-		FatherID        *string `json:"father_id"`
-		MotherID        *string `json:"mother_id"`
+		FirstName  string  `json:"first_name"`
+		MiddleName *string `json:"middle_name"`
+		LastName   string  `json:"last_name"`
+		MaidenName *string `json:"maiden_name"`
+		Gender     string  `json:"gender"`
+		BirthDate  *string `json:"birth_date"`
+		BirthPlace *string `json:"birth_place"`
+		DeathDate  *string `json:"death_date"`
+		DeathPlace *string `json:"death_place"`
+		IsLiving   bool    `json:"is_living"`
+		Occupation *string `json:"occupation"`
+		Biography  *string `json:"biography"`
+		FatherID   *string `json:"father_id"`
+		MotherID   *string `json:"mother_id"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -261,15 +282,34 @@ func UpdatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 		})
 	}
 
+	if err := family.LockTree(tx); err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate person",
+		})
+	}
+
+	// Issues the tree already has around this person aren't this write's
+	// fault; only new ones are
+	before, err := family.Check(tx, personID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate person",
+		})
+	}
+
 	_, err = tx.Exec(`
 		UPDATE people SET
 			first_name = $1, middle_name = $2, last_name = $3, maiden_name = $4, gender = $5,
 			birth_date = $6, birth_place = $7, death_date = $8, death_place = $9, is_living = $10,
-			occupation = $11, biography = $12, profile_photo_url = $13, updated_at = $14
-		WHERE id = $15
+			occupation = $11, biography = $12, updated_at = $13
+		WHERE id = $14
 	`, req.FirstName, req.MiddleName, req.LastName, req.MaidenName, req.Gender,
 		birthDate, req.BirthPlace, deathDate, req.DeathPlace, req.IsLiving,
-		req.Occupation, req.Biography, req.ProfilePhotoURL, time.Now(), personID)
+		req.Occupation, req.Biography, time.Now(), personID)
 
 	if err != nil {
 		tx.Rollback()
@@ -321,6 +361,24 @@ func UpdatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 		}
 	}
 
+	after, err := family.Check(tx, personID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate person",
+		})
+	}
+	issues := family.Introduced(before, after)
+	if errs := family.Errors(issues); len(errs) > 0 {
+		tx.Rollback()
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": errs[0].Message,
+			"issues":  errs,
+		})
+	}
+
 	if err := tx.Commit(); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -329,8 +387,9 @@ func UpdatePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Person updated successfully",
+		"success":  true,
+		"message":  "Person updated successfully",
+		"warnings": issues,
 	})
 }
 
@@ -344,7 +403,8 @@ func DeletePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 		})
 	}
 
-	_, err = db.Exec("DELETE FROM people WHERE id = $1", personID)
+	// Media rows cascade with the person, so collect their files first
+	mediaFiles, err := media.PersonFileKeys(db, personID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
@@ -352,52 +412,18 @@ func DeletePersonAPI(c *fiber.Ctx, db *sql.DB) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Person deleted successfully",
-	})
-}
-
-func SearchPeopleAPI(c *fiber.Ctx, db *sql.DB) error {
-	query := c.Query("q")
-	if query == "" {
-		return GetAllPeopleAPI(c, db)
-	}
-
-	searchPattern := "%" + query + "%"
-	rows, err := db.Query(`
-		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
-			birth_date, birth_place, death_date, death_place, is_living,
-			occupation, biography, profile_photo_url, created_by, created_at, updated_at
-		FROM people
-		WHERE first_name ILIKE $1 OR last_name ILIKE $1 OR middle_name ILIKE $1
-		ORDER BY last_name, first_name
-	`, searchPattern)
-
+	_, err = db.Exec("DELETE FROM people WHERE id = $1", personID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
-			"message": "Search failed",
+			"message": "Failed to delete person",
 		})
 	}
-	defer rows.Close()
 
-	var people []models.PersonResponse
-	for rows.Next() {
-		var p models.Person
-		err := rows.Scan(
-			&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
-			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
-			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		people = append(people, p.ToResponse())
-	}
+	media.RemoveFiles(mediaFiles)
 
 	return c.JSON(fiber.Map{
 		"success": true,
-		"data":    people,
+		"message": "Person deleted successfully",
 	})
 }
@@ -0,0 +1,90 @@
+package people
+
+import (
+	"database/sql"
+	"farmily/app/family"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetDescendantsAPI lists a person's descendants with their generation,
+// d'Aboville number and the other parent they descend through.
+// ?generations=<n> sets how far down to go.
+func GetDescendantsAPI(c *fiber.Ctx, db *sql.DB) error {
+	personID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	generations := c.QueryInt("generations", family.DefaultGenerations)
+	if generations < 1 || generations > family.MaxGenerations {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid generations: must be between 1 and " + strconv.Itoa(family.MaxGenerations),
+		})
+	}
+
+	people, err := loadPeople(db, []uuid.UUID{personID})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+	p, ok := people[personID]
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	descendants, err := family.Descendants(db, personID, generations)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch descendants",
+		})
+	}
+
+	depth := 0
+	data := make([]fiber.Map, len(descendants))
+	for i, d := range descendants {
+		entry := fiber.Map{
+			"person":       d.Person.ToResponse(),
+			"generation":   d.Generation,
+			"number":       d.Number,
+			"relation":     d.Relation,
+			"parent_id":    d.ParentID,
+			"other_parent": nil,
+		}
+		if d.OtherParent != nil {
+			entry["other_parent"] = fiber.Map{
+				"id":   d.OtherParent.ID,
+				"name": d.OtherParent.GetDisplayName(),
+			}
+		}
+		if len(d.Numbers) > 0 {
+			entry["numbers"] = d.Numbers
+		}
+		data[i] = entry
+		if d.Generation > depth {
+			depth = d.Generation
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"person":      p.ToResponse(),
+			"generations": generations,
+			"depth":       depth,
+			"descendants": data,
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package people
+
+import (
+	"database/sql"
+	"farmily/app/family"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetKinshipAPI names how person :a is related to person :b, with the
+// people the connection goes through
+func GetKinshipAPI(c *fiber.Ctx, db *sql.DB) error {
+	a, err := uuid.Parse(c.Params("a"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+	b, err := uuid.Parse(c.Params("b"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	g, err := family.LoadGraph(db)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load family tree",
+		})
+	}
+	personA, personB := g.Person(a), g.Person(b)
+	if personA == nil || personB == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	}
+
+	k := g.Relate(a, b)
+	data := fiber.Map{
+		"person":   personA.ToResponse(),
+		"relative": personB.ToResponse(),
+		"related":  k.Related,
+	}
+	if !k.Related {
+		data["description"] = personA.GetDisplayName() + " and " + personB.GetDisplayName() + " are not related"
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    data,
+		})
+	}
+
+	path := make([]fiber.Map, len(k.Path))
+	for i, s := range k.Path {
+		path[i] = fiber.Map{
+			"id":       s.PersonID,
+			"name":     g.Person(s.PersonID).GetDisplayName(),
+			"relation": s.Relation,
+		}
+	}
+	common := make([]fiber.Map, len(k.CommonAncestors))
+	for i, id := range k.CommonAncestors {
+		common[i] = fiber.Map{
+			"id":   id,
+			"name": g.Person(id).GetDisplayName(),
+		}
+	}
+
+	data["kind"] = k.Kind
+	data["label"] = k.Label
+	data["description"] = personA.GetDisplayName() + " is " + personB.GetDisplayName() + "'s " + k.Label
+	data["inverse"] = g.Relate(b, a).Label
+	data["common_ancestors"] = common
+	data["path"] = path
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
@@ -40,6 +40,22 @@ func SetupPeopleRoutes(app *fiber.App, db *sql.DB) {
 		return GetPersonAPI(c, db)
 	})
 
+	api.Get("/:id/timeline", func(c *fiber.Ctx) error {
+		return GetPersonTimelineAPI(c, db)
+	})
+
+	api.Get("/:id/ancestors", func(c *fiber.Ctx) error {
+		return GetAncestorsAPI(c, db)
+	})
+
+	api.Get("/:id/descendants", func(c *fiber.Ctx) error {
+		return GetDescendantsAPI(c, db)
+	})
+
+	api.Get("/:a/relationship-to/:b", func(c *fiber.Ctx) error {
+		return GetKinshipAPI(c, db)
+	})
+
 	api.Post("/", func(c *fiber.Ctx) error {
 		return CreatePersonAPI(c, db)
 	})
@@ -51,4 +67,9 @@ func SetupPeopleRoutes(app *fiber.App, db *sql.DB) {
 	api.Delete("/:id", func(c *fiber.Ctx) error {
 		return DeletePersonAPI(c, db)
 	})
+
+	// Ranked full-text search, grouped by person
+	app.Get("/api/search", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return FullTextSearchAPI(c, db)
+	})
 }
@@ -0,0 +1,253 @@
+package people
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Markers ts_headline puts around matched words. They are swapped for <mark>
+// tags after the snippet has been HTML-escaped.
+const (
+	markStart = "[[mark]]"
+	markStop  = "[[/mark]]"
+)
+
+const headlineOptions = `StartSel=` + markStart + `, StopSel=` + markStop +
+	`, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "`
+
+// searchQuery finds every record matching the query and the person each one
+// belongs to. Name fragments also match by substring so partial names work
+// as they did before full-text search.
+const searchQuery = `
+	WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query),
+	matches AS (
+		SELECT p.id AS person_id, 'person' AS source, p.id AS source_id,
+			ts_rank(p.search_vector, q.query) +
+				CASE WHEN p.first_name ILIKE $2 OR p.middle_name ILIKE $2
+					OR p.last_name ILIKE $2 OR p.maiden_name ILIKE $2 THEN 1 ELSE 0 END AS rank,
+			ts_headline('english', concat_ws(' · ',
+				concat_ws(' ', p.first_name, p.middle_name, p.last_name), p.occupation, p.birth_place, p.biography),
+				q.query, $3) AS snippet
+		FROM people p, q
+		WHERE p.search_vector @@ q.query
+			OR p.first_name ILIKE $2 OR p.middle_name ILIKE $2
+			OR p.last_name ILIKE $2 OR p.maiden_name ILIKE $2
+
+		UNION ALL
+
+		SELECT n.person_id, 'note', n.id, ts_rank(n.search_vector, q.query),
+			ts_headline('english', n.content, q.query, $3)
+		FROM notes n, q
+		WHERE n.search_vector @@ q.query AND n.deleted_at IS NULL
+
+		UNION ALL
+
+		SELECT e.person_id, 'event', e.id, ts_rank(e.search_vector, q.query),
+			ts_headline('english', e.description, q.query, $3)
+		FROM events e, q
+		WHERE e.search_vector @@ q.query
+
+		UNION ALL
+
+		SELECT owners.person_id, 'media', m.id, ts_rank(m.search_vector, q.query),
+			ts_headline('english', concat_ws(' · ', m.title, m.description), q.query, $3)
+		FROM media m
+		JOIN (
+			SELECT id AS media_id, person_id FROM media WHERE person_id IS NOT NULL
+			UNION
+			SELECT m2.id, e.person_id FROM media m2 JOIN events e ON m2.event_id = e.id
+			UNION
+			SELECT media_id, person_id FROM media_people
+		) owners ON owners.media_id = m.id, q
+		WHERE m.search_vector @@ q.query
+	)
+	SELECT person_id, source, source_id, rank, snippet
+	FROM matches
+	ORDER BY rank DESC
+`
+
+// highlight escapes a ts_headline snippet for HTML and turns its markers into <mark> tags
+func highlight(snippet string) string {
+	s := html.EscapeString(snippet)
+	s = strings.ReplaceAll(s, markStart, "<mark>")
+	s = strings.ReplaceAll(s, markStop, "</mark>")
+	return s
+}
+
+// SearchPeopleAPI finds people whose first, middle or last name contains q.
+// Without q it returns everyone.
+func SearchPeopleAPI(c *fiber.Ctx, db *sql.DB) error {
+	query := c.Query("q")
+	if query == "" {
+		return GetAllPeopleAPI(c, db)
+	}
+
+	searchPattern := "%" + query + "%"
+	rows, err := db.Query(`
+		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
+		FROM people
+		WHERE first_name ILIKE $1 OR last_name ILIKE $1 OR middle_name ILIKE $1
+		ORDER BY last_name, first_name
+	`, searchPattern)
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Search failed",
+		})
+	}
+	defer rows.Close()
+
+	var people []models.PersonResponse
+	for rows.Next() {
+		var p models.Person
+		err := rows.Scan(
+			&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		people = append(people, p.ToResponse())
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    people,
+	})
+}
+
+// FullTextSearchAPI runs a ranked full-text search over people, notes, events
+// and media and groups the matches by person, best first. ?limit caps the
+// number of people returned (default 20, max 100).
+func FullTextSearchAPI(c *fiber.Ctx, db *sql.DB) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Search query is required",
+		})
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	} else if limit > 100 {
+		limit = 100
+	}
+
+	rows, err := db.Query(searchQuery, query, "%"+query+"%", headlineOptions)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Search failed",
+		})
+	}
+	defer rows.Close()
+
+	results := map[uuid.UUID]*models.SearchResult{}
+	for rows.Next() {
+		var personID uuid.UUID
+		var m models.SearchMatch
+		if err := rows.Scan(&personID, &m.Source, &m.SourceID, &m.Rank, &m.Snippet); err != nil {
+			continue
+		}
+		m.Snippet = highlight(m.Snippet)
+
+		r, ok := results[personID]
+		if !ok {
+			r = &models.SearchResult{}
+			results[personID] = r
+		}
+		r.Score += m.Rank
+		r.Matches = append(r.Matches, m)
+	}
+
+	// Best-scoring people first
+	ids := make([]uuid.UUID, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return results[ids[i]].Score > results[ids[j]].Score
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	people, err := loadPeople(db, ids)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Search failed",
+		})
+	}
+
+	// Initialize as empty slice to ensure JSON [] instead of null
+	data := []models.SearchResult{}
+	for _, id := range ids {
+		p, ok := people[id]
+		if !ok {
+			continue
+		}
+		r := results[id]
+		r.Person = p.ToResponse()
+		data = append(data, *r)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// loadPeople fetches the given people keyed by ID
+func loadPeople(db *sql.DB, ids []uuid.UUID) (map[uuid.UUID]models.Person, error) {
+	people := map[uuid.UUID]models.Person{}
+	if len(ids) == 0 {
+		return people, nil
+	}
+
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = id.String()
+	}
+
+	rows, err := db.Query(`
+		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
+		FROM people
+		WHERE id = ANY($1::uuid[])
+	`, pq.Array(idStrings))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Person
+		err := rows.Scan(
+			&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+			&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+			&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			continue
+		}
+		people[p.ID] = p
+	}
+
+	return people, rows.Err()
+}
@@ -0,0 +1,252 @@
+package people
+
+import (
+	"database/sql"
+	"farmily/app/models"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var eventTitles = map[string]string{
+	models.EventBirth:      "Born",
+	models.EventDeath:      "Died",
+	models.EventMarriage:   "Married",
+	models.EventDivorce:    "Divorced",
+	models.EventGraduation: "Graduated",
+	models.EventEmployment: "Employment",
+	models.EventRetirement: "Retired",
+	models.EventOther:      "Event",
+}
+
+// GetPersonTimelineAPI merges the person's vital dates, events and
+// relationship dates into one chronological feed
+func GetPersonTimelineAPI(c *fiber.Ctx, db *sql.DB) error {
+	id := c.Params("id")
+	personID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid person ID",
+		})
+	}
+
+	var p models.Person
+	err = db.QueryRow(`
+		SELECT id, first_name, middle_name, last_name, maiden_name, gender,
+			birth_date, birth_place, death_date, death_place, is_living,
+			occupation, biography, profile_photo_url, profile_media_id, created_by, created_at, updated_at
+		FROM people WHERE id = $1
+	`, personID).Scan(
+		&p.ID, &p.FirstName, &p.MiddleName, &p.LastName, &p.MaidenName, &p.Gender,
+		&p.BirthDate, &p.BirthPlace, &p.DeathDate, &p.DeathPlace, &p.IsLiving,
+		&p.Occupation, &p.Biography, &p.ProfilePhotoURL, &p.ProfileMediaID, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "Person not found",
+		})
+	} else if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Database error",
+		})
+	}
+
+	entries := []models.TimelineEntry{}
+
+	// 1. Events recorded for this person
+	eventRows, err := db.Query(`
+		SELECT id, event_type, event_date, event_place, description
+		FROM events WHERE person_id = $1
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch events",
+		})
+	}
+	defer eventRows.Close()
+
+	// Remember recorded events so vital dates and marriages aren't repeated
+	// from the people row or relationships
+	recordedVitals := map[string]bool{}
+	for eventRows.Next() {
+		var e models.Event
+		if err := eventRows.Scan(&e.ID, &e.EventType, &e.EventDate, &e.EventPlace, &e.Description); err != nil {
+			continue
+		}
+
+		entry := models.TimelineEntry{
+			Type:     e.EventType,
+			Title:    eventTitles[e.EventType],
+			Source:   models.TimelineSourceEvent,
+			SourceID: e.ID,
+		}
+		if e.EventDate.Valid {
+			entry.Date = &e.EventDate.Time
+			recordedVitals[e.EventType+e.EventDate.Time.Format("2006-01-02")] = true
+		}
+		if e.EventPlace.Valid {
+			entry.Place = e.EventPlace.String
+		}
+		if e.Description.Valid {
+			entry.Description = e.Description.String
+		}
+		entries = append(entries, entry)
+	}
+
+	// 2. Birth and death from the people row
+	if p.BirthDate.Valid && !recordedVitals[models.EventBirth+p.BirthDate.Time.Format("2006-01-02")] {
+		entry := models.TimelineEntry{
+			Date:     &p.BirthDate.Time,
+			Type:     models.EventBirth,
+			Title:    eventTitles[models.EventBirth],
+			Source:   models.TimelineSourcePerson,
+			SourceID: p.ID,
+		}
+		if p.BirthPlace.Valid {
+			entry.Place = p.BirthPlace.String
+		}
+		entries = append(entries, entry)
+	}
+	if p.DeathDate.Valid && !recordedVitals[models.EventDeath+p.DeathDate.Time.Format("2006-01-02")] {
+		entry := models.TimelineEntry{
+			Date:     &p.DeathDate.Time,
+			Type:     models.EventDeath,
+			Title:    eventTitles[models.EventDeath],
+			Source:   models.TimelineSourcePerson,
+			SourceID: p.ID,
+		}
+		if p.DeathPlace.Valid {
+			entry.Place = p.DeathPlace.String
+		}
+		entries = append(entries, entry)
+	}
+
+	// 3. Marriages and their end, from spouse relationships in either direction
+	spouseRows, err := db.Query(`
+		SELECT r.id, r.start_date, r.end_date,
+			o.id, o.first_name || ' ' || o.last_name, o.death_date
+		FROM relationships r
+		JOIN people o ON o.id = CASE WHEN r.person1_id = $1 THEN r.person2_id ELSE r.person1_id END
+		WHERE r.relationship_type = 'spouse' AND (r.person1_id = $1 OR r.person2_id = $1)
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch relationships",
+		})
+	}
+	defer spouseRows.Close()
+
+	for spouseRows.Next() {
+		var relID, spouseID uuid.UUID
+		var startDate, endDate, spouseDeath sql.NullTime
+		var spouseName string
+		if err := spouseRows.Scan(&relID, &startDate, &endDate, &spouseID, &spouseName, &spouseDeath); err != nil {
+			continue
+		}
+
+		if startDate.Valid && !recordedVitals[models.EventMarriage+startDate.Time.Format("2006-01-02")] {
+			entries = append(entries, models.TimelineEntry{
+				Date:              &startDate.Time,
+				Type:              models.EventMarriage,
+				Title:             "Married " + spouseName,
+				RelatedPersonID:   &spouseID,
+				RelatedPersonName: spouseName,
+				Source:            models.TimelineSourceRelationship,
+				SourceID:          relID,
+			})
+		}
+		if endDate.Valid && !recordedVitals[models.EventDivorce+endDate.Time.Format("2006-01-02")] {
+			// A marriage that ends on the spouse's death date is widowhood, not divorce
+			entryType, title := models.EventDivorce, "Divorced "+spouseName
+			if spouseDeath.Valid && spouseDeath.Time.Equal(endDate.Time) {
+				entryType, title = models.TimelineWidowed, "Widowed by the death of "+spouseName
+			}
+			entries = append(entries, models.TimelineEntry{
+				Date:              &endDate.Time,
+				Type:              entryType,
+				Title:             title,
+				RelatedPersonID:   &spouseID,
+				RelatedPersonName: spouseName,
+				Source:            models.TimelineSourceRelationship,
+				SourceID:          relID,
+			})
+		}
+	}
+
+	// 4. Children's births. A child is stored either as ('child', child -> parent)
+	// or as ('parent', parent -> child), so both directions are checked.
+	childRows, err := db.Query(`
+		SELECT r.id, ch.id, ch.first_name || ' ' || ch.last_name, ch.birth_date, ch.birth_place
+		FROM relationships r
+		JOIN people ch ON
+			(r.relationship_type = 'child' AND r.person2_id = $1 AND ch.id = r.person1_id) OR
+			(r.relationship_type = 'parent' AND r.person1_id = $1 AND ch.id = r.person2_id)
+		WHERE ch.birth_date IS NOT NULL
+	`, personID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch relationships",
+		})
+	}
+	defer childRows.Close()
+
+	seenChildren := map[uuid.UUID]bool{}
+	for childRows.Next() {
+		var relID, childID uuid.UUID
+		var childName string
+		var birthDate sql.NullTime
+		var birthPlace sql.NullString
+		if err := childRows.Scan(&relID, &childID, &childName, &birthDate, &birthPlace); err != nil {
+			continue
+		}
+		if seenChildren[childID] {
+			continue
+		}
+		seenChildren[childID] = true
+
+		entry := models.TimelineEntry{
+			Date:              &birthDate.Time,
+			Type:              models.TimelineChildBirth,
+			Title:             "Birth of " + childName,
+			RelatedPersonID:   &childID,
+			RelatedPersonName: childName,
+			Source:            models.TimelineSourceRelationship,
+			SourceID:          relID,
+		}
+		if birthPlace.Valid {
+			entry.Place = birthPlace.String
+		}
+		entries = append(entries, entry)
+	}
+
+	for i := range entries {
+		if entries[i].Date != nil && !entries[i].Date.Before(p.BirthDate.Time) {
+			entries[i].Age = p.GetAgeAt(*entries[i].Date)
+		}
+	}
+
+	// Oldest first; undated entries go last
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Date, entries[j].Date
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.Before(*b)
+	})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"person":  p.ToResponse(),
+			"entries": entries,
+		},
+	})
+}
@@ -2,8 +2,10 @@ package relationships
 
 import (
 	"database/sql"
+	"farmily/app/family"
 	"farmily/app/models"
 	"farmily/app/routes/auth"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -154,23 +156,73 @@ func CreateRelationshipAPI(c *fiber.Ctx, db *sql.DB) error {
 		endDate = *req.EndDate
 	}
 
-	_, err = db.Exec(`
+	tx, err := db.Begin()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start transaction",
+		})
+	}
+	defer tx.Rollback()
+
+	if err := family.LockTree(tx); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate relationship",
+		})
+	}
+
+	// Issues the tree already has around these people aren't this write's
+	// fault; only new ones are
+	before, err := family.Check(tx, person1ID, person2ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate relationship",
+		})
+	}
+
+	_, err = tx.Exec(`
 		INSERT INTO relationships (id, person1_id, person2_id, relationship_type, start_date, end_date, notes)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`, relationshipID, person1ID, person2ID, req.RelationshipType, startDate, endDate, req.Notes)
 
 	if err != nil {
+		log.Printf("Failed to create relationship: %v", err)
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
 			"message": "Failed to create relationship",
-			"error":   err.Error(),
+		})
+	}
+
+	after, err := family.Check(tx, person1ID, person2ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to validate relationship",
+		})
+	}
+	issues := family.Introduced(before, after)
+	if errs := family.Errors(issues); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": errs[0].Message,
+			"issues":  errs,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to commit transaction",
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Relationship created successfully",
-		"id":      relationshipID,
+		"success":  true,
+		"message":  "Relationship created successfully",
+		"id":       relationshipID,
+		"warnings": issues,
 	})
 }
 
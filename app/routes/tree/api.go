@@ -2,8 +2,12 @@ package tree
 
 import (
 	"database/sql"
+	"farmily/app/family"
+	"farmily/app/models"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type Node struct {
@@ -13,6 +17,14 @@ type Node struct {
 	PhotoURL  string `json:"photo_url,omitempty"`
 	BirthDate string `json:"birth_date,omitempty"`
 	DeathDate string `json:"death_date,omitempty"`
+	// Generation is relative to the root: negative for ancestors
+	Generation int `json:"generation"`
+	// Parent and child counts and the hidden flags tell the page which
+	// people can be expanded with a follow-up call rooted at them
+	ParentCount       int  `json:"parent_count"`
+	ChildCount        int  `json:"child_count"`
+	HasHiddenParents  bool `json:"has_hidden_parents"`
+	HasHiddenChildren bool `json:"has_hidden_children"`
 }
 
 type Link struct {
@@ -21,87 +33,167 @@ type Link struct {
 	Type   string `json:"type"`
 }
 
+// GetTreeDataAPI returns the part of the tree around a root person:
+// ?root=<id> (the first person entered if not given),
+// ?direction=ancestors|descendants|both and ?depth=<generations>. Expanding
+// a node is the same call rooted at it, usually with depth=1 and one
+// direction.
 func GetTreeDataAPI(c *fiber.Ctx, db *sql.DB) error {
-	// check authentication or if user is logged in
-	// ignoring strict auth check for now to allow easier testing if needed,
-	// but typically we should use auth.GetUserID(c) if per-user trees were a thing.
-	// Since it's a shared family tree, we just check if they are authenticated via middleware on the route group.
-
-	// 1. Fetch All People (Nodes)
-	rows, err := db.Query(`
-		SELECT id, first_name, last_name, gender, profile_photo_url, birth_date, death_date
-		FROM people
-	`)
+	direction := c.Query("direction", family.DirectionBoth)
+	switch direction {
+	case family.DirectionAncestors, family.DirectionDescendants, family.DirectionBoth:
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid direction: must be ancestors, descendants or both",
+		})
+	}
+
+	depth := c.QueryInt("depth", family.DefaultTreeDepth)
+	if depth < 1 || depth > family.MaxGenerations {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid depth: must be between 1 and " + strconv.Itoa(family.MaxGenerations),
+		})
+	}
+
+	var root uuid.UUID
+	if id := c.Query("root"); id != "" {
+		var err error
+		if root, err = uuid.Parse(id); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid root person ID",
+			})
+		}
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM people WHERE id = $1)", root).Scan(&exists); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"message": "Person not found",
+			})
+		}
+	} else {
+		err := db.QueryRow("SELECT id FROM people ORDER BY created_at, id LIMIT 1").Scan(&root)
+		if err == sql.ErrNoRows {
+			return c.JSON(fiber.Map{
+				"success": true,
+				"data": fiber.Map{
+					"root":      nil,
+					"direction": direction,
+					"depth":     depth,
+					"nodes":     []Node{},
+					"links":     []Link{},
+				},
+			})
+		} else if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Database error",
+			})
+		}
+	}
+
+	tree, err := family.LoadSubtree(db, root, direction, depth)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to fetch people",
+			"message": "Failed to fetch family tree",
 		})
 	}
-	defer rows.Close()
 
 	// Initialize as empty slices to ensure JSON [] instead of null
 	nodes := []Node{}
-	for rows.Next() {
-		var id, firstName, lastName, gender string
-		var photoURL, birthDate, deathDate sql.NullString
-
-		if err := rows.Scan(&id, &firstName, &lastName, &gender, &photoURL, &birthDate, &deathDate); err != nil {
-			continue
-		}
-
+	for _, p := range tree.People {
 		node := Node{
-			ID:     id,
-			Name:   firstName + " " + lastName,
-			Gender: gender,
+			ID:                p.ID.String(),
+			Name:              p.FirstName + " " + p.LastName,
+			Gender:            p.Gender,
+			Generation:        tree.Generations[p.ID],
+			ParentCount:       tree.ParentCount(p.ID),
+			ChildCount:        tree.ChildCount(p.ID),
+			HasHiddenParents:  tree.HasHiddenParents(p.ID),
+			HasHiddenChildren: tree.HasHiddenChildren(p.ID),
 		}
-		if photoURL.Valid {
-			node.PhotoURL = photoURL.String
+		if p.ProfileMediaID.Valid {
+			node.PhotoURL = models.PortraitURL(p.ID, models.VariantThumb, p.UpdatedAt)
+		} else if p.ProfilePhotoURL.Valid {
+			node.PhotoURL = models.MediaVariantURL(p.ProfilePhotoURL.String, models.VariantThumb)
 		}
-		if birthDate.Valid {
-			// Format date if needed, or send as is
-			node.BirthDate = birthDate.String
+		if p.BirthDate.Valid {
+			node.BirthDate = p.BirthDate.Time.Format("2006-01-02")
 		}
-		if deathDate.Valid {
-			node.DeathDate = deathDate.String
+		if p.DeathDate.Valid {
+			node.DeathDate = p.DeathDate.Time.Format("2006-01-02")
 		}
 
 		nodes = append(nodes, node)
 	}
 
-	// 2. Fetch All Relationships (Links)
-	relRows, err := db.Query(`
-		SELECT person1_id, person2_id, relationship_type
-		FROM relationships
-	`)
+	links := []Link{}
+	for _, r := range tree.Relationships {
+		// D3 expects source and target to match node IDs
+		links = append(links, Link{
+			Source: r.Person1ID.String(),
+			Target: r.Person2ID.String(),
+			Type:   r.RelationshipType,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"root":      root,
+			"direction": direction,
+			"depth":     depth,
+			"nodes":     nodes,
+			"links":     links,
+		},
+	})
+}
+
+// GetTreeIssuesAPI checks the whole tree for genealogical inconsistencies:
+// people who are their own ancestors, more than two parents, duplicated or
+// mirrored relationships, and dates that don't fit. ?severity=error|warning
+// narrows the report.
+func GetTreeIssuesAPI(c *fiber.Ctx, db *sql.DB) error {
+	severity := c.Query("severity")
+	if severity != "" && severity != family.SeverityError && severity != family.SeverityWarning {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid severity: must be error or warning",
+		})
+	}
+
+	g, err := family.LoadGraph(db)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to fetch relationships",
+			"message": "Failed to load family tree",
 		})
 	}
-	defer relRows.Close()
 
-	links := []Link{}
-	for relRows.Next() {
-		var p1, p2, relType string
-		if err := relRows.Scan(&p1, &p2, &relType); err != nil {
-			continue
+	counts := map[string]int{family.SeverityError: 0, family.SeverityWarning: 0}
+	issues := []family.Issue{}
+	for _, issue := range g.Validate() {
+		counts[issue.Severity]++
+		if severity == "" || issue.Severity == severity {
+			issues = append(issues, issue)
 		}
-
-		// D3 expects source and target to match node IDs
-		links = append(links, Link{
-			Source: p1,
-			Target: p2,
-			Type:   relType,
-		})
 	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"nodes": nodes,
-			"links": links,
+			"errors":   counts[family.SeverityError],
+			"warnings": counts[family.SeverityWarning],
+			"issues":   issues,
 		},
 	})
 }
@@ -20,4 +20,8 @@ func SetupTreeRoutes(app *fiber.App, db *sql.DB) {
 	app.Get("/api/tree/data", auth.AuthMiddleware, func(c *fiber.Ctx) error {
 		return GetTreeDataAPI(c, db)
 	})
+
+	app.Get("/api/tree/issues", auth.AuthMiddleware, func(c *fiber.Ctx) error {
+		return GetTreeIssuesAPI(c, db)
+	})
 }
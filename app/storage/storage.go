@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Backend kinds selected with STORAGE_BACKEND
+const (
+	KindLocal = "local"
+	KindS3    = "s3"
+)
+
+// ErrNotFound is returned when a key does not exist in the backend
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrInvalidKey is returned for keys that are empty or could escape the backend root
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// Backend stores media files by key. Keys are slash-separated paths relative
+// to the backend root, e.g. "2024/05/<uuid>.jpg", and are what media.file_path holds.
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// FileBackend is implemented by backends that keep objects on local disk so
+// they can be served with range support straight from the filesystem
+type FileBackend interface {
+	Path(key string) (string, error)
+}
+
+// New builds the backend of the given kind from environment variables
+func New(kind string) (Backend, error) {
+	switch kind {
+	case "", KindLocal:
+		return NewLocal(MediaDir())
+	case KindS3:
+		return NewS3(S3ConfigFromEnv())
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", kind)
+	}
+}
+
+// MediaDir returns the directory the local backend stores files in
+func MediaDir() string {
+	if dir := os.Getenv("MEDIA_DIR"); dir != "" {
+		return dir
+	}
+	return "./uploads"
+}
+
+// Copy streams one object from src to dst under the same key
+func Copy(ctx context.Context, src, dst Backend, key string) error {
+	r, info, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Put(ctx, key, r, info.Size, info.ContentType)
+}
+
+// CheckKey reports whether key is one a backend would accept
+func CheckKey(key string) error {
+	_, err := cleanKey(key)
+	return err
+}
+
+// cleanKey rejects keys that could escape the backend root
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." || part == "" {
+			return "", fmt.Errorf("%w %q", ErrInvalidKey, key)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,94 @@
+// Command backup writes a backup of the whole database, with its media
+// files, or restores one into an empty instance.
+//
+// Usage:
+//
+//	go run ./cmd/backup -out farmily.tar.gz [-passwords]
+//	go run ./cmd/backup -out farmily.json -json [-passwords]
+//	go run ./cmd/backup -restore farmily.tar.gz
+//
+// The database and storage backend are configured from the same environment
+// variables the server uses. Restoring runs the migrations first, so it can
+// target a freshly created database, and fails if the database already holds
+// family data; user accounts may exist.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"farmily/app/backup"
+	"farmily/app/config"
+	"farmily/app/database"
+)
+
+func main() {
+	out := flag.String("out", "", "file to write the backup to")
+	passwords := flag.Bool("passwords", false, "include users' password hashes")
+	jsonOnly := flag.Bool("json", false, "write the backup document alone, without media files")
+	restore := flag.String("restore", "", "backup file to restore")
+	flag.Parse()
+
+	if (*out == "") == (*restore == "") {
+		log.Fatal("Usage: backup -out file [-passwords] [-json] | backup -restore file")
+	}
+
+	config.InitDB()
+	db := config.GetDB()
+	defer db.Close()
+	config.InitStorage()
+	backend := config.GetStorage()
+
+	if *restore != "" {
+		f, err := os.Open(*restore)
+		if err != nil {
+			log.Fatal("Failed to open backup:", err)
+		}
+		defer f.Close()
+
+		if err := database.RunMigrations(db); err != nil {
+			log.Fatal("Failed to run migrations:", err)
+		}
+
+		report, err := backup.Restore(context.Background(), db, f, backend)
+		if err != nil {
+			log.Fatal("Restore failed; nothing was restored: ", err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+
+		log.Printf("Restored %d people and %d media files with %d warnings",
+			report.Rows["people"], report.MediaFiles, len(report.Warnings))
+		return
+	}
+
+	b, err := backup.Dump(db, backup.Options{PasswordHashes: *passwords})
+	if err != nil {
+		log.Fatal("Backup failed:", err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("Failed to create backup file:", err)
+	}
+	if *jsonOnly {
+		err = backup.WriteJSON(f, b)
+	} else {
+		err = backup.WriteArchive(context.Background(), f, b, backend)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		log.Fatal("Failed to write backup:", err)
+	}
+
+	log.Printf("Backed up %d people and %d media entries to %s",
+		len(b.Table("people").Rows), len(b.Table("media").Rows), *out)
+}
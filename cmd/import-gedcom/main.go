@@ -0,0 +1,95 @@
+// Command import-gedcom loads a GEDCOM 5.5.1 file or GEDCOM X JSON document
+// into the database and prints the import report as JSON.
+//
+// Usage:
+//
+//	go run ./cmd/import-gedcom -file family.ged [-user admin@example.com]
+//	go run ./cmd/import-gedcom -file family.json [-format gedcomx] [-source name]
+//
+// Files ending in .json are read as GEDCOM X unless -format says otherwise.
+// -source names where a GEDCOM X document comes from, so its person and fact
+// IDs are matched against earlier imports from the same source.
+//
+// The database is configured from the same environment variables the server
+// uses. The import runs in one transaction, so a file that fails part way
+// leaves nothing behind.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"farmily/app/config"
+	"farmily/app/importer"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	path := flag.String("file", "", "GEDCOM or GEDCOM X file to import")
+	email := flag.String("user", "", "email of the user credited with the imported people and notes")
+	format := flag.String("format", "", "gedcom or gedcomx (default from the file extension)")
+	source := flag.String("source", "", "where a GEDCOM X document comes from, for matching its IDs on later imports")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatal("Usage: import-gedcom -file family.ged [-user email] [-format gedcom|gedcomx]")
+	}
+
+	if *format == "" {
+		*format = importer.FormatGEDCOM
+		if strings.EqualFold(filepath.Ext(*path), ".json") {
+			*format = importer.FormatGEDCOMX
+		}
+	}
+	run := importer.ImportGEDCOM
+	switch *format {
+	case importer.FormatGEDCOM:
+	case importer.FormatGEDCOMX:
+		run = func(db *sql.DB, r io.Reader, userID uuid.NullUUID) (*importer.Report, error) {
+			return importer.ImportGEDCOMX(db, r, userID, importer.GEDCOMXOptions{Source: *source})
+		}
+	default:
+		log.Fatalf("Unknown format %q: use gedcom or gedcomx", *format)
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatal("Failed to open file:", err)
+	}
+	defer f.Close()
+
+	config.InitDB()
+	db := config.GetDB()
+	defer db.Close()
+
+	var userID uuid.NullUUID
+	if *email != "" {
+		err := db.QueryRow("SELECT id FROM users WHERE email = $1", *email).Scan(&userID)
+		if err != nil {
+			log.Fatalf("Unknown user %s: %v", *email, err)
+		}
+	}
+
+	report, err := run(db, f, userID)
+	if err != nil {
+		log.Fatal("Import failed; nothing was imported: ", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	log.Printf("Imported %d people, %d relationships, %d events and %d notes with %d warnings",
+		len(report.Created.People), len(report.Created.Relationships),
+		len(report.Created.Events), len(report.Created.Notes), len(report.Warnings))
+	if n := len(report.Updated.People) + len(report.Updated.Events); n > 0 {
+		log.Printf("Updated %d existing people and %d events", len(report.Updated.People), len(report.Updated.Events))
+	}
+}
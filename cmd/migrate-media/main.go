@@ -0,0 +1,106 @@
+// Command migrate-media copies every file referenced by the media table from
+// one storage backend to another, e.g. from local disk to an S3 bucket.
+//
+// Usage:
+//
+//	go run ./cmd/migrate-media -from local -to s3 [-delete-source] [-dry-run]
+//
+// Both backends are configured from the same environment variables the
+// server uses (MEDIA_DIR for local, S3_* for s3). After a successful run set
+// STORAGE_BACKEND to the destination and restart the server.
+//
+// Only the original uploads are copied. Resized image variants (variants/)
+// and profile portraits (portraits/) are not migrated; the server generates
+// them again from the originals the first time each one is requested, so
+// expect extra image processing for a while after switching backends.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"farmily/app/config"
+	"farmily/app/storage"
+)
+
+func main() {
+	from := flag.String("from", storage.KindLocal, "source backend (local or s3)")
+	to := flag.String("to", storage.KindS3, "destination backend (local or s3)")
+	deleteSource := flag.Bool("delete-source", false, "delete each file from the source after it is copied")
+	overwrite := flag.Bool("overwrite", false, "copy files that already exist in the destination")
+	dryRun := flag.Bool("dry-run", false, "list what would be copied without copying")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatal("Source and destination backends must differ")
+	}
+
+	src, err := storage.New(*from)
+	if err != nil {
+		log.Fatal("Failed to open source backend:", err)
+	}
+	dst, err := storage.New(*to)
+	if err != nil {
+		log.Fatal("Failed to open destination backend:", err)
+	}
+
+	config.InitDB()
+	db := config.GetDB()
+	defer db.Close()
+
+	rows, err := db.Query("SELECT file_path FROM media ORDER BY upload_date")
+	if err != nil {
+		log.Fatal("Failed to list media:", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			log.Fatal("Failed to read media row:", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	var copied, skipped, failed int
+	for _, key := range keys {
+		if !*overwrite {
+			exists, err := dst.Exists(ctx, key)
+			if err != nil {
+				log.Printf("✗ %s: %v", key, err)
+				failed++
+				continue
+			}
+			if exists {
+				skipped++
+				continue
+			}
+		}
+
+		if *dryRun {
+			log.Printf("would copy %s", key)
+			copied++
+			continue
+		}
+
+		if err := storage.Copy(ctx, src, dst, key); err != nil {
+			log.Printf("✗ %s: %v", key, err)
+			failed++
+			continue
+		}
+		copied++
+
+		if *deleteSource {
+			if err := src.Delete(ctx, key); err != nil {
+				log.Printf("Copied %s but failed to delete source: %v", key, err)
+			}
+		}
+	}
+
+	log.Printf("Done: %d copied, %d already present, %d failed (of %d files)", copied, skipped, failed, len(keys))
+	if failed > 0 {
+		log.Fatal("Some files could not be migrated")
+	}
+}
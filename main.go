@@ -2,11 +2,18 @@ package main
 
 import (
 	"log"
+	"strings"
 
 	"farmily/app/config"
 	"farmily/app/database"
 	"farmily/app/routes/auth"
+	"farmily/app/routes/backup"
 	"farmily/app/routes/dashboard"
+	"farmily/app/routes/events"
+	"farmily/app/routes/exports"
+	"farmily/app/routes/imports"
+	"farmily/app/routes/media"
+	"farmily/app/routes/notes"
 	"farmily/app/routes/people"
 	"farmily/app/routes/relationships"
 	"farmily/app/routes/tree"
@@ -15,8 +22,36 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/template/html/v2"
+	"github.com/valyala/fasthttp"
 )
 
+// uploadBodyLimit raises the request body limit for multipart uploads to the
+// routes that take files; everything else keeps Fiber's default limit.
+// fasthttp spools large multipart files to temporary files, so these bodies
+// are not held in memory.
+func uploadBodyLimit(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	if len(header.MultipartFormBoundary()) == 0 {
+		return fasthttp.RequestConfig{}
+	}
+
+	path, _, _ := strings.Cut(string(header.RequestURI()), "?")
+	path = strings.TrimSuffix(strings.ToLower(path), "/")
+	method := string(header.Method())
+
+	limit := 0
+	switch {
+	case method == fiber.MethodPost && path == "/api/media":
+		limit = media.MaxUploadSize
+	case method == fiber.MethodPut && strings.HasPrefix(path, "/api/people/") && strings.HasSuffix(path, "/photo"):
+		limit = media.MaxUploadSize
+	case method == fiber.MethodPost && strings.HasPrefix(path, "/api/import/"):
+		limit = imports.MaxImportSize + 1024*1024
+	case method == fiber.MethodPost && path == "/api/backup/restore":
+		limit = backup.MaxRestoreSize
+	}
+	return fasthttp.RequestConfig{MaxRequestBodySize: limit}
+}
+
 // customErrorHandler handles HTTP errors with custom templates
 func customErrorHandler(c *fiber.Ctx, err error) error {
 	// Status code defaults to 500
@@ -84,6 +119,9 @@ func main() {
 	config.InitDB()
 	defer config.GetDB().Close()
 
+	// Initialize media storage
+	config.InitStorage()
+
 	// Run database migrations
 	if err := database.RunMigrations(config.GetDB()); err != nil {
 		log.Fatal("Failed to run migrations:", err)
@@ -101,6 +139,7 @@ func main() {
 		PassLocalsToViews: true,
 		ErrorHandler:      customErrorHandler,
 	})
+	app.Server().HeaderReceived = uploadBodyLimit
 
 	// Middleware
 	app.Use(logger.New())
@@ -126,9 +165,28 @@ func main() {
 	// Setup relationships routes
 	relationships.SetupRelationshipsRoutes(app, config.GetDB())
 
+	// Setup events routes
+	events.SetupEventsRoutes(app, config.GetDB())
+
+	// Setup media routes and start background thumbnail generation
+	media.SetupMediaRoutes(app, config.GetDB())
+	media.StartVariantWorkers(2)
+
+	// Setup notes routes
+	notes.SetupNotesRoutes(app, config.GetDB())
+
+	// Setup import routes
+	imports.SetupImportRoutes(app, config.GetDB())
+
+	// Setup export routes
+	exports.SetupExportRoutes(app, config.GetDB())
+
 	// Setup tree routes
 	tree.SetupTreeRoutes(app, config.GetDB())
 
+	// Setup backup routes
+	backup.SetupBackupRoutes(app, config.GetDB())
+
 	// Catch-all route for 404 errors (must be last)
 	app.Use("*", func(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound, "Page not found")